@@ -15,18 +15,60 @@ func (act *Activity) InProgress() bool {
 	return act.active
 }
 
+// Progress returns how many ticks the Activity has taken and needs.
+func (act *Activity) Progress() (ticks, needed int) {
+	return act.ticks, act.ticksNeeded
+}
+
 func (act *Activity) Begin(delay int, exec action) (done bool) {
+	act.start(delay, exec)
+	return act.Continue()
+}
+
+func (act *Activity) start(delay int, exec action) {
 	act.ticks = 0
 	act.ticksNeeded = delay
 	act.exec = exec
 	act.active = true
-	return act.Continue()
+}
+
+// resume continues an Activity that was saved after it had taken the given
+// number of ticks.
+func (act *Activity) resume(ticks, needed int, exec action) {
+	act.start(needed, exec)
+	act.ticks = ticks
+}
+
+// finish finishes the Activity at once, however many ticks it has taken.
+func (act *Activity) finish() {
+	act.ticks = act.ticksNeeded
+	if act.exec != nil {
+		act.exec()
+	}
+	act.active = false
+}
+
+// skip counts ticks that passed without the Activity being continued, short
+// of finishing it, which only Continue does.
+func (act *Activity) skip(ticks int) {
+	act.ticks += ticks
+	if act.ticks >= act.ticksNeeded {
+		act.ticks = act.ticksNeeded - 1
+	}
+}
+
+// remaining returns how many more times the Activity must be continued to
+// finish.
+func (act *Activity) remaining() int {
+	return act.ticksNeeded - act.ticks
 }
 
 func (act *Activity) Continue() (done bool) {
 	act.ticks++
 	if act.ticks >= act.ticksNeeded {
-		act.exec()
+		if act.exec != nil {
+			act.exec()
+		}
 		act.active = false
 		done = true
 	}
@@ -16,11 +16,43 @@ type Properties map[string]interface{}
 
 var behaviorValidator = validator.New()
 
+// behaviorKinds maps the names used for abilities in a Mapfile to Behavior
+// constructors.
+var behaviorKinds = map[string]func() Behavior{
+	"grow":      func() Behavior { return new(Grow) },
+	"consume":   func() Behavior { return new(Consume) },
+	"move":      func() Behavior { return new(Move) },
+	"seek":      func() Behavior { return new(Seek) },
+	"pursue":    func() Behavior { return new(Pursue) },
+	"burrow":    func() Behavior { return new(Burrow) },
+	"reproduce": func() Behavior { return new(Reproduce) },
+	"script":    func() Behavior { return new(Script) },
+}
+
+// RegisterBehavior makes a Behavior available to Mapfiles under the given
+// name.
+func RegisterBehavior(name string, fn func() Behavior) {
+	behaviorKinds[name] = fn
+}
+
+// NewBehavior creates the Behavior registered under the given name and
+// defines it with the given properties.
+func NewBehavior(name string, properties Properties) (Behavior, bool) {
+	fn, ok := behaviorKinds[name]
+	if !ok {
+		return nil, false
+	}
+	if properties == nil {
+		properties = make(Properties)
+	}
+	return fn().Define(properties), true
+}
+
 func DefineBehavior(behavior Behavior, properties Properties) Behavior {
 	var err error
 
 	// Set custom properties.
-	err = mapstructure.Decode(behavior, properties)
+	err = mapstructure.Decode(properties, behavior)
 	Guard(err)
 
 	// Validate Behavior.
@@ -33,9 +65,13 @@ func DefineBehavior(behavior Behavior, properties Properties) Behavior {
 // ---------------------------------------------------------------------
 // Behavior: Grow
 
-// Grow increases the subject's energy by its growth rate.
+// Grow increases the subject's energy by its growth rate, scaled by the
+// fertility of the ground and the current season. Growth is halved in the
+// shade of a Layer that blocks light. If Roots names another Layer, the
+// subject also draws energy from an Entity in that Layer beneath it.
 type Grow struct {
-	Rate int `mapstructure:"rate";validate:"min=1,max=10"`
+	Rate  int    `mapstructure:"rate" validate:"min=1,max=10"`
+	Roots string `mapstructure:"roots"`
 }
 
 func (b *Grow) Define(props Properties) Behavior {
@@ -46,7 +82,14 @@ func (b *Grow) Define(props Properties) Behavior {
 func (b *Grow) Execute(wld *World, ent *Entity, vec Vector) (delay int, exec func()) {
 	delay = 10
 	exec = func() {
-		ent.Transfer(b.rateToEnergy())
+		energy := wld.Cell(vec).Terrain().GrowthFactor(b.rateToEnergy())
+		energy = wld.Clock().ScaleGrowth(energy)
+		if wld.Shaded(vec) {
+			energy /= 2
+		}
+		energy += b.feed(wld, vec)
+		energy += b.drawNutrients(wld, vec)
+		ent.Transfer(energy)
 	}
 	return
 }
@@ -55,13 +98,48 @@ func (b *Grow) rateToEnergy() int {
 	return b.Rate * 2
 }
 
+func (b *Grow) feed(wld *World, vec Vector) int {
+	field := wld.Field(FieldNutrients)
+	if field == nil {
+		return 0
+	}
+	return int(field.Take(vec, float64(b.Rate)))
+}
+
+func (b *Grow) drawNutrients(wld *World, vec Vector) int {
+	if b.Roots == "" {
+		return 0
+	}
+	src, ok := wld.Project(vec, b.Roots)
+	if !ok {
+		return 0
+	}
+
+	ents := wld.Cell(src).Shuffled(wld.Rand())
+	for i := range ents {
+		entity := ents[i]
+		if !entity.Alive() {
+			continue
+		}
+		energy := b.Rate
+		if energy > entity.Attrs.Energy {
+			energy = entity.Attrs.Energy
+		}
+		entity.Transfer(-energy)
+		return energy
+	}
+	return 0
+}
+
 // ---------------------------------------------------------------------
 // Behavior: Consume
 
 // Consume attempts to consume an adjacent entity. If successful, the subject
-// gains energy from the consumed entity.
+// gains energy from the consumed entity. If Layer names another Layer, the
+// subject forages there instead, including directly beneath or above itself.
 type Consume struct {
-	Diet []Trait `mapstructure:"diet"`
+	Diet  []Trait `mapstructure:"diet"`
+	Layer string  `mapstructure:"layer"`
 }
 
 func (b *Consume) Define(props Properties) Behavior {
@@ -70,7 +148,15 @@ func (b *Consume) Define(props Properties) Behavior {
 }
 
 func (b *Consume) Execute(wld *World, ent *Entity, vec Vector) (delay int, exec func()) {
-	vectors := wld.View(vec, 1)
+	origin := vec
+	vectors := wld.View(origin, 1)
+	if b.Layer != "" {
+		var ok bool
+		if origin, ok = wld.Project(vec, b.Layer); !ok {
+			return
+		}
+		vectors = append(wld.View(origin, 1), origin)
+	}
 
 	for i := range vectors {
 		vec := vectors[i]
@@ -79,17 +165,30 @@ func (b *Consume) Execute(wld *World, ent *Entity, vec Vector) (delay int, exec
 		}
 		cell := wld.Cell(vec)
 
-		ents := cell.Shuffled()
+		ents := cell.Shuffled(wld.Rand())
 		for j := range ents {
 			entity := ents[j]
-			if b.isEdible(ent) {
-				execDestroy, ok := wld.Destroy(entity, vec)
+			if entity.ID() == ent.ID() {
+				continue
+			}
+			if b.isEdible(entity) {
+				execDestroy, ok := wld.destroy(entity, vec, "consumed", false)
 				if ok {
 					energy := b.biomassToEnergy(entity.Biomass())
 					delay = 15
 					exec = func() {
+						ev := entityEvent(EventAttacked, entity, vec)
+						ev.Other = ent.ID()
+						wld.emit(ev)
+
+						// The prey may have escaped in the meantime.
+						if !wld.Cell(vec).Exists(entity) {
+							return
+						}
+						ev.Type = EventConsumed
+						wld.emit(ev)
 						execDestroy()
-						entity.Transfer(energy)
+						ent.Transfer(energy)
 					}
 				}
 				return
@@ -112,29 +211,38 @@ func (b *Consume) isEdible(ent *Entity) bool {
 }
 
 func (b *Consume) biomassToEnergy(biomass int) int {
-	return -biomass
+	return biomass
 }
 
 // ---------------------------------------------------------------------
 // Behavior: Move
 
+// Move walks the subject to an adjacent Cell. Moving onto rough ground
+// takes longer and costs more energy, according to its Terrain. The energy
+// cost is also scaled by the metabolism of the current season. If Trail
+// names a Field, the subject leaves Scent in it wherever it goes.
 type Move struct {
 	Dir        Vector  `mapstructure:"dir"`
-	Delay      int     `mapstructure:"speed";validate:"min=1,max=30"`
-	MoveRate   float32 `mapstructure:"moveRate";validate:"min=0,max=1"`
-	SwitchRate float32 `mapstructure:"switchRate";validate:"min=0,max=1"`
+	Delay      int     `mapstructure:"speed" validate:"min=1,max=30"`
+	MoveRate   float32 `mapstructure:"moveRate" validate:"min=0,max=1"`
+	SwitchRate float32 `mapstructure:"switchRate" validate:"min=0,max=1"`
+	Trail      string  `mapstructure:"trail"`
+	Scent      float64 `mapstructure:"scent" validate:"min=0"`
 }
 
 func (b *Move) Define(props Properties) Behavior {
-	b.Dir = b.randomDir()
 	b.Delay = 10
 	b.MoveRate = 1
 	b.SwitchRate = 1
+	b.Scent = 10
 	return DefineBehavior(b, props)
 }
 
 func (b *Move) Execute(wld *World, ent *Entity, vec Vector) (delay int, exec func()) {
 	// TODO: totally redo this to match spec
+	if b.Dir == (Vector{}) {
+		b.Dir = randomDir(wld.Rand())
+	}
 	dest := vec.Plus(b.Dir)
 
 	if !wld.Walkable(dest) {
@@ -144,27 +252,266 @@ func (b *Move) Execute(wld *World, ent *Entity, vec Vector) (delay int, exec fun
 		}
 	}
 
-	delay = 10
+	delay, execWalk := walk(wld, ent, vec, dest, b.Delay)
+	if execWalk == nil {
+		return
+	}
 	exec = func() {
 		b.Dir = dest.Minus(vec)
-		wld.Move(ent, vec, dest)
-		ent.Transfer(10)
+		if field := wld.Field(b.Trail); field != nil {
+			field.Add(vec, b.Scent)
+		}
+		execWalk()
+	}
+	return
+}
+
+// walk plans a move from src to dest that takes the given number of ticks
+// per unit of movement cost, and costs energy when done.
+func walk(wld *World, ent *Entity, src, dest Vector, speed int) (delay int, exec func()) {
+	execMove, ok := wld.Move(ent, src, dest)
+	if !ok {
+		return
+	}
+
+	cost := wld.Cell(dest).Terrain().Cost()
+	delay = speed * cost
+	exec = func() {
+		execMove()
+		ent.Transfer(-wld.Clock().ScaleMetabolism(cost))
 	}
 	return
 }
 
-func (b *Move) randomDir() Vector {
-	i := rand.Intn(len(directions))
+func randomDir(rng *rand.Rand) Vector {
+	i := rng.Intn(len(directions))
 	return directions[i]
 }
 
 var directions = []Vector{
-	Vec2D(0, -1),
-	Vec2D(1, -1),
-	Vec2D(1, 0),
-	Vec2D(1, 1),
-	Vec2D(0, 1),
-	Vec2D(-1, 1),
-	Vec2D(-1, 0),
-	Vec2D(-1, -1),
+	Vec(0, -1, 0),
+	Vec(1, -1, 0),
+	Vec(1, 0, 0),
+	Vec(1, 1, 0),
+	Vec(0, 1, 0),
+	Vec(-1, 1, 0),
+	Vec(-1, 0, 0),
+	Vec(-1, -1, 0),
+}
+
+// ---------------------------------------------------------------------
+// Behavior: Seek
+
+// Seek moves the subject up the gradient of a Field, towards the neighboring
+// Cell where it is strongest.
+type Seek struct {
+	Field string `mapstructure:"field" validate:"required"`
+	Delay int    `mapstructure:"speed" validate:"min=1,max=30"`
+}
+
+func (b *Seek) Define(props Properties) Behavior {
+	b.Delay = 10
+	return DefineBehavior(b, props)
+}
+
+func (b *Seek) Execute(wld *World, ent *Entity, vec Vector) (delay int, exec func()) {
+	field := wld.Field(b.Field)
+	if field == nil {
+		return
+	}
+	dest, ok := field.Uphill(wld, vec)
+	if !ok {
+		return
+	}
+	return walk(wld, ent, vec, dest, b.Delay)
+}
+
+// ---------------------------------------------------------------------
+// Behavior: Pursue
+
+// Pursue moves the subject towards the nearest Entity with one of the Prey
+// traits within its Radius. If none are in sight and Field is set, it
+// follows that Field uphill instead, like a scent trail.
+type Pursue struct {
+	Prey   []Trait `mapstructure:"prey"`
+	Radius int     `mapstructure:"radius" validate:"min=1,max=10"`
+	Field  string  `mapstructure:"field"`
+	Delay  int     `mapstructure:"speed" validate:"min=1,max=30"`
+}
+
+func (b *Pursue) Define(props Properties) Behavior {
+	b.Prey = make([]Trait, 0)
+	b.Radius = 3
+	b.Delay = 8
+	return DefineBehavior(b, props)
+}
+
+func (b *Pursue) Execute(wld *World, ent *Entity, vec Vector) (delay int, exec func()) {
+	if target, ok := b.nearestPrey(wld, ent, vec); ok {
+		dest := vec.Plus(target.Minus(vec).Dir())
+		if wld.Walkable(dest) {
+			return walk(wld, ent, vec, dest, b.Delay)
+		}
+	}
+
+	if field := wld.Field(b.Field); field != nil {
+		if dest, ok := field.Uphill(wld, vec); ok {
+			return walk(wld, ent, vec, dest, b.Delay)
+		}
+	}
+	return
+}
+
+func (b *Pursue) nearestPrey(wld *World, ent *Entity, vec Vector) (Vector, bool) {
+	best, found := 0, false
+	target := vec
+	vectors := wld.ViewR(vec, b.Radius)
+	for i := range vectors {
+		v := vectors[i]
+		dist := distance(vec, v)
+		if found && dist >= best {
+			continue
+		}
+		for _, other := range wld.Cell(v).Entities() {
+			if other.ID() != ent.ID() && b.isPrey(other) {
+				best, target, found = dist, v, true
+				break
+			}
+		}
+	}
+	return target, found
+}
+
+func (b *Pursue) isPrey(ent *Entity) bool {
+	for _, trait := range b.Prey {
+		if ent.HasTrait(trait) {
+			return true
+		}
+	}
+	return false
+}
+
+// distance returns the number of steps between two Vectors, moving
+// diagonally where possible.
+func distance(a, b Vector) int {
+	d := b.Minus(a)
+	dx, dy := d.X, d.Y
+	if dx < 0 {
+		dx = -dx
+	}
+	if dy < 0 {
+		dy = -dy
+	}
+	if dx > dy {
+		return dx
+	}
+	return dy
+}
+
+// ---------------------------------------------------------------------
+// Behavior: Burrow
+
+// Burrow moves the subject into the Layer directly below or above it. The
+// destination Layer must be permeable and walkable at the subject's X and Y
+// coordinates.
+type Burrow struct {
+	Dir   string `mapstructure:"dir" validate:"oneof=down up toggle"`
+	Delay int    `mapstructure:"speed" validate:"min=1,max=30"`
+}
+
+func (b *Burrow) Define(props Properties) Behavior {
+	b.Dir = "toggle"
+	b.Delay = 20
+	return DefineBehavior(b, props)
+}
+
+func (b *Burrow) Execute(wld *World, ent *Entity, vec Vector) (delay int, exec func()) {
+	dest, ok := b.destination(wld, vec)
+	if !ok {
+		return
+	}
+	execMove, ok := wld.Move(ent, vec, dest)
+	if !ok {
+		return
+	}
+
+	delay = b.Delay
+	exec = execMove
+	return
+}
+
+func (b *Burrow) destination(wld *World, vec Vector) (Vector, bool) {
+	layer := wld.Layer(vec.Z)
+	candidates := make([]int, 0, 2)
+	if b.Dir != "up" {
+		if z, ok := layer.Below(); ok {
+			candidates = append(candidates, z)
+		}
+	}
+	if b.Dir != "down" {
+		if z, ok := layer.Above(); ok {
+			candidates = append(candidates, z)
+		}
+	}
+
+	for _, z := range candidates {
+		dest := Vec(vec.X, vec.Y, z)
+		if wld.Layer(z).Permeable() && wld.Walkable(dest) {
+			return dest, true
+		}
+	}
+	return vec, false
+}
+
+// ---------------------------------------------------------------------
+// Behavior: Reproduce
+
+// Reproduce spawns an offspring of the subject's Species in an adjacent
+// walkable Cell, once the subject has at least Threshold energy. The
+// offspring takes Share of the subject's energy.
+type Reproduce struct {
+	Threshold int     `mapstructure:"threshold" validate:"min=1"`
+	Share     float64 `mapstructure:"share" validate:"min=0,max=1"`
+	Delay     int     `mapstructure:"speed" validate:"min=1,max=30"`
+}
+
+func (b *Reproduce) Define(props Properties) Behavior {
+	b.Threshold = 100
+	b.Share = 0.5
+	b.Delay = 20
+	return DefineBehavior(b, props)
+}
+
+func (b *Reproduce) Execute(wld *World, ent *Entity, vec Vector) (delay int, exec func()) {
+	if ent.Attrs.Energy < b.Threshold {
+		return
+	}
+	species := wld.Species(ent.Species())
+	if species == nil {
+		return
+	}
+	dest := wld.RandWalkable(vec, 1)
+	if !wld.Walkable(dest) {
+		return
+	}
+
+	// The offspring only gets an ID once it's born, so that planning has no
+	// effect on the World.
+	delay = b.Delay
+	exec = func() {
+		child := species.Spawn(wld)
+		execSpawn, ok := wld.Spawn(child, dest)
+		if !ok {
+			return
+		}
+		energy := int(float64(ent.Attrs.Energy) * b.Share)
+		ent.Transfer(-energy)
+		child.Attrs.Energy = energy
+
+		ev := entityEvent(EventReproduced, ent, vec)
+		ev.Other = child.ID()
+		wld.emit(ev)
+		execSpawn()
+	}
+	return
 }
@@ -0,0 +1,157 @@
+package ecoscript_test
+
+import (
+	. "github.com/dustinrohde/ecoscript"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Behavior", func() {
+	var world *World
+
+	// add adds an Entity to the World and returns it.
+	add := func(ent *Entity, vec Vector) *Entity {
+		exec, ok := world.Add(ent, vec)
+		Expect(ok).To(BeTrue())
+		exec()
+		return ent
+	}
+
+	// perform executes a Behavior and carries out its activity at once.
+	perform := func(behavior Behavior, ent *Entity, vec Vector) (delay int, ok bool) {
+		delay, exec := behavior.Execute(world, ent, vec)
+		if exec == nil {
+			return delay, false
+		}
+		exec()
+		return delay, true
+	}
+
+	BeforeEach(func() {
+		world = NewWorld(3, 3, []string{"canopy", "ground", "soil"})
+		Expect(world.Relate("canopy", "ground")).To(Succeed())
+		Expect(world.Relate("ground", "soil")).To(Succeed())
+	})
+
+	Describe("Grow", func() {
+		var grow Behavior
+
+		BeforeEach(func() {
+			grow = new(Grow).Define(Properties{"rate": 5})
+		})
+
+		It("should grow half as much in the shade", func() {
+			world.Layer(0).SetBlocksLight(true)
+			add(NewEntity("crown", "Y"), Vec(1, 1, 0))
+			sunny := add(NewEntity("grass", ","), Vec(0, 1, 1))
+			shaded := add(NewEntity("grass", ","), Vec(1, 1, 1))
+
+			_, ok := perform(grow, sunny, Vec(0, 1, 1))
+			Expect(ok).To(BeTrue())
+			_, ok = perform(grow, shaded, Vec(1, 1, 1))
+			Expect(ok).To(BeTrue())
+			Expect(sunny.Attrs.Energy).To(Equal(10))
+			Expect(shaded.Attrs.Energy).To(Equal(5))
+		})
+
+		It("should scale with the fertility of the ground", func() {
+			rich := DefaultTerrain
+			rich.Fertility = 1.5
+			world.Cell(Vec(0, 0, 1)).SetTerrain(rich)
+			grass := add(NewEntity("grass", ","), Vec(0, 0, 1))
+
+			_, ok := perform(grow, grass, Vec(0, 0, 1))
+			Expect(ok).To(BeTrue())
+			Expect(grass.Attrs.Energy).To(Equal(15))
+		})
+
+		It("should draw energy through its roots", func() {
+			grow = new(Grow).Define(Properties{"rate": 5, "roots": "soil"})
+			tree := add(NewEntity("tree", "A"), Vec(1, 1, 1))
+			humus := add(NewEntity("humus", "~").AddAttributes(&Attributes{Walkable: true, Energy: 3}), Vec(1, 1, 2))
+
+			_, ok := perform(grow, tree, Vec(1, 1, 1))
+			Expect(ok).To(BeTrue())
+			Expect(tree.Attrs.Energy).To(Equal(13))
+			Expect(humus.Attrs.Energy).To(Equal(0))
+		})
+	})
+
+	Describe("Move", func() {
+		It("should take longer and cost more on rough ground", func() {
+			rough := DefaultTerrain
+			rough.MoveCost = 3
+			world.Cell(Vec(2, 1, 1)).SetTerrain(rough)
+			sheep := add(NewEntity("sheep", "&").AddAttributes(&Attributes{Energy: 10}), Vec(1, 1, 1))
+
+			move := new(Move).Define(Properties{"speed": 10, "dir": Vec(1, 0, 0)})
+			delay, ok := perform(move, sheep, Vec(1, 1, 1))
+			Expect(ok).To(BeTrue())
+			Expect(delay).To(Equal(30))
+			Expect(sheep.Attrs.Energy).To(Equal(7))
+			Expect(world.Cell(Vec(2, 1, 1)).Exists(sheep)).To(BeTrue())
+		})
+	})
+
+	Describe("Consume", func() {
+		var bird, berry *Entity
+
+		BeforeEach(func() {
+			bird = add(NewEntity("bird", "v").AddAttributes(&Attributes{Walkable: true, Energy: 10}), Vec(1, 1, 0))
+			berry = add(NewEntity("berry", "*").AddAttributes(&Attributes{Walkable: true, Energy: 5, Size: 1, Mass: 4}).
+				AddTraits("fruit"), Vec(1, 1, 1))
+		})
+
+		It("should forage in another Layer, directly beneath the subject", func() {
+			consume := new(Consume).Define(Properties{"diet": []Trait{"fruit"}, "layer": "ground"})
+			_, ok := perform(consume, bird, Vec(1, 1, 0))
+			Expect(ok).To(BeTrue())
+			Expect(world.Cell(Vec(1, 1, 1)).Exists(berry)).To(BeFalse())
+			Expect(bird.Attrs.Energy).To(Equal(14))
+		})
+
+		It("should only forage in its own Layer otherwise", func() {
+			consume := new(Consume).Define(Properties{"diet": []Trait{"fruit"}})
+			_, ok := perform(consume, bird, Vec(1, 1, 0))
+			Expect(ok).To(BeFalse())
+			Expect(world.Cell(Vec(1, 1, 1)).Exists(berry)).To(BeTrue())
+		})
+	})
+
+	Describe("Burrow", func() {
+		var mole *Entity
+
+		BeforeEach(func() {
+			mole = add(NewEntity("mole", "m").AddAttributes(&Attributes{Energy: 10}), Vec(1, 1, 1))
+		})
+
+		It("should move the subject into a permeable Layer", func() {
+			world.Layer(2).SetPermeable(true)
+			burrow := new(Burrow).Define(Properties{"dir": "down", "speed": 5})
+			delay, ok := perform(burrow, mole, Vec(1, 1, 1))
+			Expect(ok).To(BeTrue())
+			Expect(delay).To(Equal(5))
+			Expect(world.Cell(Vec(1, 1, 1)).Exists(mole)).To(BeFalse())
+			Expect(world.Cell(Vec(1, 1, 2)).Exists(mole)).To(BeTrue())
+
+			burrow = new(Burrow).Define(Properties{"dir": "toggle"})
+			world.Layer(1).SetPermeable(true)
+			_, ok = perform(burrow, mole, Vec(1, 1, 2))
+			Expect(ok).To(BeTrue())
+			Expect(world.Cell(Vec(1, 1, 1)).Exists(mole)).To(BeTrue())
+		})
+
+		It("should not move into Layers that are impermeable or occupied", func() {
+			burrow := new(Burrow).Define(Properties{"dir": "down"})
+			_, ok := perform(burrow, mole, Vec(1, 1, 1))
+			Expect(ok).To(BeFalse())
+
+			world.Layer(2).SetPermeable(true)
+			add(NewEntity("rock", "o"), Vec(1, 1, 2))
+			_, ok = perform(burrow, mole, Vec(1, 1, 1))
+			Expect(ok).To(BeFalse())
+			Expect(world.Cell(Vec(1, 1, 1)).Exists(mole)).To(BeTrue())
+		})
+	})
+})
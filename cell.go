@@ -7,6 +7,7 @@ import (
 type Cell struct {
 	occupier *Entity
 	stack    *entStack
+	terrain  Terrain
 }
 
 type entStack struct {
@@ -19,6 +20,7 @@ func newCell() *Cell {
 	cell.stack = new(entStack)
 	cell.stack.entities = make([]*Entity, 0)
 	cell.stack.indexes = make(map[EntityID]int)
+	cell.terrain = DefaultTerrain
 	return cell
 }
 
@@ -38,148 +40,83 @@ func (c *Cell) Entities() []*Entity {
 	return c.stack.entities
 }
 
-func (c *Cell) Shuffled() []*Entity {
+// Shuffled returns the Entities in the Cell in an order drawn from rng.
+func (c *Cell) Shuffled(rng *rand.Rand) []*Entity {
 	ents := c.Entities()
 	shuffled := make([]*Entity, len(ents))
-	for i, j := range rand.Perm(len(ents)) {
+	for i, j := range rng.Perm(len(ents)) {
 		shuffled[i] = ents[j]
 	}
 	return shuffled
 }
 
 func (c *Cell) Exists(ent *Entity) bool {
-	if !ent.Walkable() {
-		if c.occupier.ID() == ent.ID() {
-			return true
-		}
-	} else {
-		for id := range c.stack.indexes {
-			if id == ent.ID() {
-				return true
-			}
-		}
+	_, ok := c.stack.indexes[ent.ID()]
+	return ok
+}
+
+// Accepts returns true if the Entity could be added to the Cell right now.
+func (c *Cell) Accepts(ent *Entity) bool {
+	if c.Exists(ent) {
+		return false
 	}
-	return false
+	return ent.Walkable() || !c.Occupied()
 }
 
 func (c *Cell) Add(ent *Entity) (exec action, ok bool) {
-	if !ent.Walkable() {
-		if c.Occupied() {
-			return
-		}
+	if !c.Accepts(ent) {
+		return
+	}
 
-		index := c.stack.indexes[ent.ID()]
-		exec, ok = c.setAt(index, ent)
-		if !ok {
+	exec = func() {
+		if !c.Accepts(ent) {
 			return
 		}
-		c.occupier = ent
-	}
-
-	exec = chain(exec, func() {
-		index := len(c.stack.entities)
+		if !ent.Walkable() {
+			c.occupier = ent
+		}
+		c.stack.indexes[ent.ID()] = len(c.stack.entities)
 		c.stack.entities = append(c.stack.entities, ent)
-		c.stack.indexes[ent.ID()] = index
-	})
+	}
 	ok = true
 	return
 }
 
-//func (c *Cell) setOccupier(ent *Entity) (exec action, ok bool) {
-//	prevOrg := c.occupier
-//	index := c.stack.indexes[prevOrg.ID()]
-//	exec, ok = c.removeIndex(index)
-//	if !ok {
-//		return
-//	}
-//
-//	exec = chain(exec, func() {
-//		c.stack.indexes[ent.ID()] = index
-//		delete(c.stack.indexes, prevOrg.ID())
-//		c.occupier = ent
-//	})
-//	ok = true
-//	return
-//}
-//
-//func (c *Cell) setOccupier(ent *Entity) (exec action, ok bool) {
-//	prevOrg := c.occupier
-//	index := c.stack.indexes[prevOrg.ID()]
-//	exec, ok = c.setAt(index, ent)
-//	if !ok {
-//		return
-//	}
-//
-//	exec = chain(exec, func() {
-//		c.stack.indexes[ent.ID()] = index
-//		delete(c.stack.indexes, prevOrg.ID())
-//		c.occupier = ent
-//	})
-//	ok = true
-//	return
-//}
-
 func (c *Cell) Remove(ent *Entity) (exec action, ok bool) {
-	index := c.stack.indexes[ent.ID()]
-	exec, ok = c.removeIndex(index)
-	if !ok {
+	if !c.Exists(ent) {
 		return
 	}
 
-	if !ent.Walkable() && c.Occupied() && c.occupier.ID() == ent.ID() {
-		exec = chain(exec, func() {
-			c.occupier = nil
-		})
-		ok = true
-	}
-	return
-}
-
-func (c *Cell) setAt(i int, ent *Entity) (exec action, ok bool) {
-	exec, ok = c.removeIndex(i)
-	if !ok {
-		return
+	exec = func() {
+		c.removeEnt(ent.ID())
 	}
-
-	exec = chain(exec, func() {
-		c.stack.indexes[ent.ID()] = i
-	})
 	ok = true
 	return
-
 }
 
-func (c *Cell) removeEnt(id EntityID) (exec action, ok bool) {
+// removeEnt removes the Entity with the given ID, if present. The index is
+// looked up when called rather than when planned, since other actions may
+// have reshuffled the stack in between.
+func (c *Cell) removeEnt(id EntityID) {
 	index, ok := c.stack.indexes[id]
 	if !ok {
 		return
 	}
-	exec, ok = c.removeIndex(index)
-	if !ok {
-		return
+	if c.occupier != nil && c.occupier.ID() == id {
+		c.occupier = nil
 	}
-	exec = chain(exec, func() {
-		if id == c.occupier.ID() {
-			c.occupier = nil
-		}
-		delete(c.stack.indexes, id)
-	})
-	ok = true
-	return
+	delete(c.stack.indexes, id)
+	c.removeIndex(index)
 }
 
-func (c *Cell) removeIndex(i int) (exec action, ok bool) {
-	ok = true
-
-	if i >= len(c.stack.entities) {
-		return
-	}
+func (c *Cell) removeIndex(i int) {
+	ents := c.stack.entities
+	copy(ents[i:], ents[i+1:])
+	z := len(ents) - 1
+	ents[z] = nil
+	c.stack.entities = ents[:z]
 
-	exec = func() {
-		copy(c.stack.entities[i:], c.stack.entities[i+1:])
-		z := len(c.stack.entities) - 1
-		c.stack.entities[z] = nil
-		c.stack.entities = c.stack.entities[:z]
+	for j := i; j < z; j++ {
+		c.stack.indexes[c.stack.entities[j].ID()] = j
 	}
-	return
 }
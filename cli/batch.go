@@ -0,0 +1,124 @@
+package cli
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/dustinrohde/ecoscript"
+)
+
+// defaultStop keeps batch runs from running forever when neither the
+// command line nor the Mapfile says when to stop.
+const defaultStop = "tick >= 1000"
+
+// stringList is a flag that may be given more than once.
+type stringList []string
+
+func (l *stringList) String() string {
+	return strings.Join(*l, "; ")
+}
+
+func (l *stringList) Set(s string) error {
+	*l = append(*l, s)
+	return nil
+}
+
+// batch runs a Mapfile headless until a stop condition is met, then prints
+// a summary.
+func batch(flags *flag.FlagSet, args []string) error {
+	wf := addWorldFlags(flags)
+	var until stringList
+	flags.Var(&until, "until", "stop when the given condition is met; may be repeated (default: the Mapfile's, or \""+defaultStop+"\")")
+	asJSON := flags.Bool("json", false, "print the summary as JSON")
+	scheduler := flags.String("scheduler", "serial", "how to tick entities: serial, parallel, sparse or discrete")
+	workers := flags.Int("workers", 0, "number of goroutines for the parallel scheduler (default: one per CPU)")
+	html := flags.String("html", "", "also write an HTML report of the run to the given file")
+	every := flags.Int("every", 10, "number of ticks between the frames of the HTML report")
+	tile := flags.Int("tile", 4, "width and height of each cell in the HTML report's frames, in pixels")
+	if err := parseFlags(flags, args, 0, 0); err != nil {
+		return err
+	}
+	if *workers < 0 {
+		return usagef("-workers must not be negative")
+	}
+	if *every < 1 || *tile < 1 {
+		return usagef("-every and -tile must be 1 or greater")
+	}
+	sched, err := newScheduler(*scheduler, *workers)
+	if err != nil {
+		return err
+	}
+
+	conditions, err := ecoscript.ParseStopConditions(until)
+	if err != nil {
+		return usagef("%s", err)
+	}
+
+	mapfile, err := ecoscript.ParseMapfile(wf.mapfile)
+	if err != nil {
+		return err
+	}
+	if err := mapfile.CheckStopConditions(conditions); err != nil {
+		return usagef("%s", err)
+	}
+	if len(conditions) == 0 {
+		if conditions, err = mapfile.StopConditions(); err != nil {
+			return err
+		}
+	}
+	if len(conditions) == 0 {
+		conditions, _ = ecoscript.ParseStopConditions([]string{defaultStop})
+	}
+
+	world, seed, err := wf.loadFrom(mapfile)
+	if err != nil {
+		return err
+	}
+	world.SetScheduler(sched)
+	logFailures(world)
+
+	var report *ecoscript.Report
+	if *html != "" {
+		timeline := ecoscript.NewTimeline(world, *every, ecoscript.ImageOptions{TileSize: *tile, Terrain: true})
+		report = ecoscript.RunUntil(world, conditions, timeline.Observe)
+		timeline.Capture(world)
+		timeline.Close()
+		if err := writeTimeline(*html, timeline, fmt.Sprintf("%s (seed %d)", wf.mapfile, seed), report); err != nil {
+			return err
+		}
+	} else {
+		report = ecoscript.RunUntil(world, conditions)
+	}
+
+	if *asJSON {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		enc.SetEscapeHTML(false)
+		return enc.Encode(report)
+	}
+	return report.WriteText(os.Stdout)
+}
+
+func writeTimeline(path string, timeline *ecoscript.Timeline, title string, report *ecoscript.Report) error {
+	out, err := create(path)
+	if err != nil {
+		return err
+	}
+	defer out.Close()
+
+	if err := timeline.WriteHTML(out, title, report); err != nil {
+		return err
+	}
+	return out.Close()
+}
+
+func newScheduler(name string, workers int) (ecoscript.Scheduler, error) {
+	sched, err := ecoscript.NewScheduler(name, workers)
+	if err != nil {
+		return nil, usagef("%s", err)
+	}
+	return sched, nil
+}
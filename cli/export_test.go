@@ -0,0 +1,3 @@
+package cli
+
+var Dispatch = dispatch
@@ -0,0 +1,102 @@
+package cli
+
+import (
+	"flag"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/dustinrohde/ecoscript"
+)
+
+// imageFlags are the flags for rendering a World to an image.
+type imageFlags struct {
+	output  string
+	tile    int
+	palette string
+	frames  int
+	every   int
+	delay   time.Duration
+}
+
+func addImageFlags(flags *flag.FlagSet) *imageFlags {
+	imf := new(imageFlags)
+	flags.StringVar(&imf.output, "o", "", "write a PNG or animated GIF image to the given file instead of printing")
+	flags.IntVar(&imf.tile, "tile", ecoscript.DefaultTileSize, "width and height of each cell in an image, in pixels")
+	flags.StringVar(&imf.palette, "palette", "", "comma-separated KEY=COLOR pairs to color entities by species in an image")
+	flags.IntVar(&imf.frames, "frames", 50, "number of frames in a GIF")
+	flags.IntVar(&imf.every, "every", 1, "number of ticks between frames of a GIF")
+	flags.DurationVar(&imf.delay, "delay", 100*time.Millisecond, "time to show each frame of a GIF")
+	return imf
+}
+
+// check validates the flags before the World is loaded.
+func (imf *imageFlags) check() error {
+	if imf.output == "" {
+		return nil
+	}
+	switch strings.ToLower(filepath.Ext(imf.output)) {
+	case ".png", ".gif":
+	default:
+		return usagef("-o must name a .png or .gif file")
+	}
+	if imf.tile < 1 {
+		return usagef("-tile must be 1 or greater")
+	}
+	if imf.frames < 1 || imf.every < 1 {
+		return usagef("-frames and -every must be 1 or greater")
+	}
+	_, err := imf.parsePalette()
+	return err
+}
+
+func (imf *imageFlags) parsePalette() (map[string]ecoscript.Color, error) {
+	colors := make(map[string]ecoscript.Color)
+	if imf.palette == "" {
+		return colors, nil
+	}
+	for _, pair := range strings.Split(imf.palette, ",") {
+		parts := strings.SplitN(pair, "=", 2)
+		if len(parts) != 2 {
+			return nil, usagef("palette entry '%s' should be KEY=COLOR", pair)
+		}
+		color := ecoscript.Color(strings.TrimSpace(parts[1]))
+		if err := color.Validate(); err != nil {
+			return nil, usagef("%s", err)
+		}
+		colors[strings.TrimSpace(parts[0])] = color
+	}
+	return colors, nil
+}
+
+// write renders the given Layers of the World, composited or as a Heatmap if
+// one is given, to the output file.
+func (imf *imageFlags) write(world *ecoscript.World, layers []int, heatmap ecoscript.Heatmap) error {
+	palette, err := imf.parsePalette()
+	if err != nil {
+		return err
+	}
+	opts := ecoscript.ImageOptions{
+		TileSize: imf.tile,
+		Palette:  palette,
+		Layers:   layers,
+		Terrain:  true,
+		Heatmap:  heatmap,
+	}
+
+	out, err := create(imf.output)
+	if err != nil {
+		return err
+	}
+	defer out.Close()
+
+	if strings.ToLower(filepath.Ext(imf.output)) == ".gif" {
+		err = ecoscript.WriteGIF(out, world, imf.frames, imf.every, imf.delay, opts)
+	} else {
+		err = ecoscript.WritePNG(out, world, opts)
+	}
+	if err != nil {
+		return err
+	}
+	return out.Close()
+}
@@ -0,0 +1,82 @@
+package cli
+
+import (
+	"flag"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/dustinrohde/ecoscript"
+	"github.com/dustinrohde/ecoscript/tui"
+)
+
+// inspect describes an Entity or a Cell at a given tick.
+func inspect(flags *flag.FlagSet, args []string) error {
+	wf := addWorldFlags(flags)
+	tick := flags.Int("tick", 0, "the tick to inspect the world at")
+	entity := flags.Int("entity", -1, "the ID of the entity to describe")
+	cell := flags.String("cell", "", "the cell to describe, as X,Y or X,Y,LAYER")
+	if err := parseFlags(flags, args, 0, 0); err != nil {
+		return err
+	}
+	if (*entity < 0) == (*cell == "") {
+		return usagef("give exactly one of -entity and -cell")
+	}
+	if *tick < 0 {
+		return usagef("-tick must not be negative")
+	}
+
+	world, _, err := wf.load()
+	if err != nil {
+		return err
+	}
+
+	var vec ecoscript.Vector
+	if *cell != "" {
+		if vec, err = parseCell(world, *cell); err != nil {
+			return err
+		}
+	}
+
+	advance(world, *tick)
+
+	if *entity >= 0 {
+		ent, vec, ok := world.Find(ecoscript.EntityID(*entity))
+		if !ok {
+			return fmt.Errorf("no entity #%d at tick %d", *entity, world.Clock().Ticks())
+		}
+		printLines(tui.DescribeEntity(world, ent, vec))
+		return nil
+	}
+	printLines(tui.DescribeCell(world, vec))
+	return nil
+}
+
+func parseCell(world *ecoscript.World, s string) (vec ecoscript.Vector, err error) {
+	parts := strings.Split(s, ",")
+	if len(parts) < 2 || len(parts) > 3 {
+		return vec, usagef("cell '%s' should be X,Y or X,Y,LAYER", s)
+	}
+	x, errX := strconv.Atoi(strings.TrimSpace(parts[0]))
+	y, errY := strconv.Atoi(strings.TrimSpace(parts[1]))
+	if errX != nil || errY != nil {
+		return vec, usagef("cell '%s' should be X,Y or X,Y,LAYER", s)
+	}
+	z := 0
+	if len(parts) == 3 {
+		if z, err = layerIndex(world, strings.TrimSpace(parts[2])); err != nil {
+			return
+		}
+	}
+	vec = ecoscript.Vec(x, y, z)
+	if !world.InBounds(vec) {
+		return vec, usagef("cell (%d,%d) is out of bounds", x, y)
+	}
+	return vec, nil
+}
+
+func printLines(lines []string) {
+	for _, line := range lines {
+		fmt.Println(line)
+	}
+}
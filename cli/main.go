@@ -0,0 +1,335 @@
+// Package cli implements the ecoscript command, so that programs can build
+// it with their own Extensions linked in:
+//
+//	package main
+//
+//	import (
+//		"github.com/dustinrohde/ecoscript/cli"
+//		_ "example.com/herd"
+//	)
+//
+//	func main() {
+//		cli.Main()
+//	}
+package cli
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/dustinrohde/ecoscript"
+	"github.com/pkg/errors"
+)
+
+type command struct {
+	name    string
+	usage   string
+	summary string
+	run     func(flags *flag.FlagSet, args []string) error
+}
+
+var commands []*command
+
+func init() {
+	commands = []*command{
+		{"run", "[flags]", "run a Mapfile and show it as it plays out", run},
+		{"validate", "[flags] MAPFILE...", "check Mapfiles for errors and likely mistakes", validate},
+		{"render", "[flags]", "print the world as it is at a given tick, or write it to a PNG or GIF", render},
+		{"stats", "[flags]", "run a Mapfile without showing it and write statistics", stats},
+		{"batch", "[flags]", "run a Mapfile as fast as possible until a stop condition is met", batch},
+		{"sweep", "[flags] SPEC", "run a parameter sweep experiment and write a CSV report", sweep},
+		{"inspect", "[flags]", "describe an entity or cell at a given tick", inspect},
+		{"serve", "[flags]", "run a Mapfile and serve it over an HTTP/JSON API", serve},
+		{"record", "[flags]", "record a run for replaying later", record},
+		{"replay", "[flags] RECORDING", "replay a recording and check that it reproduces", replay},
+		{"diff", "RECORDING RECORDING", "find where two recordings first differ", diff},
+	}
+}
+
+// Exit codes.
+const (
+	exitOK    = 0
+	exitError = 1
+	exitUsage = 2
+)
+
+// usageError is returned by commands that were invoked incorrectly.
+type usageError struct {
+	msg string
+}
+
+func (e usageError) Error() string {
+	return e.msg
+}
+
+func usagef(format string, args ...interface{}) error {
+	return usageError{fmt.Sprintf(format, args...)}
+}
+
+// silentError carries an exit code for a failure that a command has already
+// reported.
+type silentError struct {
+	code int
+}
+
+func (e silentError) Error() string {
+	return fmt.Sprintf("exit status %d", e.code)
+}
+
+// Main runs the ecoscript command with the arguments of the program and
+// exits. A program that links in Extensions can call it to be the ecoscript
+// command with those Extensions.
+func Main() {
+	os.Exit(dispatch(os.Args[1:], os.Stderr))
+}
+
+func dispatch(args []string, stderr io.Writer) int {
+	if len(args) == 0 || args[0] == "help" || args[0] == "-h" || args[0] == "--help" {
+		printUsage(stderr)
+		if len(args) == 0 {
+			return exitUsage
+		}
+		return exitOK
+	}
+
+	var cmd *command
+	for _, c := range commands {
+		if c.name == args[0] {
+			cmd = c
+		}
+	}
+	if cmd == nil {
+		fmt.Fprintf(stderr, "ecoscript: unknown command '%s'\n", args[0])
+		printUsage(stderr)
+		return exitUsage
+	}
+
+	flags := flag.NewFlagSet("ecoscript "+cmd.name, flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	flags.Usage = func() {
+		fmt.Fprintf(stderr, "usage: ecoscript %s %s\n\n%s.\n\n", cmd.name, cmd.usage, capitalize(cmd.summary))
+		flags.PrintDefaults()
+	}
+
+	err := cmd.run(flags, args[1:])
+	if err == nil {
+		return exitOK
+	}
+	switch cause := errors.Cause(err).(type) {
+	case silentError:
+		return cause.code
+	case usageError:
+		fmt.Fprintf(stderr, "ecoscript %s: %s\n", cmd.name, cause)
+		flags.Usage()
+		return exitUsage
+	}
+	switch errors.Cause(err) {
+	case flag.ErrHelp:
+		return exitOK
+	case errFlags:
+		return exitUsage
+	}
+	fmt.Fprintf(stderr, "ecoscript %s: %s\n", cmd.name, err)
+	return exitError
+}
+
+func printUsage(w io.Writer) {
+	fmt.Fprintln(w, "usage: ecoscript COMMAND [flags]")
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "commands:")
+	for _, cmd := range commands {
+		fmt.Fprintf(w, "  %-10s %s\n", cmd.name, cmd.summary)
+	}
+	if names := ecoscript.Extensions(); len(names) > 0 {
+		fmt.Fprintln(w)
+		fmt.Fprintf(w, "extensions: %s\n", strings.Join(names, ", "))
+	}
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "Run 'ecoscript COMMAND -h' for help on a command.")
+}
+
+func capitalize(s string) string {
+	if s == "" {
+		return s
+	}
+	return strings.ToUpper(s[:1]) + s[1:]
+}
+
+// errFlags is returned when flags fail to parse. The flag package has
+// already reported the problem.
+var errFlags = errors.New("invalid flags")
+
+// parseFlags parses flags and checks the number of positional arguments.
+func parseFlags(flags *flag.FlagSet, args []string, minArgs, maxArgs int) error {
+	if err := flags.Parse(args); err != nil {
+		if err == flag.ErrHelp {
+			return err
+		}
+		return errFlags
+	}
+	n := flags.NArg()
+	switch {
+	case n < minArgs && minArgs == maxArgs:
+		return usagef("wrong number of arguments: want %d, got %d", minArgs, n)
+	case n < minArgs:
+		return usagef("wrong number of arguments: want at least %d, got %d", minArgs, n)
+	case maxArgs >= 0 && n > maxArgs:
+		return usagef("unexpected argument '%s'", flags.Arg(maxArgs))
+	}
+	return nil
+}
+
+// worldFlags are the flags shared by commands that run a Mapfile.
+type worldFlags struct {
+	mapfile string
+	seed    int64
+}
+
+func addWorldFlags(flags *flag.FlagSet) *worldFlags {
+	wf := new(worldFlags)
+	flags.StringVar(&wf.mapfile, "mapfile", "examples/Mapfile", "the Mapfile to run")
+	flags.Int64Var(&wf.seed, "seed", 0, "seed for the random source, or a random seed if 0")
+	return wf
+}
+
+// load parses the Mapfile and builds a seeded World from it, returning the
+// seed it used.
+func (wf *worldFlags) load() (*ecoscript.World, int64, error) {
+	mapfile, err := ecoscript.ParseMapfile(wf.mapfile)
+	if err != nil {
+		return nil, 0, err
+	}
+	return wf.loadFrom(mapfile)
+}
+
+// loadFrom builds a seeded World from a parsed Mapfile and returns the seed
+// it used. A random seed is chosen if none was given.
+func (wf *worldFlags) loadFrom(mapfile *ecoscript.Mapfile) (*ecoscript.World, int64, error) {
+	seed := wf.seed
+	if seed == 0 {
+		seed = time.Now().UnixNano()
+	}
+	world, err := mapfile.ToWorld()
+	if err != nil {
+		return nil, 0, err
+	}
+	world.Seed(seed)
+	return world, seed, nil
+}
+
+// logFailures writes the errors of scripts that fail as the World runs to
+// stderr.
+func logFailures(world *ecoscript.World) {
+	filter := ecoscript.Filter{Types: []ecoscript.EventType{ecoscript.EventFailed}}
+	world.Events().Subscribe(filter, ecoscript.LogEvents(os.Stderr))
+}
+
+// displayFlags are the flags shared by commands that draw Layers.
+type displayFlags struct {
+	color  string
+	shade  bool
+	legend bool
+	heat   string
+	window int
+}
+
+func addDisplayFlags(flags *flag.FlagSet) *displayFlags {
+	df := new(displayFlags)
+	flags.StringVar(&df.color, "color", "auto", "color mode: auto, none, 16, 256 or truecolor")
+	flags.BoolVar(&df.shade, "shade", false, "shade entities by their energy")
+	flags.BoolVar(&df.legend, "legend", false, "show a legend of entities beside the map, as if the Mapfile's display_legend were set")
+	flags.StringVar(&df.heat, "heatmap", "", "color cells by density, energy, visits, deaths or field:NAME instead of showing entities")
+	flags.IntVar(&df.window, "window", 0, "count visits and deaths over the last given number of ticks, or all of them if 0")
+	return df
+}
+
+// options returns the DisplayOptions for drawing the World to stdout. In the
+// auto color mode, color is used only if stdout is a terminal.
+func (df *displayFlags) options(world *ecoscript.World) (opts ecoscript.DisplayOptions, err error) {
+	if df.color == "auto" {
+		opts.Mode = ecoscript.DetectColorMode(isTerminal(os.Stdout))
+	} else if opts.Mode, err = ecoscript.ParseColorMode(df.color); err != nil {
+		return opts, usagef("%s", err)
+	}
+	opts.Terrain = true
+	opts.ShadeEnergy = df.shade
+	opts.EmptyTile = world.EmptyTile()
+	opts.Legend = df.legend || world.DisplayLegend()
+	if df.window < 0 {
+		return opts, usagef("-window must not be negative")
+	}
+	return opts, nil
+}
+
+// heatmap creates the Heatmap to draw the World with, if any, and a function
+// to stop it listening to the World. Create it before ticking the World, so
+// that visits and deaths are counted.
+func (df *displayFlags) heatmap(world *ecoscript.World) (ecoscript.Heatmap, func(), error) {
+	if df.heat == "" {
+		return nil, func() {}, nil
+	}
+	h, err := ecoscript.ParseHeatmap(world, df.heat, df.window)
+	if err != nil {
+		return nil, nil, usagef("%s", err)
+	}
+	if events, ok := h.(*ecoscript.EventHeatmap); ok {
+		return h, events.Close, nil
+	}
+	return h, func() {}, nil
+}
+
+// advance ticks the World until the given tick.
+func advance(world *ecoscript.World, tick int) {
+	for world.Clock().Ticks() < tick {
+		world.Tick()
+	}
+}
+
+// layerIndex finds a Layer by name or Z index.
+func layerIndex(world *ecoscript.World, layer string) (int, error) {
+	if z, ok := world.LayerIndex(layer); ok {
+		return z, nil
+	}
+	z, err := strconv.Atoi(layer)
+	if err != nil || z < 0 || z >= world.Depth() {
+		return 0, usagef("no layer '%s'", layer)
+	}
+	return z, nil
+}
+
+func parseEventFilter(types string) (filter ecoscript.Filter, err error) {
+	if types == "all" {
+		return
+	}
+	for _, name := range strings.Split(types, ",") {
+		typ, ok := ecoscript.ParseEventType(strings.TrimSpace(name))
+		if !ok {
+			err = usagef("unknown event type '%s'", name)
+			return
+		}
+		filter.Types = append(filter.Types, typ)
+	}
+	return
+}
+
+// create opens a file for writing, or returns stdout if the path is empty
+// or "-".
+func create(path string) (io.WriteCloser, error) {
+	if path == "" || path == "-" {
+		return nopCloser{os.Stdout}, nil
+	}
+	return os.Create(path)
+}
+
+type nopCloser struct {
+	io.Writer
+}
+
+func (nopCloser) Close() error {
+	return nil
+}
@@ -0,0 +1,57 @@
+package cli_test
+
+import (
+	"bytes"
+
+	. "github.com/dustinrohde/ecoscript/cli"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Dispatch", func() {
+	var stderr *bytes.Buffer
+
+	BeforeEach(func() {
+		stderr = new(bytes.Buffer)
+	})
+
+	It("should exit with 0 when asked for help", func() {
+		Expect(Dispatch([]string{"-h"}, stderr)).To(Equal(0))
+		Expect(stderr.String()).To(ContainSubstring("usage: ecoscript COMMAND"))
+
+		stderr.Reset()
+		Expect(Dispatch([]string{"batch", "-h"}, stderr)).To(Equal(0))
+		Expect(stderr.String()).To(ContainSubstring("usage: ecoscript batch"))
+	})
+
+	It("should exit with 1 when a command fails", func() {
+		Expect(Dispatch([]string{"batch", "-mapfile", "no/such/Mapfile"}, stderr)).To(Equal(1))
+		Expect(stderr.String()).To(ContainSubstring("ecoscript batch:"))
+	})
+
+	It("should exit with 2 for unknown commands", func() {
+		Expect(Dispatch([]string{"frobnicate"}, stderr)).To(Equal(2))
+		Expect(stderr.String()).To(ContainSubstring("unknown command 'frobnicate'"))
+	})
+
+	It("should exit with 2 for bad flags and arguments", func() {
+		Expect(Dispatch([]string{"batch", "-nosuchflag"}, stderr)).To(Equal(2))
+		Expect(stderr.String()).To(ContainSubstring("nosuchflag"))
+
+		stderr.Reset()
+		Expect(Dispatch([]string{"batch", "-until", "tick >="}, stderr)).To(Equal(2))
+
+		stderr.Reset()
+		Expect(Dispatch([]string{"batch", "-mapfile", "../examples/Mapfile", "-until", "extinct: nosuchspecies"}, stderr)).To(Equal(2))
+		Expect(stderr.String()).To(ContainSubstring("unknown species 'nosuchspecies'"))
+
+		stderr.Reset()
+		Expect(Dispatch([]string{"diff", "only-one"}, stderr)).To(Equal(2))
+		Expect(stderr.String()).To(ContainSubstring("wrong number of arguments"))
+	})
+
+	It("should exit with 2 when run without a command", func() {
+		Expect(Dispatch(nil, stderr)).To(Equal(2))
+	})
+})
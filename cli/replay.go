@@ -0,0 +1,152 @@
+package cli
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/dustinrohde/ecoscript"
+	"github.com/pkg/errors"
+)
+
+// record runs a Mapfile for a number of ticks and writes a recording.
+func record(flags *flag.FlagSet, args []string) error {
+	wf := addWorldFlags(flags)
+	ticks := flags.Int("ticks", 100, "number of ticks to record")
+	interval := flags.Int("checkpoint", 50, "number of ticks between checkpoints, which wait for activities in progress to finish")
+	outPath := flags.String("o", "", "write the recording to the given file instead of stdout")
+	scheduler := flags.String("scheduler", "serial", "how to tick entities: serial, parallel, sparse or discrete")
+	workers := flags.Int("workers", 0, "number of goroutines for the parallel scheduler (default: one per CPU)")
+	if err := parseFlags(flags, args, 0, 0); err != nil {
+		return err
+	}
+	if *ticks < 1 {
+		return usagef("-ticks must be positive")
+	}
+	if *interval < 1 {
+		return usagef("-checkpoint must be positive")
+	}
+	if *workers < 0 {
+		return usagef("-workers must not be negative")
+	}
+	sched, err := newScheduler(*scheduler, *workers)
+	if err != nil {
+		return err
+	}
+
+	world, seed, err := wf.load()
+	if err != nil {
+		return err
+	}
+	world.SetScheduler(sched)
+	logFailures(world)
+	out, err := create(*outPath)
+	if err != nil {
+		return err
+	}
+	defer out.Close()
+
+	recorder, err := ecoscript.NewRecorder(out, world, seed, *interval)
+	if err != nil {
+		return err
+	}
+	defer recorder.Close()
+
+	for i := 0; i < *ticks; i++ {
+		if err := recorder.Tick(); err != nil {
+			return err
+		}
+	}
+	return out.Close()
+}
+
+// replay plays back a recording, checking that it reproduces the recorded
+// hashes.
+func replay(flags *flag.FlagSet, args []string) error {
+	to := flags.Int("to", -1, "jump to the given tick and show the world")
+	step := flags.Bool("step", false, "show the world and events after every tick")
+	layer := flags.String("layer", "0", "the layer to show, by name or index")
+	if err := parseFlags(flags, args, 1, 1); err != nil {
+		return err
+	}
+
+	rec, err := readRecording(flags.Arg(0))
+	if err != nil {
+		return err
+	}
+	player, err := ecoscript.NewPlayer(rec)
+	if err != nil {
+		return err
+	}
+	z, err := layerIndex(player.World(), *layer)
+	if err != nil {
+		return err
+	}
+
+	switch {
+	case *to >= 0:
+		if err := player.Seek(*to); err != nil {
+			return err
+		}
+		fmt.Printf("tick %d\n", player.Tick())
+		fmt.Print(player.World().Layer(z).Display())
+
+	case *step:
+		for player.Tick() < rec.End() {
+			frame, err := player.Step()
+			if err != nil {
+				return err
+			}
+			fmt.Printf("tick %d (%s)\n", frame.Tick, frame.Hash)
+			fmt.Println(player.World().Layer(z).Display())
+			for _, ev := range frame.Events {
+				fmt.Println(ev)
+			}
+		}
+
+	default:
+		if err := player.Verify(); err != nil {
+			return err
+		}
+		fmt.Printf("replayed ticks %d-%d: ok\n", rec.Start(), rec.End())
+	}
+	return nil
+}
+
+// diff compares two recordings and reports where they first differ. It
+// exits with status 1 if they do.
+func diff(flags *flag.FlagSet, args []string) error {
+	if err := parseFlags(flags, args, 2, 2); err != nil {
+		return err
+	}
+
+	a, err := readRecording(flags.Arg(0))
+	if err != nil {
+		return err
+	}
+	b, err := readRecording(flags.Arg(1))
+	if err != nil {
+		return err
+	}
+	divergence, err := ecoscript.DiffRecordings(a, b)
+	if err != nil {
+		return err
+	}
+	if divergence == nil {
+		fmt.Println("no divergence")
+		return nil
+	}
+	fmt.Println(divergence)
+	return silentError{exitError}
+}
+
+func readRecording(path string) (*ecoscript.Recording, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	rec, err := ecoscript.ReadRecording(file)
+	return rec, errors.Wrap(err, path)
+}
@@ -0,0 +1,176 @@
+package cli
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/dustinrohde/ecoscript"
+	"github.com/dustinrohde/ecoscript/tui"
+)
+
+// run plays a Mapfile. In a terminal it runs the interactive UI; otherwise it
+// prints a Layer after every tick.
+func run(flags *flag.FlagSet, args []string) error {
+	wf := addWorldFlags(flags)
+	ticks := flags.Int("ticks", 0, "stop after the given number of ticks, or never if 0 (plain output only)")
+	speed := flags.Duration("speed", tui.DefaultDelay, "time to wait between ticks")
+	layer := flags.String("layer", "0", "the layer to show, by name or index")
+	events := flags.String("events", "", "log events of the given comma-separated types, or \"all\"")
+	plain := flags.Bool("plain", false, "print each tick instead of running the interactive UI")
+	paused := flags.Bool("paused", false, "start the interactive UI paused")
+	df := addDisplayFlags(flags)
+	if err := parseFlags(flags, args, 0, 0); err != nil {
+		return err
+	}
+	if *ticks < 0 {
+		return usagef("-ticks must not be negative")
+	}
+
+	world, _, err := wf.load()
+	if err != nil {
+		return err
+	}
+	z, err := layerIndex(world, *layer)
+	if err != nil {
+		return err
+	}
+	opts, err := df.options(world)
+	if err != nil {
+		return err
+	}
+	heatmap, closeHeatmap, err := df.heatmap(world)
+	if err != nil {
+		return err
+	}
+	defer closeHeatmap()
+	opts.Heatmap = heatmap
+	var filter *ecoscript.Filter
+	if *events != "" {
+		f, err := parseEventFilter(*events)
+		if err != nil {
+			return err
+		}
+		filter = &f
+	}
+
+	if !*plain && isTerminal(os.Stdin) && isTerminal(os.Stdout) {
+		restore, err := rawMode()
+		if err != nil {
+			return err
+		}
+		defer restore()
+
+		ui := tui.New(world, os.Stdin, os.Stdout).SetDelay(*speed).SetLayer(z).SetPaused(*paused).SetDisplay(opts)
+		if filter != nil {
+			ui.LogEvents(*filter)
+		}
+		return ui.Run()
+	}
+
+	if filter != nil {
+		world.Events().Subscribe(*filter, ecoscript.LogEvents(os.Stderr))
+	}
+	for *ticks == 0 || world.Clock().Ticks() < *ticks {
+		fmt.Println(world.Layer(z).DisplayWith(opts))
+		world.Tick()
+		time.Sleep(*speed)
+	}
+	fmt.Println(world.Layer(z).DisplayWith(opts))
+	return nil
+}
+
+// render prints the World as it is at a given tick, or writes it to an
+// image.
+func render(flags *flag.FlagSet, args []string) error {
+	wf := addWorldFlags(flags)
+	tick := flags.Int("tick", 0, "the tick to render")
+	layer := flags.String("layer", "", "the comma-separated layers to render, by name or index, or all if empty")
+	field := flags.String("field", "", "render the given field instead of entities, or beneath them in color")
+	view := flags.String("view", "layers", "how to show the layers: one after another (layers), stacked (composite), side by side (side), or by a renderer an extension registers")
+	counts := flags.Bool("counts", false, "show the number of entities in each cell of a composite view")
+	df := addDisplayFlags(flags)
+	imf := addImageFlags(flags)
+	if err := parseFlags(flags, args, 0, 0); err != nil {
+		return err
+	}
+	if err := imf.check(); err != nil {
+		return err
+	}
+	if *tick < 0 {
+		return usagef("-tick must not be negative")
+	}
+	renderer, ok := ecoscript.LookupRenderer(*view)
+	if !ok {
+		return usagef("unknown view '%s'", *view)
+	}
+
+	world, _, err := wf.load()
+	if err != nil {
+		return err
+	}
+	layers := make([]int, 0, world.Depth())
+	if *layer == "" {
+		for z := 0; z < world.Depth(); z++ {
+			layers = append(layers, z)
+		}
+	} else {
+		for _, name := range strings.Split(*layer, ",") {
+			z, err := layerIndex(world, strings.TrimSpace(name))
+			if err != nil {
+				return err
+			}
+			layers = append(layers, z)
+		}
+	}
+	if *field != "" && world.Field(*field) == nil {
+		return usagef("no field '%s'", *field)
+	}
+	opts, err := df.options(world)
+	if err != nil {
+		return err
+	}
+	if *field != "" {
+		opts.Field = world.Field(*field)
+	}
+	heatmap, closeHeatmap, err := df.heatmap(world)
+	if err != nil {
+		return err
+	}
+	defer closeHeatmap()
+	opts.Heatmap = heatmap
+
+	advance(world, *tick)
+
+	if imf.output != "" {
+		if *layer == "" {
+			layers = nil
+		}
+		return imf.write(world, layers, heatmap)
+	}
+
+	switch {
+	case *view == "composite":
+		fmt.Print(world.Composite(ecoscript.CompositeOptions{
+			DisplayOptions: opts,
+			Layers:         layers,
+			Counts:         *counts,
+		}))
+		return nil
+	case *view == "layers" && *field != "" && heatmap == nil && opts.Mode == ecoscript.Monochrome:
+		for i, z := range layers {
+			if len(layers) > 1 {
+				if i > 0 {
+					fmt.Println()
+				}
+				fmt.Printf("%s (tick %d)\n", world.Layer(z).Name(), world.Clock().Ticks())
+			}
+			fmt.Print(world.Field(*field).Display(z))
+		}
+		return nil
+	}
+	fmt.Print(renderer(world, layers, opts))
+	return nil
+}
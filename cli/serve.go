@@ -0,0 +1,37 @@
+package cli
+
+import (
+	"flag"
+	"fmt"
+	"net/http"
+	"os"
+
+	"github.com/dustinrohde/ecoscript/server"
+)
+
+// serve runs a Mapfile and serves it over HTTP until interrupted.
+func serve(flags *flag.FlagSet, args []string) error {
+	wf := addWorldFlags(flags)
+	addr := flags.String("addr", "localhost:8080", "the address to listen on")
+	speed := flags.Duration("speed", server.DefaultDelay, "time to wait between ticks")
+	paused := flags.Bool("paused", false, "start paused")
+	if err := parseFlags(flags, args, 0, 0); err != nil {
+		return err
+	}
+	if *speed <= 0 {
+		return usagef("-speed must be positive")
+	}
+
+	world, seed, err := wf.load()
+	if err != nil {
+		return err
+	}
+	logFailures(world)
+	srv := server.New(world).SetDelay(*speed).SetPaused(*paused)
+	stop := make(chan struct{})
+	defer close(stop)
+	go srv.Run(stop)
+
+	fmt.Fprintf(os.Stderr, "serving on http://%s (seed %d)\n", *addr, seed)
+	return http.ListenAndServe(*addr, srv)
+}
@@ -0,0 +1,63 @@
+package cli
+
+import (
+	"flag"
+
+	"github.com/dustinrohde/ecoscript"
+)
+
+// stats runs a Mapfile headless and writes statistics.
+func stats(flags *flag.FlagSet, args []string) error {
+	wf := addWorldFlags(flags)
+	ticks := flags.Int("ticks", 1000, "number of ticks to run")
+	interval := flags.Int("interval", 1, "number of ticks between samples")
+	format := flags.String("format", "jsonl", "output format: jsonl or csv")
+	outPath := flags.String("o", "", "write statistics to the given file instead of stdout")
+	if err := parseFlags(flags, args, 0, 0); err != nil {
+		return err
+	}
+	if *ticks < 1 {
+		return usagef("-ticks must be positive")
+	}
+	if *interval < 1 {
+		return usagef("-interval must be positive")
+	}
+	if *format != "jsonl" && *format != "csv" {
+		return usagef("unknown format '%s'", *format)
+	}
+
+	world, _, err := wf.load()
+	if err != nil {
+		return err
+	}
+	logFailures(world)
+	out, err := create(*outPath)
+	if err != nil {
+		return err
+	}
+	defer out.Close()
+
+	// JSON Lines are streamed; CSV needs every sample to know its columns.
+	capacity := 1
+	if *format == "csv" {
+		capacity = *ticks / *interval + 1
+	}
+	collector := ecoscript.NewCollector(world, *interval, capacity)
+	defer collector.Close()
+
+	for world.Clock().Ticks() < *ticks {
+		world.Tick()
+		sample, ok := collector.Collect(world)
+		if ok && *format == "jsonl" {
+			if err := ecoscript.WriteJSONLines(out, []ecoscript.Sample{sample}); err != nil {
+				return err
+			}
+		}
+	}
+	if *format == "csv" {
+		if err := ecoscript.WriteCSV(out, collector.Samples()); err != nil {
+			return err
+		}
+	}
+	return out.Close()
+}
@@ -0,0 +1,58 @@
+package cli
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/dustinrohde/ecoscript"
+)
+
+// sweep runs an experiment spec and writes a CSV report of its results.
+func sweep(flags *flag.FlagSet, args []string) error {
+	workers := flags.Int("workers", 0, "number of runs at once (default: the spec's, or one per CPU)")
+	outPath := flags.String("o", "", "write the report to the given file instead of stdout")
+	quiet := flags.Bool("q", false, "don't report progress")
+	if err := parseFlags(flags, args, 1, 1); err != nil {
+		return err
+	}
+
+	exp, err := ecoscript.ParseExperiment(flags.Arg(0))
+	if err != nil {
+		return err
+	}
+	if *workers > 0 {
+		exp.Workers = *workers
+	}
+	if !*quiet {
+		exp.Progress = func(done, total int) {
+			fmt.Fprintf(os.Stderr, "\rrun %d of %d", done, total)
+			if done == total {
+				fmt.Fprintln(os.Stderr)
+			}
+		}
+	}
+
+	results := exp.Run()
+	errs := ecoscript.Errors(results)
+	for _, msg := range errs {
+		fmt.Fprintf(os.Stderr, "error: %s\n", msg)
+	}
+
+	out, err := create(*outPath)
+	if err != nil {
+		return err
+	}
+	defer out.Close()
+	if err := exp.WriteSummariesCSV(out, exp.Summarize(results)); err != nil {
+		return err
+	}
+	if err := out.Close(); err != nil {
+		return err
+	}
+
+	if len(errs) > 0 {
+		return silentError{exitError}
+	}
+	return nil
+}
@@ -0,0 +1,42 @@
+package cli
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/dustinrohde/ecoscript"
+)
+
+// validate parses Mapfiles and reports their errors and likely mistakes.
+func validate(flags *flag.FlagSet, args []string) error {
+	strict := flags.Bool("strict", false, "treat warnings as errors")
+	if err := parseFlags(flags, args, 1, -1); err != nil {
+		return err
+	}
+
+	failed := false
+	for _, path := range flags.Args() {
+		mapfile, err := ecoscript.ParseMapfile(path)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%s: error: %s\n", path, err)
+			failed = true
+			continue
+		}
+
+		warnings := mapfile.Lint()
+		for _, warning := range warnings {
+			fmt.Fprintf(os.Stderr, "%s: warning: %s\n", path, warning)
+		}
+		if len(warnings) > 0 && *strict {
+			failed = true
+			continue
+		}
+		fmt.Printf("%s: ok\n", path)
+	}
+
+	if failed {
+		return silentError{exitError}
+	}
+	return nil
+}
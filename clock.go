@@ -0,0 +1,113 @@
+package ecoscript
+
+import "math"
+
+const (
+	// TraitNocturnal marks Entities that only act at night.
+	TraitNocturnal Trait = "nocturnal"
+
+	// TraitDiurnal marks Entities that only act during the day.
+	TraitDiurnal Trait = "diurnal"
+)
+
+// Clock converts World ticks into hours, days and seasons.
+type Clock struct {
+	ticks int
+
+	TicksPerHour  int `mapstructure:"ticks_per_hour"`
+	HoursPerDay   int `mapstructure:"hours_per_day"`
+	DaysPerSeason int `mapstructure:"days_per_season"`
+	Dawn          int `mapstructure:"dawn"`
+	Dusk          int `mapstructure:"dusk"`
+	Seasons       []*Season
+}
+
+// Season modulates growth and metabolism. Each factor scales the normal
+// amount, so 1 leaves it unchanged.
+type Season struct {
+	Name       string
+	Growth     float64
+	Metabolism float64
+}
+
+// DefaultSeason is used by Clocks that have no Seasons.
+var DefaultSeason = &Season{
+	Name:       "none",
+	Growth:     1,
+	Metabolism: 1,
+}
+
+func NewClock() *Clock {
+	return &Clock{
+		TicksPerHour:  1,
+		HoursPerDay:   24,
+		DaysPerSeason: 30,
+		Dawn:          6,
+		Dusk:          18,
+	}
+}
+
+// Ticks returns the number of ticks that have passed.
+func (c *Clock) Ticks() int {
+	return c.ticks
+}
+
+func (c *Clock) advance() {
+	c.ticks++
+}
+
+// Hour returns the hour of the current day.
+func (c *Clock) Hour() int {
+	return (c.ticks / c.TicksPerHour) % c.HoursPerDay
+}
+
+// Day returns the number of days that have passed.
+func (c *Clock) Day() int {
+	return c.ticks / (c.TicksPerHour * c.HoursPerDay)
+}
+
+// Season returns the current Season, cycling through each in order.
+func (c *Clock) Season() *Season {
+	if len(c.Seasons) == 0 {
+		return DefaultSeason
+	}
+	i := (c.Day() / c.DaysPerSeason) % len(c.Seasons)
+	return c.Seasons[i]
+}
+
+func (c *Clock) hasSeason(name string) bool {
+	for _, season := range c.Seasons {
+		if season.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// IsDay returns true between dawn and dusk.
+func (c *Clock) IsDay() bool {
+	hour := c.Hour()
+	return hour >= c.Dawn && hour < c.Dusk
+}
+
+// Awake returns true if the Entity is active at the current time of day.
+func (c *Clock) Awake(ent *Entity) bool {
+	if ent.HasTrait(TraitNocturnal) {
+		return !c.IsDay()
+	}
+	if ent.HasTrait(TraitDiurnal) {
+		return c.IsDay()
+	}
+	return true
+}
+
+// ScaleGrowth scales an amount of growth by the current Season.
+func (c *Clock) ScaleGrowth(energy int) int {
+	return int(float64(energy) * c.Season().Growth)
+}
+
+// ScaleMetabolism scales an energy cost by the current Season. Costs are
+// rounded up so that they never disappear entirely.
+func (c *Clock) ScaleMetabolism(energy int) int {
+	return int(math.Ceil(float64(energy) * c.Season().Metabolism))
+}
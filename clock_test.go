@@ -0,0 +1,61 @@
+package ecoscript_test
+
+import (
+	. "github.com/dustinrohde/ecoscript"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Clock", func() {
+	var (
+		world *World
+		clock *Clock
+	)
+
+	BeforeEach(func() {
+		world = NewWorld(1, 1, []string{"ground"})
+		clock = &Clock{
+			TicksPerHour:  2,
+			HoursPerDay:   4,
+			DaysPerSeason: 1,
+			Dawn:          1,
+			Dusk:          3,
+			Seasons: []*Season{
+				{Name: "summer", Growth: 2, Metabolism: 1},
+				{Name: "winter", Growth: 0, Metabolism: 1.5},
+			},
+		}
+		world.SetClock(clock)
+	})
+
+	It("should convert ticks to hours, days and seasons", func() {
+		Expect(clock.Hour()).To(Equal(0))
+		Expect(clock.Season().Name).To(Equal("summer"))
+
+		for i := 0; i < 10; i++ {
+			world.Tick()
+		}
+		Expect(clock.Ticks()).To(Equal(10))
+		Expect(clock.Hour()).To(Equal(1))
+		Expect(clock.Day()).To(Equal(1))
+		Expect(clock.Season().Name).To(Equal("winter"))
+		Expect(clock.ScaleGrowth(10)).To(Equal(0))
+		Expect(clock.ScaleMetabolism(3)).To(Equal(5))
+	})
+
+	It("should only wake nocturnal and diurnal Entities at their time of day", func() {
+		owl := NewEntity("owl", "o").AddTraits(TraitNocturnal)
+		lark := NewEntity("lark", "l").AddTraits(TraitDiurnal)
+
+		Expect(clock.IsDay()).To(BeFalse())
+		Expect(clock.Awake(owl)).To(BeTrue())
+		Expect(clock.Awake(lark)).To(BeFalse())
+
+		world.Tick()
+		world.Tick()
+		Expect(clock.IsDay()).To(BeTrue())
+		Expect(clock.Awake(owl)).To(BeFalse())
+		Expect(clock.Awake(lark)).To(BeTrue())
+	})
+})
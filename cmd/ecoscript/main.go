@@ -1,24 +1,9 @@
+// Command ecoscript runs, renders, measures and serves the worlds described
+// by Mapfiles. Run 'ecoscript help' for its commands.
 package main
 
-import (
-	"fmt"
-	"log"
-	"time"
-
-	"github.com/dustinrohde/ecoscript"
-)
+import "github.com/dustinrohde/ecoscript/cli"
 
 func main() {
-	mapfile, err := ecoscript.ParseMapfile("examples/Mapfile")
-	if err != nil {
-		log.Fatal(err)
-	}
-
-	world := mapfile.ToWorld()
-
-	for {
-		fmt.Println(world.Layer(0).Display())
-		world.Tick()
-		time.Sleep(500 * time.Millisecond)
-	}
+	cli.Main()
 }
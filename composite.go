@@ -0,0 +1,214 @@
+package ecoscript
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// CompositeOptions control how Composite draws a World.
+type CompositeOptions struct {
+	DisplayOptions
+
+	// Layers are the Z indexes of the Layers to composite, or all of them
+	// if empty.
+	Layers []int
+
+	// Counts follows each Cell's symbol with the number of Entities beneath
+	// it, if there is more than one.
+	Counts bool
+}
+
+// DrawOrder returns the Z indexes of the World's Layers in the order they are
+// composited, from the top down. Layers with higher DrawOrders come first;
+// otherwise Layers that lie above others come before them, and unrelated
+// Layers come in the order they were declared.
+func (w *World) DrawOrder() []int {
+	height := make([]int, len(w.layers))
+	for z := range w.layers {
+		for below, ok := w.layers[z].Below(); ok; below, ok = w.layers[below].Below() {
+			height[z]++
+		}
+	}
+
+	order := make([]int, len(w.layers))
+	for z := range order {
+		order[z] = z
+	}
+	sort.SliceStable(order, func(i, j int) bool {
+		a, b := w.layers[order[i]], w.layers[order[j]]
+		if a.drawOrder != b.drawOrder {
+			return a.drawOrder > b.drawOrder
+		}
+		return height[order[i]] > height[order[j]]
+	})
+	return order
+}
+
+// Composite draws the World as seen from above, with the Layers stacked in
+// DrawOrder.
+func (w *World) Composite(opts CompositeOptions) string {
+	if opts.Legend {
+		width := w.width
+		if opts.Counts && opts.Heatmap == nil {
+			width *= 2
+		}
+		lines := LegendLines(w.Legend(opts.Layers), opts.Mode)
+		return beside(w.CompositeTiles(opts), width, lines)
+	}
+	var b strings.Builder
+	for _, row := range w.CompositeTiles(opts) {
+		for _, tile := range row {
+			b.WriteString(tile)
+		}
+		b.WriteString("\n")
+	}
+	return b.String()
+}
+
+// CompositeTiles draws each column of Cells as Composite does, row by row.
+//
+// Looking down through a column, an opaque Layer hides everything beneath
+// it. Of the Entities in sight, the highest that isn't walkable is shown, so
+// that a sheep shows over the grass it stands on; if they are all walkable,
+// the highest is shown. The background is that of the highest Cell that has
+// one. A Heatmap is summed over all the composited Layers.
+func (w *World) CompositeTiles(opts CompositeOptions) [][]string {
+	layers := w.compositeLayers(opts.Layers)
+	if len(layers) == 0 {
+		return nil
+	}
+	p := newPainter(opts.DisplayOptions, layers...)
+	var max float64
+	if opts.Heatmap != nil {
+		max = heatMax(opts.Heatmap, layers)
+	}
+
+	tiles := make([][]string, w.height)
+	for y := range tiles {
+		tiles[y] = make([]string, w.width)
+		for x := range tiles[y] {
+			if opts.Heatmap != nil {
+				tiles[y][x] = p.heat(heatSum(opts.Heatmap, layers, x, y), max)
+				continue
+			}
+			top, count, cells := sight(layers, x, y)
+			var background Color
+			for _, cell := range cells {
+				if background = p.background(cell.cell, cell.vec); background != "" {
+					break
+				}
+			}
+			tile := p.tile(top, background)
+			if opts.Counts {
+				tile += stackCount(count)
+			}
+			tiles[y][x] = tile
+		}
+	}
+	return tiles
+}
+
+// visibleCell is a Cell in sight from above, and where it is.
+type visibleCell struct {
+	cell *Cell
+	vec  Vector
+}
+
+// sight looks down through the Cells at X and Y of the given Layers, which
+// are in DrawOrder, and returns the Entity to show there, the number of
+// Entities in sight, and the Cells in sight from the top down.
+func sight(layers []*Layer, x, y int) (top *Entity, count int, cells []visibleCell) {
+	var occupier, walkable *Entity
+	for _, layer := range layers {
+		cell := layer.Cell(Vec2D(x, y))
+		cells = append(cells, visibleCell{cell, Vec(x, y, layer.z)})
+		ents := cell.Entities()
+		count += len(ents)
+		for i := len(ents) - 1; i >= 0; i-- {
+			if occupier == nil && !ents[i].Attrs.Walkable {
+				occupier = ents[i]
+			}
+			if walkable == nil && ents[i].Attrs.Walkable {
+				walkable = ents[i]
+			}
+		}
+		if layer.opaque {
+			break
+		}
+	}
+
+	top = occupier
+	if top == nil {
+		top = walkable
+	}
+	return top, count, cells
+}
+
+// compositeLayers returns the given Layers, or all of them, in DrawOrder.
+func (w *World) compositeLayers(zs []int) []*Layer {
+	include := make(map[int]bool)
+	for _, z := range zs {
+		include[z] = true
+	}
+	layers := make([]*Layer, 0, len(w.layers))
+	for _, z := range w.DrawOrder() {
+		if len(zs) == 0 || include[z] {
+			layers = append(layers, w.layers[z])
+		}
+	}
+	return layers
+}
+
+// stackCount draws the number of Entities in a column of Cells in a single
+// character: blank for one or none, a digit up to 9 and "+" beyond.
+func stackCount(n int) string {
+	switch {
+	case n <= 1:
+		return " "
+	case n <= 9:
+		return strconv.Itoa(n)
+	}
+	return "+"
+}
+
+// SideBySide draws the given Layers next to each other, each under its
+// name, so that every Layer of a World can be seen at once. The legend, if
+// any, is of all of them.
+func (w *World) SideBySide(zs []int, opts DisplayOptions) string {
+	const gap = "  "
+
+	names := make([]string, len(zs))
+	tiles := make([][][]string, len(zs))
+	for i, z := range zs {
+		layer := w.Layer(z)
+		tiles[i] = layer.Tiles(opts)
+
+		name := layer.Name()
+		if len(name) > layer.Width() {
+			name = name[:layer.Width()]
+		}
+		names[i] = name + strings.Repeat(" ", layer.Width()-len(name))
+	}
+
+	rows := make([][]string, w.height)
+	for y := range rows {
+		for i := range zs {
+			if i > 0 {
+				rows[y] = append(rows[y], gap)
+			}
+			rows[y] = append(rows[y], tiles[i][y]...)
+		}
+	}
+	var lines []string
+	if opts.Legend {
+		lines = LegendLines(w.Legend(zs), opts.Mode)
+	}
+
+	var b strings.Builder
+	b.WriteString(strings.TrimRight(strings.Join(names, gap), " "))
+	b.WriteString("\n")
+	width := len(zs)*(w.width+len(gap)) - len(gap)
+	b.WriteString(beside(rows, width, lines))
+	return b.String()
+}
@@ -0,0 +1,62 @@
+package ecoscript_test
+
+import (
+	. "github.com/dustinrohde/ecoscript"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Composite", func() {
+	var world *World
+
+	add := func(name, symbol string, walkable bool, vec Vector) {
+		ent := NewEntity(name, symbol).AddAttributes(&Attributes{Walkable: walkable, Energy: 10})
+		exec, ok := world.Add(ent, vec)
+		Expect(ok).To(BeTrue())
+		exec()
+	}
+
+	BeforeEach(func() {
+		world = NewWorld(4, 1, []string{"underground", "canopy", "ground"})
+		Expect(world.Relate("canopy", "ground")).To(Succeed())
+		Expect(world.Relate("ground", "underground")).To(Succeed())
+
+		add("grass", ",", true, Vec(0, 0, 2))
+		add("sheep", "&", false, Vec(0, 0, 2))
+		add("leaves", "%", true, Vec(1, 0, 1))
+		add("grass", ",", true, Vec(1, 0, 2))
+		add("worm", "~", true, Vec(2, 0, 0))
+		add("mole", "m", false, Vec(3, 0, 0))
+		add("grass", ",", true, Vec(3, 0, 2))
+	})
+
+	It("should order Layers as they lie above one another", func() {
+		Expect(world.DrawOrder()).To(Equal([]int{1, 2, 0}))
+		world.Layer(0).SetDrawOrder(1)
+		Expect(world.DrawOrder()).To(Equal([]int{0, 1, 2}))
+	})
+
+	It("should show occupiers over walkable Entities", func() {
+		Expect(world.Composite(CompositeOptions{})).To(Equal("&%~m\n"))
+	})
+
+	It("should hide Layers beneath an opaque Layer", func() {
+		world.Layer(2).SetOpaque(true)
+		Expect(world.Composite(CompositeOptions{})).To(Equal("&% ,\n"))
+	})
+
+	It("should composite only the given Layers", func() {
+		Expect(world.Composite(CompositeOptions{Layers: []int{0, 2}})).To(Equal("&,~m\n"))
+	})
+
+	It("should show stack counts", func() {
+		Expect(world.Composite(CompositeOptions{Counts: true})).To(Equal("&2%2~ m2\n"))
+	})
+
+	It("should show Layers side by side", func() {
+		Expect(world.SideBySide([]int{2, 0}, DisplayOptions{})).To(Equal(
+			"grou  unde\n" +
+				"&, ,    ~m\n"))
+	})
+})
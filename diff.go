@@ -0,0 +1,194 @@
+package ecoscript
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/pkg/errors"
+)
+
+// Divergence describes where two Worlds, or two recorded runs, first differ.
+type Divergence struct {
+	Tick int
+
+	// Cell and Entity are the first Cell that differs, in Z, Y, X order,
+	// and the Entity in it with the lowest ID that differs. Either may be
+	// nil if the difference lies elsewhere.
+	Cell   *Vector
+	Entity *EntityID
+
+	Reason string
+}
+
+func (d *Divergence) String() string {
+	s := fmt.Sprintf("tick %d", d.Tick)
+	if d.Cell != nil {
+		s += fmt.Sprintf(", cell (%d,%d,%d)", d.Cell.X, d.Cell.Y, d.Cell.Z)
+	}
+	if d.Entity != nil {
+		s += fmt.Sprintf(", entity #%d", *d.Entity)
+	}
+	return s + ": " + d.Reason
+}
+
+// CompareWorlds returns where two Worlds differ, or nil if they have the
+// same Digest.
+func CompareWorlds(a, b *World) *Divergence {
+	if a.Digest() == b.Digest() {
+		return nil
+	}
+	d := &Divergence{Tick: a.Clock().Ticks()}
+
+	if a.Width() != b.Width() || a.Height() != b.Height() || a.Depth() != b.Depth() {
+		d.Reason = fmt.Sprintf("size differs: %dx%dx%d and %dx%dx%d",
+			a.Width(), a.Height(), a.Depth(), b.Width(), b.Height(), b.Depth())
+		return d
+	}
+
+	for z := 0; z < a.Depth(); z++ {
+		if a.LayerDigest(z) == b.LayerDigest(z) {
+			continue
+		}
+		for y := 0; y < a.Height(); y++ {
+			for x := 0; x < a.Width(); x++ {
+				vec := Vec(x, y, z)
+				if a.CellDigest(vec) != b.CellDigest(vec) {
+					d.Cell = &vec
+					compareCells(d, a.Cell(vec), b.Cell(vec))
+					return d
+				}
+			}
+		}
+	}
+
+	switch {
+	case a.Clock().Ticks() != b.Clock().Ticks():
+		d.Reason = fmt.Sprintf("clocks differ: %d and %d ticks", a.Clock().Ticks(), b.Clock().Ticks())
+	case len(a.fields) != len(b.fields):
+		d.Reason = "fields differ"
+	case len(a.weather) != len(b.weather):
+		d.Reason = "weather differs"
+	default:
+		for i := range a.fields {
+			if a.fields[i].digest() != b.fields[i].digest() {
+				d.Reason = fmt.Sprintf("field '%s' differs", a.fields[i].Name)
+				return d
+			}
+		}
+		for i := range a.weather {
+			if a.weather[i].digest(i) != b.weather[i].digest(i) {
+				d.Reason = fmt.Sprintf("weather %d (%s) differs", i, a.weather[i].Kind)
+				return d
+			}
+		}
+		d.Reason = "layers, clocks or random sources differ"
+	}
+	return d
+}
+
+// compareCells fills in the Entity and Reason of a Divergence between two
+// Cells that differ.
+func compareCells(d *Divergence, a, b *Cell) {
+	inA := make(map[EntityID]Digest)
+	inB := make(map[EntityID]Digest)
+	ids := make([]int, 0)
+	for _, ent := range a.Entities() {
+		inA[ent.ID()] = EntityDigest(ent)
+		ids = append(ids, int(ent.ID()))
+	}
+	for _, ent := range b.Entities() {
+		inB[ent.ID()] = EntityDigest(ent)
+		if _, ok := inA[ent.ID()]; !ok {
+			ids = append(ids, int(ent.ID()))
+		}
+	}
+	sort.Ints(ids)
+
+	for _, i := range ids {
+		id := EntityID(i)
+		digestA, okA := inA[id]
+		digestB, okB := inB[id]
+		switch {
+		case !okB:
+			d.Reason = "entity is only in the first"
+		case !okA:
+			d.Reason = "entity is only in the second"
+		case digestA != digestB:
+			d.Reason = "entity state differs"
+		default:
+			continue
+		}
+		d.Entity = &id
+		return
+	}
+	d.Reason = "terrain differs"
+}
+
+// DiffRecordings finds the first tick at which two recorded runs differ,
+// then replays both up to it to find the first Cell and Entity that differ.
+// If the replays agree, the difference didn't come from the recordings'
+// initial state, so the first checkpoint both runs took after it is
+// compared instead. DiffRecordings returns nil if the runs agree for as
+// long as both last.
+func DiffRecordings(a, b *Recording) (*Divergence, error) {
+	if a.Start() != b.Start() {
+		return nil, errors.Errorf("recordings start at different ticks: %d and %d", a.Start(), b.Start())
+	}
+
+	playerA, err := NewPlayer(a)
+	if err != nil {
+		return nil, errors.Wrap(err, "first recording")
+	}
+	playerB, err := NewPlayer(b)
+	if err != nil {
+		return nil, errors.Wrap(err, "second recording")
+	}
+	if d := CompareWorlds(playerA.World(), playerB.World()); d != nil {
+		return d, nil
+	}
+
+	tick := -1
+	for t := a.Start() + 1; t <= a.End() && t <= b.End(); t++ {
+		frameA, _ := a.Frame(t)
+		frameB, _ := b.Frame(t)
+		if frameA.Hash != frameB.Hash {
+			tick = t
+			break
+		}
+	}
+	if tick == -1 {
+		return nil, nil
+	}
+
+	// Replay without verifying: a run that diverged from its twin may not
+	// reproduce its own recording either.
+	if err := playerA.seek(tick, false); err != nil {
+		return nil, errors.Wrap(err, "first recording")
+	}
+	if err := playerB.seek(tick, false); err != nil {
+		return nil, errors.Wrap(err, "second recording")
+	}
+	if d := CompareWorlds(playerA.World(), playerB.World()); d != nil {
+		return d, nil
+	}
+
+	for t := tick; t <= a.End() && t <= b.End(); t++ {
+		frameA, _ := a.Frame(t)
+		frameB, _ := b.Frame(t)
+		if frameA.Checkpoint == nil || frameB.Checkpoint == nil {
+			continue
+		}
+		if err := playerA.world.Restore(frameA.Checkpoint); err != nil {
+			return nil, errors.Wrap(err, "first recording")
+		}
+		if err := playerB.world.Restore(frameB.Checkpoint); err != nil {
+			return nil, errors.Wrap(err, "second recording")
+		}
+		if d := CompareWorlds(playerA.World(), playerB.World()); d != nil {
+			d.Reason = fmt.Sprintf("%s (replays agree; first differing checkpoint is at tick %d)", d.Reason, t)
+			d.Tick = tick
+			return d, nil
+		}
+	}
+	return &Divergence{Tick: tick, Reason: "recorded hashes differ, but replays and checkpoints agree"}, nil
+}
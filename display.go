@@ -1,9 +1,56 @@
 package ecoscript
 
+import (
+	"strings"
+)
+
 const (
 	blankSymbol = " "
+
+	// fieldRamp shades Field values from lowest to highest.
+	fieldRamp = " .:-=+*#%@"
+
+	// defaultFieldColor is the background of the strongest Cells of a Field
+	// that has no Color of its own.
+	defaultFieldColor Color = "#4080ff"
+
+	// minShade is how dark the Symbol of an Entity with no energy is drawn,
+	// when shading by energy.
+	minShade = 0.35
 )
 
+// DisplayOptions control how DisplayWith draws a Layer.
+type DisplayOptions struct {
+	// Mode is the kind of color to draw with. In Monochrome, Layers are
+	// drawn as they are by Display, but for EmptyTile and Legend.
+	Mode ColorMode
+
+	// EmptyTile is the symbol drawn in Cells with no Entities, or a blank if
+	// empty.
+	EmptyTile string
+
+	// Legend draws a legend of the symbols in the Layer, the names of their
+	// Entities and how many there are beside it.
+	Legend bool
+
+	// Terrain colors the background of each Cell by the Color of its
+	// Terrain.
+	Terrain bool
+
+	// Field, if set, colors the background of each Cell by the strength of
+	// the Field there instead.
+	Field *Field
+
+	// ShadeEnergy darkens the Symbols of Entities with less energy than the
+	// most energetic Entity of the same name in the Layer.
+	ShadeEnergy bool
+
+	// Heatmap, if set, colors each Cell by its value relative to the highest
+	// value in the Layer, instead of showing its Entities. In Monochrome, the
+	// values are shaded as Fields are by Field.Display.
+	Heatmap Heatmap
+}
+
 func (l *Layer) Display() string {
 	var result string
 	for y := 0; y < l.Height(); y++ {
@@ -17,6 +64,131 @@ func (l *Layer) Display() string {
 	return result
 }
 
+// DisplayWith draws the Layer in color, as set by opts.
+func (l *Layer) DisplayWith(opts DisplayOptions) string {
+	if opts.Legend {
+		return beside(l.Tiles(opts), l.Width(), LegendLines(l.Legend(), opts.Mode))
+	}
+	var b strings.Builder
+	for _, row := range l.Tiles(opts) {
+		for _, tile := range row {
+			b.WriteString(tile)
+		}
+		b.WriteString("\n")
+	}
+	return b.String()
+}
+
+// Tiles draws each Cell of the Layer as DisplayWith does, row by row, for
+// callers that lay out the Layer themselves.
+func (l *Layer) Tiles(opts DisplayOptions) [][]string {
+	p := newPainter(opts, l)
+	tiles := make([][]string, l.Height())
+	for y := range tiles {
+		tiles[y] = make([]string, l.Width())
+		for x := range tiles[y] {
+			if opts.Heatmap != nil {
+				tiles[y][x] = p.heat(opts.Heatmap.Value(l, x, y), p.heatMax[l.z])
+				continue
+			}
+			cell := l.Cell(Vec2D(x, y))
+			var top *Entity
+			if ents := cell.Entities(); len(ents) > 0 {
+				top = ents[len(ents)-1]
+			}
+			tiles[y][x] = p.tile(top, p.background(cell, Vec(x, y, l.z)))
+		}
+	}
+	return tiles
+}
+
+// painter draws Cells as tiles, as set by a DisplayOptions, for the Layers
+// it was made for.
+type painter struct {
+	opts DisplayOptions
+
+	// most is the highest energy of the Entities of each name, for shading.
+	most map[string]int
+
+	// max is the strongest value of the Field in each Layer.
+	max map[int]float64
+
+	// heatMax is the highest value of the Heatmap in each Layer.
+	heatMax map[int]float64
+}
+
+func newPainter(opts DisplayOptions, layers ...*Layer) *painter {
+	p := &painter{opts: opts}
+	if opts.ShadeEnergy {
+		p.most = make(map[string]int)
+		for _, l := range layers {
+			for _, cell := range l.cells {
+				for _, ent := range cell.Entities() {
+					if ent.Attrs.Energy > p.most[ent.Name] {
+						p.most[ent.Name] = ent.Attrs.Energy
+					}
+				}
+			}
+		}
+	}
+	if opts.Field != nil {
+		p.max = make(map[int]float64)
+		for _, l := range layers {
+			p.max[l.z] = opts.Field.Max(l.z)
+		}
+	}
+	if opts.Heatmap != nil {
+		p.heatMax = make(map[int]float64)
+		for _, l := range layers {
+			p.heatMax[l.z] = heatMax(opts.Heatmap, []*Layer{l})
+		}
+	}
+	return p
+}
+
+// background returns the background Color of a Cell, if any.
+func (p *painter) background(cell *Cell, vec Vector) Color {
+	if p.opts.Field != nil {
+		if value := p.opts.Field.At(vec); value > 0 {
+			return p.opts.Field.shade(value / p.max[vec.Z])
+		}
+		return ""
+	}
+	if p.opts.Terrain {
+		return cell.terrain.Color
+	}
+	return ""
+}
+
+// tile draws an Entity, or the empty tile if it's nil, on a background.
+func (p *painter) tile(ent *Entity, background Color) string {
+	if ent == nil {
+		symbol := p.opts.EmptyTile
+		if symbol == "" {
+			symbol = blankSymbol
+		}
+		return Style{}.render(symbol, p.opts.Mode, 1, background)
+	}
+	shade := 1.0
+	if p.most[ent.Name] > 0 {
+		shade = minShade + (1-minShade)*float64(ent.Attrs.Energy)/float64(p.most[ent.Name])
+	}
+	return ent.Style.render(ent.Display(), p.opts.Mode, shade, background)
+}
+
+// heat draws a value of the Heatmap, out of the highest value.
+func (p *painter) heat(value, max float64) string {
+	var level float64
+	if max > 0 {
+		level = value / max
+	}
+	if p.opts.Mode == Monochrome {
+		ramp := []rune(fieldRamp)
+		return string(ramp[int(level*float64(len(ramp)-1))])
+	}
+	return Style{}.render(blankSymbol, p.opts.Mode, 1, heatColor(level))
+}
+
 func (c *Cell) Display() string {
 	ents := c.Entities()
 	if len(ents) > 0 {
@@ -29,3 +201,38 @@ func (c *Cell) Display() string {
 func (e *Entity) Display() string {
 	return e.Symbol
 }
+
+// Display renders the Field in the given Layer, shading each Cell relative to
+// the strongest value in that Layer.
+func (f *Field) Display(z int) string {
+	max := f.Max(z)
+	ramp := []rune(fieldRamp)
+
+	var result string
+	for y := 0; y < f.height; y++ {
+		for x := 0; x < f.width; x++ {
+			i := 0
+			if max > 0 {
+				level := f.At(Vec(x, y, z)) / max
+				i = int(level * float64(len(ramp)-1))
+			}
+			result += string(ramp[i])
+		}
+		result += "\n"
+	}
+	return result
+}
+
+// shade returns the background Color of a Cell where the Field is at the
+// given level, from 0 (black) to 1 (the Field's Color).
+func (f *Field) shade(level float64) Color {
+	color := f.Color
+	if color == "" {
+		color = defaultFieldColor
+	}
+	c, err := color.resolve()
+	if err != nil {
+		return ""
+	}
+	return c.scale(level).hex()
+}
@@ -1,6 +1,8 @@
 package entities
 
 import (
+	"math/rand"
+
 	es "github.com/dustinrohde/ecoscript"
 )
 
@@ -17,7 +19,7 @@ func NewShrub() *es.Entity {
 		new(es.Grow).Define(es.Properties{
 			"rate": 3,
 		}),
-	).AddStrategy(func() string {
+	).AddStrategy(func(*rand.Rand) string {
 		return "Grow"
 	})
 }
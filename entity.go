@@ -1,53 +1,76 @@
 package ecoscript
 
-import "reflect"
+import (
+	"math/rand"
+	"reflect"
+)
 
 type (
 	// Entity represents an entity in the world.
 	Entity struct {
-		id EntityID
+		id      EntityID
+		species string
 
 		Name   string      `mapstructure:"name"`
 		Symbol string      `mapstructure:"symbol"`
 		Attrs  *Attributes `mapstructure:"attributes"`
 		Traits []Trait     `mapstructure:"traits"`
+		Style  Style       `mapstructure:"style"`
 
 		Behaviors      Behaviors
 		ChooseBehavior Strategy
 
 		currentAbility int
 		activity       *Activity
+		behavior       string
+
+		// ticked is the number of the tick the Entity last acted in, plus
+		// one, for Schedulers that must not tick it twice.
+		ticked int
+
+		// rest is the tick before which the Entity won't start a new
+		// activity.
+		rest int
 	}
 
 	EntityID int
 
+	// Attributes are the physical properties of an Entity. Speed scales how
+	// long its activities take under a DiscreteScheduler; zero means 1.
 	Attributes struct {
-		Walkable bool `mapstructure:"walkable"`
-		Energy   int  `mapstructure:"energy"`
-		Size     int  `mapstructure:"size"`
-		Mass     int  `mapstructure:"mass"`
+		Walkable bool    `mapstructure:"walkable"`
+		Energy   int     `mapstructure:"energy"`
+		Size     int     `mapstructure:"size"`
+		Mass     int     `mapstructure:"mass"`
+		Speed    float64 `mapstructure:"speed"`
 	}
 
 	Trait string
 
 	Behaviors map[string]Behavior
 
-	Strategy func() string
+	// Strategy chooses the key of the Behavior an Entity performs next,
+	// drawing any randomness from rng.
+	Strategy func(rng *rand.Rand) string
 )
 
-var (
-	oid          EntityID  = -1
-	lastEntityID *EntityID = &oid
-)
+// noEntityID is the ID of an Entity that hasn't been added to a World yet.
+const noEntityID EntityID = -1
 
+// NewEntity creates an Entity with no Attributes, traits or Behaviors. Give
+// it some energy before adding it to a World: an Entity with none starves on
+// its first tick. It gets its ID from the first World it's added to.
 func NewEntity(name, symbol string) *Entity {
+	return newEntity(noEntityID, name, symbol)
+}
+
+func newEntity(id EntityID, name, symbol string) *Entity {
 	traits := make([]Trait, 0)
 	attrs := new(Attributes)
 	behaviors := make(map[string]Behavior)
 	activity := NewActivity()
-	*lastEntityID++
 	return &Entity{
-		id:        *lastEntityID,
+		id:        id,
 		Name:      name,
 		Symbol:    symbol,
 		Attrs:     attrs,
@@ -70,30 +93,82 @@ func (e *Entity) AddTraits(traits ...Trait) *Entity {
 func (e *Entity) AddBehaviors(behaviors ...Behavior) *Entity {
 	for i := range behaviors {
 		behavior := behaviors[i]
-		name := reflect.TypeOf(behavior).Elem().Name()
-		e.Behaviors[name] = behavior
+		e.Behaviors[behaviorKey(behavior)] = behavior
 	}
 	return e
 }
 
+// behaviorKey returns the key of a Behavior in Entity.Behaviors: its type
+// name, followed by its own name if it has one, as in "Script:graze".
+func behaviorKey(behavior Behavior) string {
+	key := reflect.TypeOf(behavior).Elem().Name()
+	if named, ok := behavior.(interface{ Name() string }); ok {
+		key += ":" + named.Name()
+	}
+	return key
+}
+
 func (e *Entity) AddStrategy(fn Strategy) *Entity {
 	e.ChooseBehavior = fn
 	return e
 }
 
 func (e *Entity) Tick(world *World, vec Vector) {
+	// Sleeping Entities don't act at all.
+	if !world.Clock().Awake(e) {
+		return
+	}
+
 	// If activity in progress, continue it. Otherwise, start a new activity.
 	if e.activity.InProgress() {
-		e.activity.Continue()
-	} else {
-		// Start new activity.
-		behaviorKey := e.ChooseBehavior()
-		behavior := e.Behaviors[behaviorKey]
-		delay, exec := behavior.Execute(world, e, vec)
-		e.activity.Begin(delay, exec)
+		if e.activity.Continue() {
+			e.finished(world, vec)
+		}
+	} else if e.start(world, vec) {
+		if e.activity.Continue() {
+			e.finished(world, vec)
+		}
 	}
 }
 
+// start chooses a Behavior and starts an activity of it, unless the Entity
+// is resting or has nothing to do. A Behavior that plans nothing, with no
+// delay and no action, starts no activity.
+func (e *Entity) start(world *World, vec Vector) bool {
+	// Resting Entities wait before starting a new activity.
+	if world.Clock().Ticks() < e.rest {
+		return false
+	}
+
+	// Start new activity.
+	if e.ChooseBehavior == nil {
+		return false
+	}
+	behaviorKey := e.ChooseBehavior(world.Rand())
+	behavior, ok := e.Behaviors[behaviorKey]
+	if !ok {
+		return false
+	}
+	delay, exec := behavior.Execute(world, e, vec)
+	if delay == 0 && exec == nil {
+		return false
+	}
+
+	e.behavior = behaviorKey
+	ev := entityEvent(EventActivityStarted, e, vec)
+	ev.Detail = behaviorKey
+	world.emit(ev)
+
+	e.activity.start(delay, exec)
+	return true
+}
+
+func (e *Entity) finished(world *World, vec Vector) {
+	ev := entityEvent(EventActivityFinished, e, vec)
+	ev.Detail = e.behavior
+	world.emit(ev)
+}
+
 // ---------------------------------------------------------------------
 // Behavior API.
 
@@ -101,6 +176,42 @@ func (e *Entity) ID() EntityID {
 	return e.id
 }
 
+// Species returns the key of the Species the Entity was spawned from, if any.
+func (e *Entity) Species() string {
+	return e.species
+}
+
+// Activity returns what the Entity is doing, and Behavior the key of the
+// Behavior it is doing or did last.
+func (e *Entity) Activity() *Activity {
+	return e.activity
+}
+
+func (e *Entity) Behavior() string {
+	return e.behavior
+}
+
+// RestUntil keeps the Entity from starting a new activity before the given
+// tick. An activity in progress carries on. Schedulers that keep track of
+// Entities don't visit resting ones at all.
+func (e *Entity) RestUntil(tick int) {
+	e.rest = tick
+}
+
+// RestingUntil returns the tick the Entity rests until.
+func (e *Entity) RestingUntil() int {
+	return e.rest
+}
+
+func (e *Entity) HasTrait(trait Trait) bool {
+	for i := range e.Traits {
+		if e.Traits[i] == trait {
+			return true
+		}
+	}
+	return false
+}
+
 func (e *Entity) Transfer(energy int) bool {
 	e.Attrs.Energy += energy
 	return e.Alive()
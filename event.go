@@ -0,0 +1,226 @@
+package ecoscript
+
+import (
+	"fmt"
+	"io"
+	"sync"
+	"sync/atomic"
+)
+
+// EventType identifies what happened in an Event.
+type EventType int
+
+const (
+	EventSpawned EventType = iota
+	EventMoved
+	EventAttacked
+	EventConsumed
+	EventDied
+	EventReproduced
+	EventActivityStarted
+	EventActivityFinished
+	EventFailed
+)
+
+var eventTypeNames = []string{
+	EventSpawned:          "spawned",
+	EventMoved:            "moved",
+	EventAttacked:         "attacked",
+	EventConsumed:         "consumed",
+	EventDied:             "died",
+	EventReproduced:       "reproduced",
+	EventActivityStarted:  "started",
+	EventActivityFinished: "finished",
+	EventFailed:           "failed",
+}
+
+func (t EventType) String() string {
+	if int(t) < len(eventTypeNames) {
+		return eventTypeNames[t]
+	}
+	return fmt.Sprintf("EventType(%d)", int(t))
+}
+
+// ParseEventType returns the EventType with the given name.
+func ParseEventType(name string) (EventType, bool) {
+	for i := range eventTypeNames {
+		if eventTypeNames[i] == name {
+			return EventType(i), true
+		}
+	}
+	return 0, false
+}
+
+func (t EventType) MarshalText() ([]byte, error) {
+	return []byte(t.String()), nil
+}
+
+func (t *EventType) UnmarshalText(text []byte) error {
+	typ, ok := ParseEventType(string(text))
+	if !ok {
+		return fmt.Errorf("unknown event type '%s'", text)
+	}
+	*t = typ
+	return nil
+}
+
+// Event describes something that happened to an Entity during a tick.
+type Event struct {
+	Type    EventType
+	Tick    int
+	Entity  EntityID
+	Species string
+	Vec     Vector
+
+	// Dest is where the Entity moved to, for EventMoved.
+	Dest Vector
+
+	// Other is the other Entity involved: the attacker or consumer of the
+	// Entity, or the offspring it reproduced.
+	Other EntityID
+
+	// Detail is the cause of an EventDied, the Behavior of an activity, or
+	// the error of an EventFailed.
+	Detail string
+}
+
+func (ev Event) String() string {
+	s := fmt.Sprintf("tick %d: %s#%d %s at (%d,%d,%d)",
+		ev.Tick, ev.Species, ev.Entity, ev.Type, ev.Vec.X, ev.Vec.Y, ev.Vec.Z)
+	switch ev.Type {
+	case EventMoved:
+		s += fmt.Sprintf(" to (%d,%d,%d)", ev.Dest.X, ev.Dest.Y, ev.Dest.Z)
+	case EventAttacked, EventConsumed:
+		s += fmt.Sprintf(" by #%d", ev.Other)
+	case EventReproduced:
+		s += fmt.Sprintf(" offspring #%d", ev.Other)
+	}
+	if ev.Detail != "" {
+		s += fmt.Sprintf(" (%s)", ev.Detail)
+	}
+	return s
+}
+
+// Filter selects Events. Empty criteria match everything.
+type Filter struct {
+	Types    []EventType
+	Entities []EntityID
+	Species  []string
+	Region   *Region
+}
+
+// Match returns true if the Event satisfies every criterion of the Filter.
+func (f Filter) Match(ev Event) bool {
+	if len(f.Types) > 0 {
+		ok := false
+		for _, t := range f.Types {
+			ok = ok || t == ev.Type
+		}
+		if !ok {
+			return false
+		}
+	}
+	if len(f.Entities) > 0 {
+		ok := false
+		for _, id := range f.Entities {
+			ok = ok || id == ev.Entity || id == ev.Other
+		}
+		if !ok {
+			return false
+		}
+	}
+	if len(f.Species) > 0 {
+		ok := false
+		for _, species := range f.Species {
+			ok = ok || species == ev.Species
+		}
+		if !ok {
+			return false
+		}
+	}
+	if f.Region != nil && !f.Region.Contains(ev.Vec) {
+		return false
+	}
+	return true
+}
+
+// Bus delivers Events to subscribers. Publishing is nearly free when nobody
+// is subscribed, so Worlds always publish.
+type Bus struct {
+	mu     sync.Mutex
+	subs   []*subscription
+	nextID int
+	count  int32
+}
+
+type subscription struct {
+	id     int
+	filter Filter
+	fn     func(Event)
+}
+
+func NewBus() *Bus {
+	return &Bus{
+		subs: make([]*subscription, 0),
+	}
+}
+
+// Subscribe calls fn with every published Event that matches the Filter,
+// until the returned function is called.
+func (b *Bus) Subscribe(filter Filter, fn func(Event)) (unsubscribe func()) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	sub := &subscription{b.nextID, filter, fn}
+	b.nextID++
+
+	// Copy on write, so Publish can iterate without holding the lock.
+	subs := make([]*subscription, len(b.subs), len(b.subs)+1)
+	copy(subs, b.subs)
+	b.subs = append(subs, sub)
+	atomic.StoreInt32(&b.count, int32(len(b.subs)))
+
+	return func() {
+		b.mu.Lock()
+		defer b.mu.Unlock()
+
+		subs := make([]*subscription, 0, len(b.subs))
+		for _, other := range b.subs {
+			if other.id != sub.id {
+				subs = append(subs, other)
+			}
+		}
+		b.subs = subs
+		atomic.StoreInt32(&b.count, int32(len(b.subs)))
+	}
+}
+
+// Active returns true if anybody is subscribed.
+func (b *Bus) Active() bool {
+	return atomic.LoadInt32(&b.count) > 0
+}
+
+// Publish delivers an Event to every matching subscriber, in the order they
+// subscribed.
+func (b *Bus) Publish(ev Event) {
+	if !b.Active() {
+		return
+	}
+
+	b.mu.Lock()
+	subs := b.subs
+	b.mu.Unlock()
+
+	for _, sub := range subs {
+		if sub.filter.Match(ev) {
+			sub.fn(ev)
+		}
+	}
+}
+
+// LogEvents returns a subscriber that writes each Event to w, one per line.
+func LogEvents(w io.Writer) func(Event) {
+	return func(ev Event) {
+		fmt.Fprintln(w, ev)
+	}
+}
@@ -0,0 +1,129 @@
+package ecoscript_test
+
+import (
+	"math/rand"
+
+	. "github.com/dustinrohde/ecoscript"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+// idle never plans anything.
+type idle struct{}
+
+func (b *idle) Define(props Properties) Behavior {
+	return DefineBehavior(b, props)
+}
+
+func (b *idle) Execute(wld *World, ent *Entity, vec Vector) (delay int, exec func()) {
+	return 0, nil
+}
+
+var _ = Describe("Bus", func() {
+	var (
+		world  *World
+		ent    *Entity
+		events []Event
+		record func(Event)
+	)
+
+	BeforeEach(func() {
+		world = NewWorld(3, 3, []string{"ground"})
+		ent = NewEntity("entity", "e").AddAttributes(&Attributes{Energy: 10})
+		events = make([]Event, 0)
+		record = func(ev Event) {
+			events = append(events, ev)
+		}
+	})
+
+	It("should publish spawns and moves", func() {
+		world.Events().Subscribe(Filter{}, record)
+
+		exec, ok := world.Spawn(ent, Vec(0, 0, 0))
+		Expect(ok).To(BeTrue())
+		exec()
+		exec, ok = world.Move(ent, Vec(0, 0, 0), Vec(1, 0, 0))
+		Expect(ok).To(BeTrue())
+		exec()
+
+		Expect(events).To(HaveLen(2))
+		Expect(events[0].Type).To(Equal(EventSpawned))
+		Expect(events[0].Entity).To(Equal(ent.ID()))
+		Expect(events[1].Type).To(Equal(EventMoved))
+		Expect(events[1].Dest.Equals(Vec(1, 0, 0))).To(BeTrue())
+	})
+
+	It("should publish deaths with their cause", func() {
+		world.Events().Subscribe(Filter{Types: []EventType{EventDied}}, record)
+
+		exec, _ := world.Spawn(ent, Vec(0, 0, 0))
+		exec()
+		ent.EndLife()
+		world.Tick()
+
+		Expect(events).To(HaveLen(1))
+		Expect(events[0].Detail).To(Equal("starved"))
+		Expect(world.Cell(Vec(0, 0, 0)).Population()).To(Equal(0))
+	})
+
+	It("should only publish a death once", func() {
+		world.Events().Subscribe(Filter{Types: []EventType{EventDied}}, record)
+
+		exec, _ := world.Spawn(ent, Vec(0, 0, 0))
+		exec()
+		first, ok := world.Destroy(ent, Vec(0, 0, 0))
+		Expect(ok).To(BeTrue())
+		second, ok := world.Destroy(ent, Vec(0, 0, 0))
+		Expect(ok).To(BeTrue())
+		first()
+		second()
+
+		Expect(events).To(HaveLen(1))
+	})
+
+	It("should only publish activities that were started", func() {
+		world.Events().Subscribe(Filter{Types: []EventType{EventActivityStarted, EventActivityFinished}}, record)
+
+		behavior := "idle"
+		ent.AddBehaviors(&idle{}, &hop{Height: 1})
+		ent.AddStrategy(func(*rand.Rand) string { return behavior })
+		exec, _ := world.Spawn(ent, Vec(0, 0, 0))
+		exec()
+		world.Tick()
+		Expect(events).To(BeEmpty())
+
+		behavior = "hop"
+		world.Tick()
+		Expect(events).To(HaveLen(2))
+		Expect(events[0].Type).To(Equal(EventActivityStarted))
+		Expect(events[1].Type).To(Equal(EventActivityFinished))
+		Expect(events[0].Detail).To(Equal("hop"))
+	})
+
+	It("should filter by region", func() {
+		world.Events().Subscribe(Filter{Region: &Region{X: 1, Y: 1, Width: 2, Height: 2}}, record)
+
+		exec, _ := world.Spawn(ent, Vec(0, 0, 0))
+		exec()
+		Expect(events).To(BeEmpty())
+
+		exec, _ = world.Move(ent, Vec(0, 0, 0), Vec(1, 1, 0))
+		exec()
+		exec, _ = world.Move(ent, Vec(1, 1, 0), Vec(2, 2, 0))
+		exec()
+		Expect(events).To(HaveLen(1))
+		Expect(events[0].Vec.Equals(Vec(1, 1, 0))).To(BeTrue())
+	})
+
+	It("should stop delivering after unsubscribing", func() {
+		unsubscribe := world.Events().Subscribe(Filter{}, record)
+		Expect(world.Events().Active()).To(BeTrue())
+		unsubscribe()
+		Expect(world.Events().Active()).To(BeFalse())
+
+		exec, _ := world.Spawn(ent, Vec(0, 0, 0))
+		exec()
+		Expect(events).To(BeEmpty())
+	})
+})
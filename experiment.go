@@ -0,0 +1,445 @@
+package ecoscript
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"math"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strconv"
+	"sync"
+
+	"github.com/pkg/errors"
+	"github.com/spf13/viper"
+)
+
+// Experiment runs a Mapfile headless many times, varying its parameters and
+// seeds, and aggregates the results. It is read from a YAML spec like:
+//
+//	mapfile: Mapfile
+//	seeds: [1, 2, 3]
+//	stop:
+//	  - tick >= 2000
+//	parameters:
+//	  - key: entities.sheep.attributes.energy
+//	    values: [50, 100, 150]
+//	  - key: entities.pine-tree.abilities.grow.rate
+//	    range: {from: 2, to: 10, step: 4}
+//
+// Every combination of parameter values is a configuration, and each
+// configuration is run once per seed. The special key "mapfile" varies the
+// Mapfile itself. Relative Mapfile paths are relative to the spec.
+type Experiment struct {
+	Mapfile    string       `mapstructure:"mapfile"`
+	Seeds      []int64      `mapstructure:"seeds"`
+	Runs       int          `mapstructure:"runs"`
+	Workers    int          `mapstructure:"workers"`
+	Parameters []*Parameter `mapstructure:"parameters"`
+
+	// Stop overrides the stop conditions of the Mapfile. As in the Mapfile,
+	// RawStop takes conditions both as strings and as YAML maps.
+	RawStop []interface{} `mapstructure:"stop"`
+	Stop    []string      `mapstructure:"-"`
+
+	// Progress, if set, is called after each run finishes.
+	Progress func(done, total int) `mapstructure:"-"`
+
+	// dir is the directory of the spec, which relative Mapfile paths are
+	// resolved against.
+	dir string
+}
+
+// Parameter is a Mapfile key and the values to try it with, given either as
+// a list or as a range of numbers.
+type Parameter struct {
+	Key    string        `mapstructure:"key"`
+	Values []interface{} `mapstructure:"values"`
+	Range  *struct {
+		From float64 `mapstructure:"from"`
+		To   float64 `mapstructure:"to"`
+		Step float64 `mapstructure:"step"`
+	} `mapstructure:"range"`
+}
+
+// ParseExperiment reads an Experiment spec.
+func ParseExperiment(filePath string) (*Experiment, error) {
+	v := viper.New()
+	v.SetConfigFile(filePath)
+	v.SetConfigType("yaml")
+	if err := v.ReadInConfig(); err != nil {
+		return nil, errors.Wrapf(err, "error reading experiment '%s'", filePath)
+	}
+
+	exp := &Experiment{dir: filepath.Dir(filePath)}
+	if err := v.Unmarshal(exp); err != nil {
+		return nil, errors.Wrap(err, "error unmarshaling experiment")
+	}
+	return exp, exp.clean()
+}
+
+func (exp *Experiment) clean() error {
+	if exp.Mapfile == "" && exp.parameter("mapfile") == nil {
+		return errors.New("experiment needs a ``mapfile`` or a ``mapfile`` parameter")
+	}
+	if len(exp.Seeds) == 0 {
+		if exp.Runs < 1 {
+			exp.Runs = 1
+		}
+		for i := 1; i <= exp.Runs; i++ {
+			exp.Seeds = append(exp.Seeds, int64(i))
+		}
+	}
+	if exp.Workers < 1 {
+		exp.Workers = runtime.NumCPU()
+	}
+	var err error
+	if exp.Stop, err = stopStrings(exp.RawStop); err != nil {
+		return errors.WithMessage(err, "invalid entry in ``stop``")
+	}
+	if _, err = ParseStopConditions(exp.Stop); err != nil {
+		return errors.WithMessage(err, "invalid entry in ``stop``")
+	}
+
+	keys := make(map[string]bool)
+	for _, param := range exp.Parameters {
+		if param.Key == "" {
+			return errors.New("every parameter needs a ``key``")
+		}
+		if keys[param.Key] {
+			return errors.Errorf("parameter '%s' occurs more than once", param.Key)
+		}
+		keys[param.Key] = true
+
+		if param.Range != nil {
+			if len(param.Values) > 0 {
+				return errors.Errorf("parameter '%s' can't have both ``values`` and ``range``", param.Key)
+			}
+			r := param.Range
+			if r.Step <= 0 || r.To < r.From {
+				return errors.Errorf("parameter '%s' needs a range with from <= to and a positive step", param.Key)
+			}
+			// Count steps rather than accumulating, so that rounding errors
+			// don't add or lose a value.
+			for i := 0; ; i++ {
+				value := r.From + float64(i)*r.Step
+				if value > r.To+r.Step*1e-9 {
+					break
+				}
+				param.Values = append(param.Values, rangeValue(value))
+			}
+		}
+		if len(param.Values) == 0 {
+			return errors.Errorf("parameter '%s' has no values", param.Key)
+		}
+	}
+	return nil
+}
+
+// rangeValue returns whole numbers as ints, so they can set integer
+// properties.
+func rangeValue(value float64) interface{} {
+	if value == math.Trunc(value) {
+		return int(value)
+	}
+	return value
+}
+
+// resolve returns the path of a Mapfile named in the spec.
+func (exp *Experiment) resolve(path string) string {
+	if filepath.IsAbs(path) {
+		return path
+	}
+	return filepath.Join(exp.dir, path)
+}
+
+func (exp *Experiment) parameter(key string) *Parameter {
+	for _, param := range exp.Parameters {
+		if param.Key == key {
+			return param
+		}
+	}
+	return nil
+}
+
+// Configurations returns every combination of parameter values, each as a
+// map of key to value.
+func (exp *Experiment) Configurations() []map[string]interface{} {
+	configs := []map[string]interface{}{{}}
+	for _, param := range exp.Parameters {
+		next := make([]map[string]interface{}, 0, len(configs)*len(param.Values))
+		for _, config := range configs {
+			for _, value := range param.Values {
+				combined := make(map[string]interface{}, len(config)+1)
+				for key := range config {
+					combined[key] = config[key]
+				}
+				combined[param.Key] = value
+				next = append(next, combined)
+			}
+		}
+		configs = next
+	}
+	return configs
+}
+
+// RunResult is the outcome of one run of an Experiment.
+type RunResult struct {
+	Config int
+	Seed   int64
+	Report *Report
+	Err    error
+}
+
+// Run runs every configuration with every seed, spread over the Experiment's
+// workers, and returns the results in order of configuration and seed.
+func (exp *Experiment) Run() []RunResult {
+	configs := exp.Configurations()
+	results := make([]RunResult, len(configs)*len(exp.Seeds))
+	jobs := make(chan int)
+
+	var (
+		wg   sync.WaitGroup
+		mu   sync.Mutex
+		done int
+	)
+	for i := 0; i < exp.Workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for job := range jobs {
+				config, seed := job/len(exp.Seeds), exp.Seeds[job%len(exp.Seeds)]
+				report, err := exp.runOne(configs[config], seed)
+				results[job] = RunResult{config, seed, report, err}
+
+				if exp.Progress != nil {
+					mu.Lock()
+					done++
+					exp.Progress(done, len(results))
+					mu.Unlock()
+				}
+			}
+		}()
+	}
+	for job := range results {
+		jobs <- job
+	}
+	close(jobs)
+	wg.Wait()
+	return results
+}
+
+func (exp *Experiment) runOne(config map[string]interface{}, seed int64) (report *Report, err error) {
+	// A bad value for a Behavior property panics when Entities are spawned.
+	defer func() {
+		if r := recover(); r != nil {
+			err = errors.Errorf("%v", r)
+		}
+	}()
+
+	path := exp.Mapfile
+	overrides := make(map[string]interface{}, len(config))
+	for key, value := range config {
+		if key == "mapfile" {
+			path = fmt.Sprint(value)
+		} else {
+			overrides[key] = value
+		}
+	}
+
+	mapfile, err := ParseMapfileWith(exp.resolve(path), overrides)
+	if err != nil {
+		return nil, err
+	}
+	stop := exp.Stop
+	if len(stop) == 0 {
+		stop = mapfile.Stop
+	}
+	if len(stop) == 0 {
+		return nil, errors.Errorf("neither the experiment nor '%s' has stop conditions", path)
+	}
+	conditions, err := ParseStopConditions(stop)
+	if err != nil {
+		return nil, err
+	}
+	if err := mapfile.CheckStopConditions(conditions); err != nil {
+		return nil, err
+	}
+
+	world, err := mapfile.ToWorld()
+	if err != nil {
+		return nil, err
+	}
+	world.Seed(seed)
+	return RunUntil(world, conditions), nil
+}
+
+// ---------------------------------------------------------------------
+// Aggregation
+
+// Summary aggregates the runs of one configuration.
+type Summary struct {
+	Config map[string]interface{}
+	Runs   int
+	Failed int
+
+	Ticks Stat
+
+	// Final is the final population of each Species, and Extinct the tick
+	// at which it died out, over the runs in which it did.
+	Final   map[string]Stat
+	Extinct map[string]Stat
+}
+
+// Stat is the mean and sample variance of a set of numbers.
+type Stat struct {
+	N        int
+	Mean     float64
+	Variance float64
+}
+
+func newStat(values []float64) Stat {
+	stat := Stat{N: len(values)}
+	if stat.N == 0 {
+		return stat
+	}
+	for _, value := range values {
+		stat.Mean += value
+	}
+	stat.Mean /= float64(stat.N)
+	if stat.N > 1 {
+		for _, value := range values {
+			stat.Variance += (value - stat.Mean) * (value - stat.Mean)
+		}
+		stat.Variance /= float64(stat.N - 1)
+	}
+	return stat
+}
+
+// Summarize aggregates the results of an Experiment by configuration.
+func (exp *Experiment) Summarize(results []RunResult) []*Summary {
+	configs := exp.Configurations()
+	summaries := make([]*Summary, len(configs))
+
+	for i, config := range configs {
+		summary := &Summary{
+			Config:  config,
+			Final:   make(map[string]Stat),
+			Extinct: make(map[string]Stat),
+		}
+		ticks := make([]float64, 0)
+		finals := make(map[string][]float64)
+		extinct := make(map[string][]float64)
+
+		reports := make([]*Report, 0)
+		for _, result := range results {
+			if result.Config != i {
+				continue
+			}
+			summary.Runs++
+			if result.Err != nil {
+				summary.Failed++
+				continue
+			}
+			reports = append(reports, result.Report)
+			for key := range result.Report.Peak {
+				finals[key] = nil
+			}
+		}
+
+		for _, report := range reports {
+			ticks = append(ticks, float64(report.Ticks))
+			for key := range finals {
+				finals[key] = append(finals[key], float64(report.Final.Population[key]))
+			}
+			for key, tick := range report.Extinct {
+				extinct[key] = append(extinct[key], float64(tick))
+			}
+		}
+
+		summary.Ticks = newStat(ticks)
+		for key, values := range finals {
+			summary.Final[key] = newStat(values)
+		}
+		for key, values := range extinct {
+			summary.Extinct[key] = newStat(values)
+		}
+		summaries[i] = summary
+	}
+	return summaries
+}
+
+// WriteSummariesCSV writes one row per configuration: its parameter values,
+// the number of runs, and the mean and variance of the number of ticks run,
+// of each Species' final population and of the tick at which it went
+// extinct, with the number of runs in which it did.
+func (exp *Experiment) WriteSummariesCSV(w io.Writer, summaries []*Summary) error {
+	species := make(map[string]bool)
+	for _, summary := range summaries {
+		for key := range summary.Final {
+			species[key] = true
+		}
+		for key := range summary.Extinct {
+			species[key] = true
+		}
+	}
+	speciesKeys := sortedKeys(species)
+
+	header := make([]string, 0)
+	for _, param := range exp.Parameters {
+		header = append(header, param.Key)
+	}
+	header = append(header, "runs", "failed", "ticks_mean", "ticks_var")
+	for _, key := range speciesKeys {
+		header = append(header,
+			"final:"+key+"_mean", "final:"+key+"_var",
+			"extinct:"+key+"_runs", "extinct:"+key+"_mean", "extinct:"+key+"_var")
+	}
+
+	out := csv.NewWriter(w)
+	if err := out.Write(header); err != nil {
+		return err
+	}
+	for _, summary := range summaries {
+		row := make([]string, 0, len(header))
+		for _, param := range exp.Parameters {
+			row = append(row, fmt.Sprint(summary.Config[param.Key]))
+		}
+		row = append(row, strconv.Itoa(summary.Runs), strconv.Itoa(summary.Failed))
+		row = append(row, formatStat(summary.Ticks)...)
+		for _, key := range speciesKeys {
+			row = append(row, formatStat(summary.Final[key])...)
+			extinct := summary.Extinct[key]
+			row = append(row, strconv.Itoa(extinct.N))
+			row = append(row, formatStat(extinct)...)
+		}
+		if err := out.Write(row); err != nil {
+			return err
+		}
+	}
+	out.Flush()
+	return out.Error()
+}
+
+func formatStat(stat Stat) []string {
+	if stat.N == 0 {
+		return []string{"", ""}
+	}
+	return []string{
+		strconv.FormatFloat(stat.Mean, 'f', 3, 64),
+		strconv.FormatFloat(stat.Variance, 'f', 3, 64),
+	}
+}
+
+// Errors returns the errors of the failed runs, sorted.
+func Errors(results []RunResult) []string {
+	errs := make([]string, 0)
+	for _, result := range results {
+		if result.Err != nil {
+			errs = append(errs, fmt.Sprintf("configuration %d, seed %d: %s", result.Config, result.Seed, result.Err))
+		}
+	}
+	sort.Strings(errs)
+	return errs
+}
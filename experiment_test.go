@@ -0,0 +1,123 @@
+package ecoscript_test
+
+import (
+	"bytes"
+	"encoding/csv"
+	"io/ioutil"
+	"os"
+
+	. "github.com/dustinrohde/ecoscript"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Experiment", func() {
+	var path string
+
+	write := func(spec string) {
+		file, err := ioutil.TempFile("examples", "experiment-*.yaml")
+		Expect(err).NotTo(HaveOccurred())
+		_, err = file.WriteString(spec)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(file.Close()).To(Succeed())
+		path = file.Name()
+	}
+
+	AfterEach(func() {
+		os.Remove(path)
+	})
+
+	It("should run every configuration with every seed", func() {
+		write(`
+mapfile: Mapfile
+seeds: [1, 2]
+workers: 3
+stop: [tick >= 20]
+parameters:
+  - key: entities.sheep.attributes.energy
+    values: [30, 80]
+  - key: entities.berry-bush.abilities.grow.rate
+    range: {from: 2, to: 6, step: 2}
+`)
+		exp, err := ParseExperiment(path)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(exp.Configurations()).To(HaveLen(6))
+
+		results := exp.Run()
+		Expect(results).To(HaveLen(12))
+		Expect(Errors(results)).To(BeEmpty())
+		for _, result := range results {
+			Expect(result.Report.Ticks).To(Equal(20))
+		}
+
+		// Runs are seeded, so they don't depend on which worker ran them.
+		again := exp.Run()
+		for i := range results {
+			Expect(again[i].Report.Final.Population).To(Equal(results[i].Report.Final.Population))
+		}
+
+		summaries := exp.Summarize(results)
+		Expect(summaries).To(HaveLen(6))
+		Expect(summaries[0].Runs).To(Equal(2))
+		Expect(summaries[0].Ticks).To(Equal(Stat{N: 2, Mean: 20}))
+
+		var out bytes.Buffer
+		Expect(exp.WriteSummariesCSV(&out, summaries)).To(Succeed())
+		rows, err := csv.NewReader(&out).ReadAll()
+		Expect(err).NotTo(HaveOccurred())
+		Expect(rows).To(HaveLen(7))
+		Expect(rows[0][:4]).To(Equal([]string{
+			"entities.sheep.attributes.energy", "entities.berry-bush.abilities.grow.rate", "runs", "failed",
+		}))
+		Expect(rows[1][:4]).To(Equal([]string{"30", "2", "2", "0"}))
+	})
+
+	It("should report runs that fail", func() {
+		write(`
+mapfile: Mapfile
+stop: [tick >= 1]
+parameters:
+  - key: entities.sheep.attributes.wings
+    values: [2]
+`)
+		exp, err := ParseExperiment(path)
+		Expect(err).NotTo(HaveOccurred())
+		results := exp.Run()
+		Expect(Errors(results)).To(HaveLen(1))
+		Expect(exp.Summarize(results)[0].Failed).To(Equal(1))
+	})
+
+	It("should find Mapfiles relative to the spec", func() {
+		write(`
+seeds: [1]
+stop: [tick >= 5]
+parameters:
+  - key: mapfile
+    values: [Mapfile, scripted/Mapfile]
+`)
+		exp, err := ParseExperiment(path)
+		Expect(err).NotTo(HaveOccurred())
+		results := exp.Run()
+		Expect(Errors(results)).To(BeEmpty())
+		Expect(results).To(HaveLen(2))
+
+		var out bytes.Buffer
+		Expect(exp.WriteSummariesCSV(&out, exp.Summarize(results))).To(Succeed())
+		rows, err := csv.NewReader(&out).ReadAll()
+		Expect(err).NotTo(HaveOccurred())
+		Expect(rows[1][0]).To(Equal("Mapfile"))
+		Expect(rows[2][0]).To(Equal("scripted/Mapfile"))
+	})
+
+	It("should reject invalid specs", func() {
+		write(`
+mapfile: Mapfile
+parameters:
+  - key: entities.sheep.attributes.energy
+    range: {from: 10, to: 5, step: 1}
+`)
+		_, err := ParseExperiment(path)
+		Expect(err).To(HaveOccurred())
+	})
+})
@@ -0,0 +1,237 @@
+package ecoscript
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// ---------------------------------------------------------------------
+// Extensions
+
+// Extension is a package of Behaviors, strategies, traits, renderers and
+// Event subscribers that lives outside this module. An Extension registers
+// itself when its package is imported, usually from an init function:
+//
+//	package herd
+//
+//	type extension struct{}
+//
+//	func (extension) Name() string { return "herd" }
+//
+//	func (extension) Register(r *ecoscript.Registry) {
+//		r.Behavior("flock", func() ecoscript.Behavior { return new(Flock) })
+//		r.Strategy("hungry-first", HungryFirst)
+//		r.Trait("leader", "other members of the herd follow it")
+//		r.Subscriber(ecoscript.Filter{Types: []ecoscript.EventType{ecoscript.EventDied}}, mourn)
+//	}
+//
+//	func init() {
+//		ecoscript.RegisterExtension(extension{})
+//	}
+//
+// A program links the Extension in by importing its package, as in
+// import _ "example.com/herd". A Mapfile that relies on it lists it under
+// “extensions“, and fails to parse in programs that don't link it.
+type Extension interface {
+	// Name is how Mapfiles refer to the Extension.
+	Name() string
+
+	// Register registers the parts of the Extension.
+	Register(r *Registry)
+}
+
+// Registry is what an Extension registers its parts with. Each part must
+// have a name of its own: registering a name that's already taken, by this
+// module or another Extension, panics.
+type Registry struct {
+	ext *extension
+}
+
+// extension is a registered Extension.
+type extension struct {
+	Extension
+	subscribers []extensionSubscriber
+}
+
+type extensionSubscriber struct {
+	filter Filter
+	fn     func(*World) func(Event)
+}
+
+var (
+	extensions = make(map[string]*extension)
+
+	// extensionParts maps each part registered by an Extension, by kind and
+	// name as in "behavior flock", to the name of the Extension.
+	extensionParts = make(map[string]string)
+)
+
+// RegisterExtension registers an Extension and its parts. It panics if an
+// Extension by the same name is already registered.
+func RegisterExtension(ext Extension) {
+	if ext == nil {
+		panic("ecoscript: RegisterExtension of a nil Extension")
+	}
+	name := ext.Name()
+	if _, ok := extensions[name]; ok {
+		panic(fmt.Sprintf("ecoscript: extension '%s' is registered twice", name))
+	}
+	e := &extension{Extension: ext}
+	ext.Register(&Registry{e})
+	extensions[name] = e
+}
+
+// Extensions returns the names of the registered Extensions, sorted.
+func Extensions() []string {
+	names := make([]string, 0, len(extensions))
+	for name := range extensions {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// extensionOf returns the name of the Extension that registered a part, if
+// any.
+func extensionOf(kind, name string) (string, bool) {
+	ext, ok := extensionParts[kind+" "+name]
+	return ext, ok
+}
+
+// claim records that the Extension registers a part, panicking if the name
+// is taken.
+func (r *Registry) claim(kind, name string, taken bool) {
+	if taken {
+		panic(fmt.Sprintf("ecoscript: extension '%s' registers %s '%s', which is already registered",
+			r.ext.Name(), kind, name))
+	}
+	extensionParts[kind+" "+name] = r.ext.Name()
+}
+
+// Behavior makes a Behavior available to Mapfiles as an ability, like
+// RegisterBehavior. Snapshots find Behaviors by the name of their type, so
+// it panics if another registered Behavior's type has the same name.
+func (r *Registry) Behavior(name string, fn func() Behavior) {
+	typ := reflect.TypeOf(fn()).Elem()
+	if other, ok := behaviorType(typ.Name()); ok && reflect.TypeOf(other()).Elem() != typ {
+		panic(fmt.Sprintf("ecoscript: extension '%s' registers behavior '%s' of type %s, whose name is taken by %s",
+			r.ext.Name(), name, typ, reflect.TypeOf(other()).Elem()))
+	}
+	_, taken := behaviorKinds[name]
+	r.claim("behavior", name, taken)
+	RegisterBehavior(name, fn)
+}
+
+// Strategy makes a Strategy available to Mapfiles as the “strategy“ of a
+// Species, like RegisterStrategy.
+func (r *Registry) Strategy(name string, fn func(Behaviors) Strategy) {
+	_, taken := strategyKinds[name]
+	r.claim("strategy", name, taken)
+	RegisterStrategy(name, fn)
+}
+
+// Trait describes a trait that the Extension gives meaning to, like
+// RegisterTrait.
+func (r *Registry) Trait(trait Trait, description string) {
+	_, taken := traitDescriptions[trait]
+	r.claim("trait", string(trait), taken)
+	RegisterTrait(trait, description)
+}
+
+// Renderer makes a Renderer available by name, like RegisterRenderer.
+func (r *Registry) Renderer(name string, renderer Renderer) {
+	_, taken := renderers[name]
+	r.claim("renderer", name, taken)
+	RegisterRenderer(name, renderer)
+}
+
+// Subscriber subscribes to the Events of every World created from a Mapfile
+// that lists the Extension. fn is called with each such World and returns
+// the function to subscribe with the given Filter.
+func (r *Registry) Subscriber(filter Filter, fn func(w *World) func(Event)) {
+	r.ext.subscribers = append(r.ext.subscribers, extensionSubscriber{filter, fn})
+}
+
+// attach subscribes the Extension's subscribers to a World.
+func (e *extension) attach(w *World) {
+	for _, sub := range e.subscribers {
+		w.Events().Subscribe(sub.filter, sub.fn(w))
+	}
+}
+
+// ---------------------------------------------------------------------
+// Traits
+
+// traitDescriptions describes the traits that something gives meaning to.
+// Any other trait only means what the abilities of a Mapfile make of it.
+var traitDescriptions = map[Trait]string{
+	TraitNocturnal: "only acts at night",
+	TraitDiurnal:   "only acts during the day",
+	TraitPlant:     "withers in a drought",
+	TraitAquatic:   "survives floods",
+}
+
+// RegisterTrait describes a trait that something gives meaning to.
+func RegisterTrait(trait Trait, description string) {
+	traitDescriptions[trait] = description
+}
+
+// TraitDescription returns the description of a trait, if it has one.
+func TraitDescription(trait Trait) (string, bool) {
+	description, ok := traitDescriptions[trait]
+	return description, ok
+}
+
+// ---------------------------------------------------------------------
+// Renderers
+
+// Renderer draws the given Layers of a World as text, like the views of the
+// render command.
+type Renderer func(w *World, layers []int, opts DisplayOptions) string
+
+var renderers = map[string]Renderer{
+	"layers": renderLayers,
+	"composite": func(w *World, layers []int, opts DisplayOptions) string {
+		return w.Composite(CompositeOptions{DisplayOptions: opts, Layers: layers})
+	},
+	"side": (*World).SideBySide,
+}
+
+// RegisterRenderer makes a Renderer available by name.
+func RegisterRenderer(name string, renderer Renderer) {
+	renderers[name] = renderer
+}
+
+// LookupRenderer returns the Renderer registered under the given name.
+func LookupRenderer(name string) (Renderer, bool) {
+	renderer, ok := renderers[name]
+	return renderer, ok
+}
+
+// Renderers returns the names of the registered Renderers, sorted.
+func Renderers() []string {
+	names := make([]string, 0, len(renderers))
+	for name := range renderers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// renderLayers draws the Layers one after another, each under its name if
+// there's more than one.
+func renderLayers(w *World, layers []int, opts DisplayOptions) string {
+	var b strings.Builder
+	for i, z := range layers {
+		if len(layers) > 1 {
+			if i > 0 {
+				b.WriteString("\n")
+			}
+			fmt.Fprintf(&b, "%s (tick %d)\n", w.Layer(z).Name(), w.Clock().Ticks())
+		}
+		b.WriteString(w.Layer(z).DisplayWith(opts))
+	}
+	return b.String()
+}
@@ -0,0 +1,183 @@
+package ecoscript_test
+
+import (
+	"io/ioutil"
+	"math/rand"
+	"os"
+	"strings"
+
+	. "github.com/dustinrohde/ecoscript"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+// hop gains Height energy each tick.
+type hop struct {
+	Height int `mapstructure:"height"`
+}
+
+func (b *hop) Define(props Properties) Behavior {
+	b.Height = 1
+	return DefineBehavior(b, props)
+}
+
+func (b *hop) Execute(wld *World, ent *Entity, vec Vector) (delay int, exec func()) {
+	return 1, func() { ent.Transfer(b.Height) }
+}
+
+// hopper is an Extension with one of everything.
+type hopper struct {
+	hops *int
+}
+
+func (hopper) Name() string { return "hopper" }
+
+func (e hopper) Register(r *Registry) {
+	r.Behavior("hop", func() Behavior { return new(hop) })
+	r.Strategy("always-hop", func(Behaviors) Strategy {
+		return func(*rand.Rand) string { return "hop" }
+	})
+	r.Trait("springy", "hops")
+	r.Renderer("population", func(w *World, layers []int, opts DisplayOptions) string {
+		return "hoppers everywhere"
+	})
+	r.Subscriber(Filter{Types: []EventType{EventActivityFinished}}, func(*World) func(Event) {
+		return func(ev Event) {
+			if ev.Detail == "hop" {
+				*e.hops++
+			}
+		}
+	})
+}
+
+var hops int
+
+func init() {
+	RegisterExtension(hopper{&hops})
+}
+
+var _ = Describe("Extension", func() {
+	var path string
+
+	write := func(extensions string) {
+		file, err := ioutil.TempFile("", "Mapfile-*")
+		Expect(err).NotTo(HaveOccurred())
+		_, err = file.WriteString(extensions + `
+atlas:
+  map:
+    inline:
+      - name: ground
+        grid: |
+          k..
+          ...
+  legend:
+    - symbol: 'k'
+      entity: 'kangaroo'
+entities:
+  kangaroo:
+    name: kangaroo
+    symbol: 'k'
+    strategy: always-hop
+    attributes: {energy: 10}
+    traits: [springy]
+    abilities:
+      - name: move
+      - name: hop
+        properties:
+          height: 2
+`)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(file.Close()).To(Succeed())
+		path = file.Name()
+	}
+
+	AfterEach(func() {
+		os.Remove(path)
+	})
+
+	It("should register the parts of an Extension", func() {
+		Expect(Extensions()).To(ContainElement("hopper"))
+		description, ok := TraitDescription("springy")
+		Expect(ok).To(BeTrue())
+		Expect(description).To(Equal("hops"))
+		render, ok := LookupRenderer("population")
+		Expect(ok).To(BeTrue())
+		Expect(render(nil, nil, DisplayOptions{})).To(Equal("hoppers everywhere"))
+		Expect(Renderers()).To(ContainElement("composite"))
+	})
+
+	It("should run Mapfiles that list the Extension", func() {
+		write("extensions: [hopper]")
+		mapfile, err := ParseMapfile(path)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(mapfile.Lint()).To(BeEmpty())
+
+		world, err := mapfile.ToWorld()
+		Expect(err).NotTo(HaveOccurred())
+		hops = 0
+		for i := 0; i < 10; i++ {
+			world.Tick()
+		}
+		ent := world.Cell(Vec(0, 0, 0)).Occupier()
+		Expect(ent).NotTo(BeNil())
+		Expect(ent.Attrs.Energy).To(Equal(30))
+		Expect(hops).To(Equal(10))
+
+		// Restored Entities keep the strategy of their Species.
+		snap, err := world.Snapshot()
+		Expect(err).NotTo(HaveOccurred())
+		Expect(world.Restore(snap)).To(Succeed())
+		world.Tick()
+		Expect(world.Cell(Vec(0, 0, 0)).Occupier().Attrs.Energy).To(Equal(32))
+	})
+
+	It("should warn about parts of Extensions that aren't listed", func() {
+		write("")
+		mapfile, err := ParseMapfile(path)
+		Expect(err).NotTo(HaveOccurred())
+		warnings := strings.Join(mapfile.Lint(), "\n")
+		Expect(warnings).To(ContainSubstring("uses behavior 'hop' from extension 'hopper'"))
+		Expect(warnings).To(ContainSubstring("uses strategy 'always-hop' from extension 'hopper'"))
+		Expect(warnings).To(ContainSubstring("uses trait 'springy' from extension 'hopper'"))
+	})
+
+	It("should reject Mapfiles that need Extensions that aren't linked", func() {
+		write("extensions: [hopper, wombat]")
+		_, err := ParseMapfile(path)
+		Expect(err).To(MatchError(ContainSubstring("extension 'wombat' is listed in ``extensions``, but isn't linked")))
+	})
+
+	It("should reject names that are taken", func() {
+		Expect(func() { RegisterExtension(hopper{&hops}) }).To(Panic())
+		Expect(func() { RegisterExtension(grabby{}) }).To(PanicWith(ContainSubstring("behavior 'move'")))
+	})
+
+	It("should reject Behaviors whose type names are taken", func() {
+		type Grow struct{ hop }
+		ext := copycat{func() Behavior { return new(Grow) }}
+		Expect(func() { RegisterExtension(ext) }).To(PanicWith(ContainSubstring("whose name is taken")))
+		_, ok := NewBehavior("sprout", nil)
+		Expect(ok).To(BeFalse())
+	})
+})
+
+// grabby tries to replace a built-in Behavior.
+type grabby struct{}
+
+func (grabby) Name() string { return "grabby" }
+
+func (grabby) Register(r *Registry) {
+	r.Behavior("move", func() Behavior { return new(Move) })
+}
+
+// copycat registers a Behavior whose type has the name of another's.
+type copycat struct {
+	behavior func() Behavior
+}
+
+func (copycat) Name() string { return "copycat" }
+
+func (e copycat) Register(r *Registry) {
+	r.Behavior("sprout", e.behavior)
+}
@@ -0,0 +1,139 @@
+package ecoscript
+
+const (
+	// FieldNutrients is the Field that corpses enrich and plants feed on.
+	FieldNutrients = "nutrients"
+)
+
+// Field is a continuous quantity over every Cell of every Layer, like water,
+// nutrients or scent. Each tick, a fraction of each Cell's value diffuses
+// evenly to its neighbors and a fraction decays away.
+type Field struct {
+	Name      string
+	Diffusion float64
+	Decay     float64
+
+	// Color is the background of the Cells where the Field is strongest,
+	// when the Field is displayed in color.
+	Color Color
+
+	width  int
+	height int
+	values [][]float64
+}
+
+func newField(name string, diffusion, decay float64, width, height, depth int) *Field {
+	values := make([][]float64, depth)
+	for z := range values {
+		values[z] = make([]float64, width*height)
+	}
+	return &Field{
+		Name:      name,
+		Diffusion: diffusion,
+		Decay:     decay,
+		width:     width,
+		height:    height,
+		values:    values,
+	}
+}
+
+// At returns the value of the Field at the given Vector.
+func (f *Field) At(vec Vector) float64 {
+	return f.values[vec.Z][vec.Flatten(f.width)]
+}
+
+func (f *Field) Set(vec Vector, value float64) {
+	f.values[vec.Z][vec.Flatten(f.width)] = value
+}
+
+// Fill sets the value of the Field in every Cell.
+func (f *Field) Fill(value float64) {
+	for z := range f.values {
+		for i := range f.values[z] {
+			f.values[z][i] = value
+		}
+	}
+}
+
+// Add increases the value of the Field at the given Vector.
+func (f *Field) Add(vec Vector, amount float64) {
+	f.values[vec.Z][vec.Flatten(f.width)] += amount
+}
+
+// Take removes up to the given amount from the Field at the given Vector
+// and returns how much was removed.
+func (f *Field) Take(vec Vector, amount float64) float64 {
+	i := vec.Flatten(f.width)
+	have := f.values[vec.Z][i]
+	if amount > have {
+		amount = have
+	}
+	f.values[vec.Z][i] -= amount
+	return amount
+}
+
+// Max returns the highest value of the Field in the given Layer.
+func (f *Field) Max(z int) float64 {
+	max := 0.0
+	for _, value := range f.values[z] {
+		if value > max {
+			max = value
+		}
+	}
+	return max
+}
+
+// Uphill returns the walkable Vector next to origin where the Field is
+// strongest, if it is stronger than at origin.
+func (f *Field) Uphill(s Space, origin Vector) (Vector, bool) {
+	best, found := f.At(origin), false
+	dest := origin
+	vectors := s.ViewWalkableR(origin, 1)
+	for i := range vectors {
+		vec := vectors[i]
+		if value := f.At(vec); value > best {
+			best, dest, found = value, vec, true
+		}
+	}
+	return dest, found
+}
+
+// step diffuses and decays the Field by one tick.
+func (f *Field) step() {
+	for z := range f.values {
+		f.values[z] = f.diffuse(f.values[z])
+	}
+}
+
+func (f *Field) diffuse(values []float64) []float64 {
+	next := make([]float64, len(values))
+	keep := 1 - f.Decay
+
+	for y := 0; y < f.height; y++ {
+		for x := 0; x < f.width; x++ {
+			origin := Vec2D(x, y)
+			value := values[origin.Flatten(f.width)] * keep
+			if value == 0 {
+				continue
+			}
+
+			neighbors := origin.Radius(1)
+			inBounds := neighbors[:0]
+			for _, vec := range neighbors {
+				if vec.X >= 0 && vec.X < f.width && vec.Y >= 0 && vec.Y < f.height {
+					inBounds = append(inBounds, vec)
+				}
+			}
+
+			shared := value * f.Diffusion
+			if len(inBounds) == 0 {
+				shared = 0
+			}
+			next[origin.Flatten(f.width)] += value - shared
+			for _, vec := range inBounds {
+				next[vec.Flatten(f.width)] += shared / float64(len(inBounds))
+			}
+		}
+	}
+	return next
+}
@@ -0,0 +1,92 @@
+package ecoscript_test
+
+import (
+	. "github.com/dustinrohde/ecoscript"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Field", func() {
+	var (
+		world *World
+		field *Field
+	)
+
+	BeforeEach(func() {
+		world = NewWorld(3, 3, []string{"ground"})
+		field = world.AddField("scent", 0.8, 0)
+	})
+
+	It("should diffuse to neighbors without losing anything", func() {
+		field.Add(Vec(1, 1, 0), 80)
+		world.Tick()
+
+		Expect(field.At(Vec(1, 1, 0))).To(BeNumerically("~", 16))
+		Expect(field.At(Vec(0, 0, 0))).To(BeNumerically("~", 8))
+
+		total := 0.0
+		for y := 0; y < 3; y++ {
+			for x := 0; x < 3; x++ {
+				total += field.At(Vec(x, y, 0))
+			}
+		}
+		Expect(total).To(BeNumerically("~", 80))
+	})
+
+	It("should decay", func() {
+		field.Diffusion = 0
+		field.Decay = 0.5
+		field.Add(Vec(1, 1, 0), 80)
+		world.Tick()
+		Expect(field.At(Vec(1, 1, 0))).To(BeNumerically("~", 40))
+	})
+
+	It("should lead uphill", func() {
+		field.Add(Vec(2, 1, 0), 5)
+		dest, ok := field.Uphill(world, Vec(1, 1, 0))
+		Expect(ok).To(BeTrue())
+		Expect(dest.Equals(Vec(2, 1, 0))).To(BeTrue())
+
+		_, ok = field.Uphill(world, Vec(2, 1, 0))
+		Expect(ok).To(BeFalse())
+	})
+	Describe("nutrients", func() {
+		var nutrients *Field
+
+		BeforeEach(func() {
+			nutrients = world.AddField(FieldNutrients, 0, 0)
+		})
+
+		It("should be enriched by remains", func() {
+			ent := NewEntity("log", "=").AddAttributes(&Attributes{Energy: 1, Size: 2, Mass: 3})
+			exec, ok := world.Add(ent, Vec(1, 1, 0))
+			Expect(ok).To(BeTrue())
+			exec()
+
+			exec, ok = world.Destroy(ent, Vec(1, 1, 0))
+			Expect(ok).To(BeTrue())
+			exec()
+			Expect(nutrients.At(Vec(1, 1, 0))).To(BeNumerically("~", 6))
+		})
+
+		It("should not be enriched by prey that was eaten", func() {
+			berry := NewEntity("berry", "*").AddAttributes(&Attributes{Walkable: true, Energy: 1, Size: 2, Mass: 3}).
+				AddTraits("fruit")
+			bird := NewEntity("bird", "v").AddAttributes(&Attributes{Walkable: true, Energy: 1})
+			for x, ent := range []*Entity{berry, bird} {
+				exec, ok := world.Add(ent, Vec(x, 1, 0))
+				Expect(ok).To(BeTrue())
+				exec()
+			}
+
+			consume := new(Consume).Define(Properties{"diet": []Trait{"fruit"}})
+			_, exec := consume.Execute(world, bird, Vec(1, 1, 0))
+			Expect(exec).NotTo(BeNil())
+			exec()
+			Expect(world.Cell(Vec(0, 1, 0)).Exists(berry)).To(BeFalse())
+			Expect(bird.Attrs.Energy).To(Equal(7))
+			Expect(nutrients.At(Vec(0, 1, 0))).To(BeZero())
+		})
+	})
+})
@@ -0,0 +1,121 @@
+package ecoscript
+
+import (
+	"fmt"
+	"hash/fnv"
+	"io"
+	"sort"
+)
+
+// Digest is a hash of World state. Digests of collections are sums of the
+// Digests of their members, so they don't depend on the order in which
+// Entities are stacked in a Cell or on the order of iteration.
+type Digest uint64
+
+func (d Digest) String() string {
+	return fmt.Sprintf("%016x", uint64(d))
+}
+
+func digest(fn func(w io.Writer)) Digest {
+	h := fnv.New64a()
+	fn(h)
+	return Digest(h.Sum64())
+}
+
+// Hash returns the Digest of the World as a string. Worlds with the same
+// Hash play out identically, unless an activity in progress was planned
+// differently in one of them: what an activity will do when it's done isn't
+// hashed, only how far along it is.
+func (w *World) Hash() string {
+	return w.Digest().String()
+}
+
+// Digest hashes the whole state of the World: every Cell, every Field, the
+// clock, the weather and the random source.
+func (w *World) Digest() Digest {
+	var sum Digest
+	for z, layer := range w.layers {
+		sum += w.LayerDigest(z)
+		sum += digest(func(h io.Writer) {
+			fmt.Fprintf(h, "layer %d %s %d %d %t %t", z, layer.name, layer.above, layer.below,
+				layer.blocksLight, layer.permeable)
+		})
+	}
+	for _, field := range w.fields {
+		sum += field.digest()
+	}
+	for i, wx := range w.weather {
+		sum += wx.digest(i)
+	}
+	sum += digest(func(h io.Writer) {
+		fmt.Fprintf(h, "world %d %d %d %d %d", w.clock.Ticks(), w.src.seed, w.src.draws, w.nextID,
+			len(w.fields))
+	})
+	return sum
+}
+
+// LayerDigest hashes every Cell in the Layer with the given Z index.
+func (w *World) LayerDigest(z int) Digest {
+	var sum Digest
+	layer := w.Layer(z)
+	for i := range layer.cells {
+		sum += w.CellDigest(Vec(i%w.width, i/w.width, z))
+	}
+	return sum
+}
+
+// CellDigest hashes the position and Terrain of a Cell and the Entities in
+// it.
+func (w *World) CellDigest(vec Vector) Digest {
+	cell := w.Cell(vec)
+	sum := digest(func(h io.Writer) {
+		fmt.Fprintf(h, "cell %d %d %d %+v", vec.X, vec.Y, vec.Z, cell.terrain)
+	})
+	for _, ent := range cell.Entities() {
+		sum += EntityDigest(ent)
+	}
+	return sum
+}
+
+// EntityDigest hashes the ID, attributes, traits and Behavior state of an
+// Entity, and the progress of its activity.
+func EntityDigest(ent *Entity) Digest {
+	traits := make([]string, len(ent.Traits))
+	for i := range ent.Traits {
+		traits[i] = string(ent.Traits[i])
+	}
+	sort.Strings(traits)
+
+	keys := make([]string, 0, len(ent.Behaviors))
+	for key := range ent.Behaviors {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	return digest(func(h io.Writer) {
+		fmt.Fprintf(h, "entity %d %s %s %s %+v %v", ent.id, ent.species, ent.Name, ent.Symbol,
+			*ent.Attrs, traits)
+		if ent.rest > 0 {
+			fmt.Fprintf(h, " rest %d", ent.rest)
+		}
+		if ent.activity.InProgress() {
+			ticks, needed := ent.activity.Progress()
+			fmt.Fprintf(h, " doing %s %d/%d", ent.behavior, ticks, needed)
+		}
+		for _, key := range keys {
+			fmt.Fprintf(h, " %s%+v", key, ent.Behaviors[key])
+		}
+	})
+}
+
+func (f *Field) digest() Digest {
+	return digest(func(h io.Writer) {
+		fmt.Fprintf(h, "field %s %g %g %v", f.Name, f.Diffusion, f.Decay, f.values)
+	})
+}
+
+func (wx *Weather) digest(i int) Digest {
+	return digest(func(h io.Writer) {
+		fmt.Fprintf(h, "weather %d %+v", i, *wx)
+	})
+}
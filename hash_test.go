@@ -0,0 +1,124 @@
+package ecoscript_test
+
+import (
+	"bytes"
+
+	. "github.com/dustinrohde/ecoscript"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Digest", func() {
+	var (
+		newWorld func(order ...int) (*World, []*Entity)
+	)
+
+	BeforeEach(func() {
+		newWorld = func(order ...int) (*World, []*Entity) {
+			world := NewWorld(3, 3, []string{"ground"})
+			world.Seed(1)
+			world.AddSpecies(&Species{
+				Key:   "stone",
+				Name:  "stone",
+				Attrs: Attributes{Walkable: true, Energy: 10},
+			})
+			ents := make([]*Entity, 3)
+			for i := range ents {
+				ents[i] = world.Species("stone").Spawn(world)
+				ents[i].Attrs.Energy *= i + 1
+			}
+			for _, i := range order {
+				exec, ok := world.Add(ents[i], Vec(1, 1, 0))
+				Expect(ok).To(BeTrue())
+				exec()
+			}
+			return world, ents
+		}
+	})
+
+	It("should not depend on the order of Entities in a Cell", func() {
+		a, ents := newWorld(0, 1, 2)
+		b, _ := newWorld()
+		for _, i := range []int{2, 0, 1} {
+			exec, ok := b.Add(ents[i], Vec(1, 1, 0))
+			Expect(ok).To(BeTrue())
+			exec()
+		}
+
+		Expect(a.Digest()).To(Equal(b.Digest()))
+		Expect(CompareWorlds(a, b)).To(BeNil())
+	})
+
+	It("should find the Cell and Entity that differ", func() {
+		a, _ := newWorld(0, 1, 2)
+		b, ents := newWorld(2, 1, 0)
+		ents[1].Attrs.Energy++
+
+		d := CompareWorlds(a, b)
+		Expect(d).NotTo(BeNil())
+		Expect(d.Cell.Equals(Vec(1, 1, 0))).To(BeTrue())
+		Expect(*d.Entity).To(Equal(ents[1].ID()))
+	})
+
+	It("should tell apart Entities that are further along in their activities", func() {
+		world := NewWorld(3, 3, []string{"ground"})
+		world.AddSpecies(&Species{
+			Key:       "grass",
+			Name:      "grass",
+			Attrs:     Attributes{Walkable: true, Energy: 10},
+			Abilities: []*Ability{{Name: "grow"}},
+		})
+		ent := world.Species("grass").Spawn(world)
+		exec, ok := world.Add(ent, Vec(1, 1, 0))
+		Expect(ok).To(BeTrue())
+		exec()
+
+		snap, err := world.Snapshot()
+		Expect(err).NotTo(HaveOccurred())
+		for key := range ent.Behaviors {
+			snap.Entities[0].Behavior = key
+		}
+		a, b := NewWorld(0, 0, nil), NewWorld(0, 0, nil)
+		snap.Entities[0].Activity = &ActivitySnapshot{Ticks: 1, Needed: 3}
+		Expect(a.Restore(snap)).To(Succeed())
+		snap.Entities[0].Activity.Ticks = 2
+		Expect(b.Restore(snap)).To(Succeed())
+
+		d := CompareWorlds(a, b)
+		Expect(d).NotTo(BeNil())
+		Expect(*d.Entity).To(Equal(ent.ID()))
+	})
+
+	It("should find the first tick at which recorded runs differ", func() {
+		record := func(tamper bool) *Recording {
+			world, _ := newWorld(0, 1, 2)
+			world.AddField(FieldNutrients, 0.2, 0)
+
+			var buf bytes.Buffer
+			recorder, err := NewRecorder(&buf, world, 5, 4)
+			Expect(err).NotTo(HaveOccurred())
+			for i := 0; i < 10; i++ {
+				if tamper && i == 6 {
+					world.Field(FieldNutrients).Add(Vec(0, 2, 0), 1)
+				}
+				Expect(recorder.Tick()).To(Succeed())
+			}
+			recorder.Close()
+
+			rec, err := ReadRecording(&buf)
+			Expect(err).NotTo(HaveOccurred())
+			return rec
+		}
+
+		d, err := DiffRecordings(record(false), record(false))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(d).To(BeNil())
+
+		d, err = DiffRecordings(record(false), record(true))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(d).NotTo(BeNil())
+		Expect(d.Tick).To(Equal(7))
+		Expect(d.Reason).To(ContainSubstring("nutrients"))
+	})
+})
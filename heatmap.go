@@ -0,0 +1,215 @@
+package ecoscript
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// Heatmap measures something in each Cell of a Layer, to color the Cell by
+// instead of showing its Entities.
+type Heatmap interface {
+	Name() string
+	Value(l *Layer, x, y int) float64
+}
+
+// heatRamp colors Heatmaps from lowest to highest.
+var heatRamp = []Color{"#000004", "#3b0f70", "#8c2981", "#de4968", "#fe9f6d", "#fcfdbf"}
+
+// heatColor returns the Color of a level of heat, from 0 to 1.
+func heatColor(level float64) Color {
+	if level <= 0 {
+		return heatRamp[0]
+	}
+	if level >= 1 {
+		return heatRamp[len(heatRamp)-1]
+	}
+	pos := level * float64(len(heatRamp)-1)
+	i := int(pos)
+	frac := pos - float64(i)
+	a, _ := heatRamp[i].resolve()
+	b, _ := heatRamp[i+1].resolve()
+	mix := func(x, y uint8) uint8 {
+		return uint8(float64(x) + (float64(y)-float64(x))*frac)
+	}
+	return rgbColor{-1, mix(a.r, b.r), mix(a.g, b.g), mix(a.b, b.b)}.hex()
+}
+
+func (c rgbColor) hex() Color {
+	return Color(fmt.Sprintf("#%02x%02x%02x", c.r, c.g, c.b))
+}
+
+// heatSum returns the value of a Heatmap at X and Y, summed over the given
+// Layers.
+func heatSum(h Heatmap, layers []*Layer, x, y int) float64 {
+	var sum float64
+	for _, l := range layers {
+		sum += h.Value(l, x, y)
+	}
+	return sum
+}
+
+// heatMax returns the highest value of a Heatmap in the given Layers, summed
+// over them.
+func heatMax(h Heatmap, layers []*Layer) float64 {
+	var max float64
+	if len(layers) == 0 {
+		return max
+	}
+	for y := 0; y < layers[0].height; y++ {
+		for x := 0; x < layers[0].width; x++ {
+			if sum := heatSum(h, layers, x, y); sum > max {
+				max = sum
+			}
+		}
+	}
+	return max
+}
+
+// Density is a Heatmap of the number of Entities in each Cell.
+type Density struct{}
+
+func (Density) Name() string {
+	return "density"
+}
+
+func (Density) Value(l *Layer, x, y int) float64 {
+	return float64(len(l.Cell(Vec2D(x, y)).Entities()))
+}
+
+// Energy is a Heatmap of the total energy of the Entities in each Cell.
+type Energy struct{}
+
+func (Energy) Name() string {
+	return "energy"
+}
+
+func (Energy) Value(l *Layer, x, y int) float64 {
+	var total int
+	for _, ent := range l.Cell(Vec2D(x, y)).Entities() {
+		total += ent.Attrs.Energy
+	}
+	return float64(total)
+}
+
+// FieldHeatmap is a Heatmap of the values of a Field.
+type FieldHeatmap struct {
+	Field *Field
+}
+
+func (h FieldHeatmap) Name() string {
+	return "field:" + h.Field.Name
+}
+
+func (h FieldHeatmap) Value(l *Layer, x, y int) float64 {
+	return h.Field.At(Vec(x, y, l.z))
+}
+
+// EventHeatmap is a Heatmap of where Events of some types happened over the
+// last Window ticks, or ever if Window is 0. Entities that move are counted
+// where they move to.
+type EventHeatmap struct {
+	name   string
+	Window int
+	world  *World
+
+	// total is the number of Events in each Cell within the window, and
+	// counts holds them by tick, oldest first, to forget them by.
+	total  map[Vector]int
+	counts []eventCounts
+
+	unsubscribe func()
+}
+
+type eventCounts struct {
+	tick   int
+	counts map[Vector]int
+}
+
+// NewVisits creates an EventHeatmap of how often Entities were spawned or
+// moved into each Cell. Close it when done.
+func NewVisits(w *World, window int) *EventHeatmap {
+	return NewEventHeatmap(w, "visits", window, EventSpawned, EventMoved)
+}
+
+// NewDeaths creates an EventHeatmap of where Entities died. Close it when
+// done.
+func NewDeaths(w *World, window int) *EventHeatmap {
+	return NewEventHeatmap(w, "deaths", window, EventDied)
+}
+
+// NewEventHeatmap creates an EventHeatmap of the given Event types that
+// listens to the World. Close it when done.
+func NewEventHeatmap(w *World, name string, window int, types ...EventType) *EventHeatmap {
+	h := &EventHeatmap{name: name, Window: window, world: w, total: make(map[Vector]int)}
+	h.unsubscribe = w.Events().Subscribe(Filter{Types: types}, func(ev Event) {
+		vec := ev.Vec
+		if ev.Type == EventMoved {
+			vec = ev.Dest
+		}
+		h.total[vec]++
+		if h.Window == 0 {
+			return
+		}
+		if n := len(h.counts); n == 0 || h.counts[n-1].tick != ev.Tick {
+			h.counts = append(h.counts, eventCounts{ev.Tick, make(map[Vector]int)})
+		}
+		h.counts[len(h.counts)-1].counts[vec]++
+		h.forget(ev.Tick)
+	})
+	return h
+}
+
+// forget drops the Events from before the window that ends at the given
+// tick.
+func (h *EventHeatmap) forget(tick int) {
+	for len(h.counts) > 0 && h.counts[0].tick <= tick-h.Window {
+		for vec, n := range h.counts[0].counts {
+			if h.total[vec] -= n; h.total[vec] == 0 {
+				delete(h.total, vec)
+			}
+		}
+		h.counts = h.counts[1:]
+	}
+}
+
+// Close stops listening to the World.
+func (h *EventHeatmap) Close() {
+	h.unsubscribe()
+}
+
+func (h *EventHeatmap) Name() string {
+	return h.name
+}
+
+func (h *EventHeatmap) Value(l *Layer, x, y int) float64 {
+	if h.Window > 0 {
+		h.forget(h.world.Clock().Ticks())
+	}
+	return float64(h.total[Vec(x, y, l.z)])
+}
+
+// ParseHeatmap creates the Heatmap with the given name: "density",
+// "energy", "visits", "deaths" or "field:NAME". Visits and deaths are
+// counted over the last window ticks, or ever if window is 0, from now on.
+func ParseHeatmap(w *World, name string, window int) (Heatmap, error) {
+	switch name {
+	case "density":
+		return Density{}, nil
+	case "energy":
+		return Energy{}, nil
+	case "visits":
+		return NewVisits(w, window), nil
+	case "deaths":
+		return NewDeaths(w, window), nil
+	}
+	if strings.HasPrefix(name, "field:") {
+		field := w.Field(strings.TrimPrefix(name, "field:"))
+		if field == nil {
+			return nil, errors.Errorf("no field '%s'", strings.TrimPrefix(name, "field:"))
+		}
+		return FieldHeatmap{field}, nil
+	}
+	return nil, errors.Errorf("unknown heatmap '%s'", name)
+}
@@ -0,0 +1,95 @@
+package ecoscript_test
+
+import (
+	"image/color"
+
+	. "github.com/dustinrohde/ecoscript"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Heatmap", func() {
+	var world *World
+
+	add := func(energy int, vec Vector) {
+		ent := NewEntity("sheep", "&").AddAttributes(&Attributes{Walkable: true, Energy: energy})
+		exec, ok := world.Add(ent, vec)
+		Expect(ok).To(BeTrue())
+		exec()
+	}
+
+	BeforeEach(func() {
+		world = NewWorld(3, 1, []string{"ground", "sky"})
+		add(10, Vec(0, 0, 0))
+		add(5, Vec(0, 0, 0))
+		add(4, Vec(2, 0, 0))
+		add(1, Vec(2, 0, 1))
+	})
+
+	It("should measure density and energy", func() {
+		Expect(Density{}.Value(world.Layer(0), 0, 0)).To(Equal(2.0))
+		Expect(Density{}.Value(world.Layer(0), 1, 0)).To(Equal(0.0))
+		Expect(Energy{}.Value(world.Layer(0), 0, 0)).To(Equal(15.0))
+		Expect(Energy{}.Value(world.Layer(1), 2, 0)).To(Equal(1.0))
+	})
+
+	It("should measure a Field", func() {
+		world.AddField("scent", 0, 0).Set(Vec(1, 0, 0), 3)
+		h, err := ParseHeatmap(world, "field:scent", 0)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(h.Name()).To(Equal("field:scent"))
+		Expect(h.Value(world.Layer(0), 1, 0)).To(Equal(3.0))
+	})
+
+	It("should reject unknown Heatmaps", func() {
+		_, err := ParseHeatmap(world, "warmth", 0)
+		Expect(err).To(HaveOccurred())
+		_, err = ParseHeatmap(world, "field:scent", 0)
+		Expect(err).To(HaveOccurred())
+	})
+
+	It("should count Events where they happened within the window", func() {
+		deaths := NewDeaths(world, 2)
+		defer deaths.Close()
+		visits := NewVisits(world, 0)
+		defer visits.Close()
+
+		world.Events().Publish(Event{Type: EventDied, Tick: 0, Vec: Vec(1, 0, 0)})
+		world.Events().Publish(Event{Type: EventMoved, Tick: 0, Vec: Vec(0, 0, 0), Dest: Vec(1, 0, 0)})
+		world.Tick()
+		world.Events().Publish(Event{Type: EventDied, Tick: 1, Vec: Vec(1, 0, 0)})
+		Expect(deaths.Value(world.Layer(0), 1, 0)).To(Equal(2.0))
+		Expect(visits.Value(world.Layer(0), 1, 0)).To(Equal(1.0))
+		Expect(visits.Value(world.Layer(0), 0, 0)).To(Equal(0.0))
+
+		world.Tick()
+		Expect(deaths.Value(world.Layer(0), 1, 0)).To(Equal(1.0))
+		world.Tick()
+		Expect(deaths.Value(world.Layer(0), 1, 0)).To(Equal(0.0))
+		Expect(visits.Value(world.Layer(0), 1, 0)).To(Equal(1.0))
+	})
+
+	It("should shade a Layer in Monochrome", func() {
+		opts := DisplayOptions{Heatmap: Density{}}
+		Expect(world.Layer(0).DisplayWith(opts)).To(Equal("@ =\n"))
+	})
+
+	It("should color a Layer", func() {
+		opts := DisplayOptions{Mode: TrueColor, Heatmap: Energy{}}
+		tiles := world.Layer(0).Tiles(opts)
+		Expect(tiles[0][0]).To(Equal("\x1b[48;2;252;253;191m \x1b[0m"))
+		Expect(tiles[0][1]).To(Equal("\x1b[48;2;0;0;4m \x1b[0m"))
+	})
+
+	It("should sum over composited Layers", func() {
+		opts := CompositeOptions{DisplayOptions: DisplayOptions{Heatmap: Density{}}}
+		Expect(world.Composite(opts)).To(Equal("@ @\n"))
+	})
+
+	It("should fill an Image", func() {
+		img := world.Image(ImageOptions{TileSize: 2, Heatmap: Energy{}})
+		Expect(img.At(0, 0)).To(Equal(color.RGBA{252, 253, 191, 0xff}))
+		Expect(img.At(2, 0)).To(Equal(color.RGBA{0, 0, 4, 0xff}))
+	})
+})
@@ -0,0 +1,194 @@
+package ecoscript
+
+import (
+	"hash/fnv"
+	"image"
+	"image/color"
+	"image/color/palette"
+	"image/draw"
+	"image/gif"
+	"image/png"
+	"io"
+	"sort"
+	"time"
+)
+
+// DefaultTileSize is the width and height of a Cell in images, in pixels.
+const DefaultTileSize = 8
+
+// ImageOptions control how Image draws a World.
+type ImageOptions struct {
+	// TileSize is the width and height of each Cell in pixels. Zero means
+	// DefaultTileSize.
+	TileSize int
+
+	// Palette colors Entities by Species key, or by name for Entities of
+	// no Species, over the foreground Colors of their Styles.
+	Palette map[string]Color
+
+	// Layers are the Z indexes of the Layers to composite, or all of them
+	// if empty.
+	Layers []int
+
+	// Terrain fills each Cell with the Color of its Terrain.
+	Terrain bool
+
+	// Heatmap, if set, fills each Cell by its value summed over the Layers,
+	// relative to the highest, instead of showing its Entities.
+	Heatmap Heatmap
+}
+
+var (
+	// imageBackground fills Cells with nothing else to show.
+	imageBackground = color.RGBA{0x10, 0x10, 0x10, 0xff}
+
+	// entityColors are given to Entities with no Color of their own,
+	// picked by their name.
+	entityColors = []Color{
+		"#e6194b", "#3cb44b", "#ffe119", "#4363d8", "#f58231", "#911eb4",
+		"#46f0f0", "#f032e6", "#bcf60c", "#fabebe", "#008080", "#e6beff",
+	}
+)
+
+// Image draws the World as Composite does, with the Entity shown in each
+// Cell as a square of its color.
+func (w *World) Image(opts ImageOptions) *image.RGBA {
+	size := opts.TileSize
+	if size <= 0 {
+		size = DefaultTileSize
+	}
+	inset := size / 8
+
+	img := image.NewRGBA(image.Rect(0, 0, w.width*size, w.height*size))
+	draw.Draw(img, img.Bounds(), image.NewUniform(imageBackground), image.Point{}, draw.Src)
+
+	layers := w.compositeLayers(opts.Layers)
+	var max float64
+	if opts.Heatmap != nil {
+		max = heatMax(opts.Heatmap, layers)
+	}
+	for y := 0; y < w.height; y++ {
+		for x := 0; x < w.width; x++ {
+			tile := image.Rect(x*size, y*size, (x+1)*size, (y+1)*size)
+			if opts.Heatmap != nil {
+				var level float64
+				if max > 0 {
+					level = heatSum(opts.Heatmap, layers, x, y) / max
+				}
+				c, _ := heatColor(level).resolve()
+				draw.Draw(img, tile, image.NewUniform(c.rgba()), image.Point{}, draw.Src)
+				continue
+			}
+
+			top, _, cells := sight(layers, x, y)
+
+			if opts.Terrain {
+				for _, cell := range cells {
+					if c, err := cell.cell.terrain.Color.resolve(); err == nil {
+						draw.Draw(img, tile, image.NewUniform(c.rgba()), image.Point{}, draw.Src)
+						break
+					}
+				}
+			}
+			if top != nil {
+				draw.Draw(img, tile.Inset(inset), image.NewUniform(opts.color(top)), image.Point{}, draw.Src)
+			}
+		}
+	}
+	return img
+}
+
+// color returns the color to draw an Entity in.
+func (opts ImageOptions) color(ent *Entity) color.RGBA {
+	key := ent.Species()
+	if key == "" {
+		key = ent.Name
+	}
+	return opts.colorOf(key, ent.Name, ent.Style)
+}
+
+// colorOf returns the color to draw Entities with the given Species key,
+// name and Style in.
+func (opts ImageOptions) colorOf(key, name string, style Style) color.RGBA {
+	if c, err := opts.Palette[key].resolve(); err == nil {
+		return c.rgba()
+	}
+	if c, err := style.Foreground.resolve(); err == nil {
+		return c.rgba()
+	}
+
+	h := fnv.New32a()
+	h.Write([]byte(name))
+	c, _ := entityColors[h.Sum32()%uint32(len(entityColors))].resolve()
+	return c.rgba()
+}
+
+func (c rgbColor) rgba() color.RGBA {
+	return color.RGBA{c.r, c.g, c.b, 0xff}
+}
+
+// WritePNG writes an Image of the World as a PNG.
+func WritePNG(out io.Writer, w *World, opts ImageOptions) error {
+	return png.Encode(out, w.Image(opts))
+}
+
+// WriteGIF writes an animated GIF of the World as it plays out, ticking it
+// every ticks between frames and showing each frame for delay. The first
+// frame shows the World as it is.
+func WriteGIF(out io.Writer, w *World, frames, every int, delay time.Duration, opts ImageOptions) error {
+	images := make([]*image.RGBA, 0, frames)
+	colors := make(map[color.RGBA]bool)
+	for i := 0; i < frames; i++ {
+		if i > 0 {
+			for j := 0; j < every; j++ {
+				w.Tick()
+			}
+		}
+		img := w.Image(opts)
+		for p := 0; p < len(img.Pix); p += 4 {
+			colors[color.RGBA{img.Pix[p], img.Pix[p+1], img.Pix[p+2], img.Pix[p+3]}] = true
+		}
+		images = append(images, img)
+	}
+
+	pal := gifPalette(colors)
+	anim := &gif.GIF{
+		Image: make([]*image.Paletted, len(images)),
+		Delay: make([]int, len(images)),
+	}
+	for i, img := range images {
+		frame := image.NewPaletted(img.Bounds(), pal)
+		draw.Draw(frame, frame.Bounds(), img, image.Point{}, draw.Src)
+		anim.Image[i] = frame
+		anim.Delay[i] = int(delay / (10 * time.Millisecond))
+	}
+	return gif.EncodeAll(out, anim)
+}
+
+// gifPalette returns the colors used in a GIF's frames as its palette, or
+// a general-purpose palette if there are too many of them.
+func gifPalette(colors map[color.RGBA]bool) color.Palette {
+	if len(colors) > 256 {
+		return palette.Plan9
+	}
+	list := make([]color.RGBA, 0, len(colors))
+	for c := range colors {
+		list = append(list, c)
+	}
+	sort.Slice(list, func(i, j int) bool {
+		a, b := list[i], list[j]
+		if a.R != b.R {
+			return a.R < b.R
+		}
+		if a.G != b.G {
+			return a.G < b.G
+		}
+		return a.B < b.B
+	})
+
+	pal := make(color.Palette, len(list))
+	for i, c := range list {
+		pal[i] = c
+	}
+	return pal
+}
@@ -0,0 +1,64 @@
+package ecoscript_test
+
+import (
+	"bytes"
+	"image/color"
+	"image/gif"
+	"image/png"
+	"time"
+
+	. "github.com/dustinrohde/ecoscript"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Image", func() {
+	var world *World
+
+	BeforeEach(func() {
+		world = NewWorld(3, 2, []string{"ground"})
+		world.Seed(1)
+		sheep := NewEntity("sheep", "&").AddAttributes(&Attributes{Energy: 10})
+		sheep.Style = Style{Foreground: "#ffffff"}
+		exec, ok := world.Add(sheep, Vec(1, 0, 0))
+		Expect(ok).To(BeTrue())
+		exec()
+		world.Cell(Vec(2, 1, 0)).SetTerrain(Terrain{Kind: "water", Color: "#0000ff"})
+	})
+
+	It("should draw each Cell as a tile", func() {
+		img := world.Image(ImageOptions{TileSize: 4, Terrain: true})
+		Expect(img.Bounds().Dx()).To(Equal(12))
+		Expect(img.Bounds().Dy()).To(Equal(8))
+
+		white := color.RGBA{0xff, 0xff, 0xff, 0xff}
+		Expect(img.At(5, 1)).To(Equal(white))
+		Expect(img.At(1, 1)).NotTo(Equal(white))
+		Expect(img.At(9, 5)).To(Equal(color.RGBA{0, 0, 0xff, 0xff}))
+	})
+
+	It("should color Entities from a palette", func() {
+		img := world.Image(ImageOptions{TileSize: 4, Palette: map[string]Color{"sheep": "red"}})
+		Expect(img.At(5, 1)).To(Equal(color.RGBA{205, 0, 0, 0xff}))
+	})
+
+	It("should write a PNG", func() {
+		var buf bytes.Buffer
+		Expect(WritePNG(&buf, world, ImageOptions{})).To(Succeed())
+		img, err := png.Decode(&buf)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(img.Bounds().Dx()).To(Equal(3 * DefaultTileSize))
+	})
+
+	It("should write an animated GIF of ticks", func() {
+		var buf bytes.Buffer
+		Expect(WriteGIF(&buf, world, 4, 2, 50*time.Millisecond, ImageOptions{TileSize: 2})).To(Succeed())
+		Expect(world.Clock().Ticks()).To(Equal(6))
+
+		anim, err := gif.DecodeAll(&buf)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(anim.Image).To(HaveLen(4))
+		Expect(anim.Delay).To(Equal([]int{5, 5, 5, 5}))
+	})
+})
@@ -0,0 +1,95 @@
+package ecoscript
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// LegendEntry is a symbol shown in a Layer, the name of the Entities that are
+// drawn with it, and how many of them there are.
+type LegendEntry struct {
+	Symbol string
+	Name   string
+	Style  Style
+	Count  int
+}
+
+// Legend lists the Entities in the Layer by symbol.
+func (l *Layer) Legend() []LegendEntry {
+	return legend([]*Layer{l})
+}
+
+// Legend lists the Entities in the given Layers, or all of them, by symbol.
+func (w *World) Legend(zs []int) []LegendEntry {
+	return legend(w.compositeLayers(zs))
+}
+
+func legend(layers []*Layer) []LegendEntry {
+	type key struct{ symbol, name string }
+	index := make(map[key]int)
+	var entries []LegendEntry
+	for _, l := range layers {
+		for _, cell := range l.cells {
+			for _, ent := range cell.Entities() {
+				k := key{ent.Symbol, ent.Name}
+				i, ok := index[k]
+				if !ok {
+					i = len(entries)
+					index[k] = i
+					entries = append(entries, LegendEntry{Symbol: ent.Symbol, Name: ent.Name, Style: ent.Style})
+				}
+				entries[i].Count++
+			}
+		}
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		if entries[i].Symbol != entries[j].Symbol {
+			return entries[i].Symbol < entries[j].Symbol
+		}
+		return entries[i].Name < entries[j].Name
+	})
+	return entries
+}
+
+// LegendLines draws a legend a line per entry, with each symbol in its Style.
+func LegendLines(entries []LegendEntry, mode ColorMode) []string {
+	var width, digits int
+	for _, entry := range entries {
+		if len(entry.Name) > width {
+			width = len(entry.Name)
+		}
+		if n := len(fmt.Sprint(entry.Count)); n > digits {
+			digits = n
+		}
+	}
+	lines := make([]string, len(entries))
+	for i, entry := range entries {
+		lines[i] = fmt.Sprintf("%s %-*s %*d",
+			entry.Style.Render(entry.Symbol, mode), width, entry.Name, digits, entry.Count)
+	}
+	return lines
+}
+
+// beside draws rows of tiles with lines of text beside them, padding the
+// rows out to width where there are more lines than rows.
+func beside(tiles [][]string, width int, lines []string) string {
+	const gap = "  "
+
+	var b strings.Builder
+	for y := 0; y < len(tiles) || y < len(lines); y++ {
+		if y < len(tiles) {
+			for _, tile := range tiles[y] {
+				b.WriteString(tile)
+			}
+		} else {
+			b.WriteString(strings.Repeat(" ", width))
+		}
+		if y < len(lines) {
+			b.WriteString(gap)
+			b.WriteString(lines[y])
+		}
+		b.WriteString("\n")
+	}
+	return b.String()
+}
@@ -0,0 +1,63 @@
+package ecoscript_test
+
+import (
+	. "github.com/dustinrohde/ecoscript"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Legend", func() {
+	var world *World
+
+	add := func(name, symbol string, vec Vector) {
+		ent := NewEntity(name, symbol).AddAttributes(&Attributes{Walkable: true})
+		exec, ok := world.Add(ent, vec)
+		Expect(ok).To(BeTrue())
+		exec()
+	}
+
+	BeforeEach(func() {
+		world = NewWorld(3, 2, []string{"ground", "sky"})
+		add("sheep", "&", Vec(0, 0, 0))
+		add("sheep", "&", Vec(2, 1, 0))
+		add("grass", ",", Vec(1, 0, 0))
+		add("bird", "v", Vec(1, 1, 1))
+	})
+
+	It("should count the Entities of each symbol", func() {
+		Expect(world.Layer(0).Legend()).To(Equal([]LegendEntry{
+			{Symbol: "&", Name: "sheep", Count: 2},
+			{Symbol: ",", Name: "grass", Count: 1},
+		}))
+		Expect(world.Legend(nil)).To(HaveLen(3))
+	})
+
+	It("should draw empty Cells with the empty tile", func() {
+		opts := DisplayOptions{EmptyTile: "."}
+		Expect(world.Layer(0).DisplayWith(opts)).To(Equal("&,.\n..&\n"))
+	})
+
+	It("should draw the legend beside the map", func() {
+		opts := DisplayOptions{EmptyTile: ".", Legend: true}
+		Expect(world.Layer(1).DisplayWith(opts)).To(Equal(
+			"...  v bird 1\n" +
+				".v.\n"))
+		Expect(world.Composite(CompositeOptions{DisplayOptions: opts})).To(Equal(
+			"&,.  & sheep 2\n" +
+				".v&  , grass 1\n" +
+				"     v bird  1\n"))
+	})
+
+	It("should keep display settings in Snapshots", func() {
+		world.SetEmptyTile(".")
+		world.SetDisplayLegend(true)
+		snap, err := world.Snapshot()
+		Expect(err).NotTo(HaveOccurred())
+
+		other := NewWorld(1, 1, []string{"ground"})
+		Expect(other.Restore(snap)).To(Succeed())
+		Expect(other.EmptyTile()).To(Equal("."))
+		Expect(other.DisplayLegend()).To(BeTrue())
+	})
+})
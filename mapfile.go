@@ -1,312 +1,951 @@
 package ecoscript
 
+import (
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	multierror "github.com/hashicorp/go-multierror"
+	"github.com/pkg/errors"
+	"github.com/spf13/viper"
+)
+
+// Mapfile is the Settings object that a Mapfile will be marshaled into.
+type Mapfile struct {
+	Defaults struct {
+		EmptyTile     string `mapstructure:"empty_tile"`
+		DisplayLegend bool   `mapstructure:"display_legend"`
+	} `mapstructure:"defaults"`
+
+	Atlas struct {
+		Map struct {
+			layers     [][][]string
+			terrain    [][][]string
+			layerNames []string
+			Inline     []*layerEntry `mapstructure:"inline"`
+			Files      []*layerEntry `mapstructure:"files"`
+		} `mapstructure:"map"`
+
+		RawLegend []*legendEntry `mapstructure:"legend"`
+		Legend    map[string]string
+
+		RawTerrain    []*terrainEntry `mapstructure:"terrain"`
+		TerrainLegend map[string]Terrain
+	} `mapstructure:"atlas"`
+
+	Environment struct {
+		Clock      *Clock         `mapstructure:"clock"`
+		RawSeasons []*seasonEntry `mapstructure:"seasons"`
+		Weather    []*Weather     `mapstructure:"weather"`
+		Fields     []*fieldEntry  `mapstructure:"fields"`
+	} `mapstructure:"environment"`
+
+	Entities map[string]*Species `mapstructure:"entities"`
+
+	// Extensions lists the names of the Extensions that the Mapfile relies
+	// on, which must be linked into the program.
+	Extensions []string `mapstructure:"extensions"`
+
+	// Stop lists the conditions on which headless runs stop, as parsed by
+	// ParseStopCondition. YAML reads conditions like "extinct: fox" as
+	// maps, so RawStop takes both forms.
+	RawStop []interface{} `mapstructure:"stop"`
+	Stop    []string      `mapstructure:"-"`
+
+	// dir is the directory of the Mapfile, which map files and script
+	// files are relative to.
+	dir string
+}
+
+// layerEntry describes one Layer of the map. Above and Below name the Layer
+// that this one lies directly on top of or beneath, respectively. Terrain is
+// an optional grid of symbols from ``atlas.terrain``, like Grid. DrawOrder
+// and Opaque control how the Layer is composited with the others.
+type layerEntry struct {
+	Name        string `mapstructure:"name"`
+	Grid        string `mapstructure:"grid"`
+	Terrain     string `mapstructure:"terrain"`
+	Above       string `mapstructure:"above"`
+	Below       string `mapstructure:"below"`
+	BlocksLight bool   `mapstructure:"blocks_light"`
+	Permeable   bool   `mapstructure:"permeable"`
+	DrawOrder   int    `mapstructure:"draw_order"`
+	Opaque      bool   `mapstructure:"opaque"`
+}
+
+type legendEntry struct {
+	Symbol    string `mapstructure:"symbol"`
+	EntityKey string `mapstructure:"entity"`
+}
+
+// terrainEntry maps a terrain symbol to its properties. Unset properties
+// take their value from DefaultTerrain.
+type terrainEntry struct {
+	Symbol     string   `mapstructure:"symbol"`
+	Kind       string   `mapstructure:"kind"`
+	Moisture   *float64 `mapstructure:"moisture"`
+	Fertility  *float64 `mapstructure:"fertility"`
+	Elevation  *float64 `mapstructure:"elevation"`
+	MoveCost   *int     `mapstructure:"move_cost"`
+	Impassable bool     `mapstructure:"impassable"`
+	Color      Color    `mapstructure:"color"`
+}
+
+// seasonEntry describes a Season. Unset factors default to 1.
+type seasonEntry struct {
+	Name       string   `mapstructure:"name"`
+	Growth     *float64 `mapstructure:"growth"`
+	Metabolism *float64 `mapstructure:"metabolism"`
+}
+
+// fieldEntry describes a Field and the value it starts with in every Cell.
+type fieldEntry struct {
+	Name      string  `mapstructure:"name" validate:"required"`
+	Diffusion float64 `mapstructure:"diffusion" validate:"min=0,max=1"`
+	Decay     float64 `mapstructure:"decay" validate:"min=0,max=1"`
+	Initial   float64 `mapstructure:"initial" validate:"min=0"`
+	Color     Color   `mapstructure:"color"`
+}
+
+// ParseMapfile reads and parses a Mapfile at the given file path.
 //
-//import (
-//	"io/ioutil"
-//	"log"
-//	"strings"
-//
-//	multierror "github.com/hashicorp/go-multierror"
-//	"github.com/pkg/errors"
-//	"github.com/spf13/viper"
-//)
-//
-//// Mapfile is the Settings object that a Mapfile will be marshaled into.
-//type Mapfile struct {
-//	Defaults struct {
-//		EmptyTile     string `mapstructure:"empty_tile"`
-//		DisplayLegend bool   `mapstructure:"display_legend"`
-//	} `mapstructure:"defaults"`
-//
-//	Atlas struct {
-//		Map struct {
-//			layers     [][][]string
-//			layerNames []string
-//			Inline     []*layerEntry `mapstructure:"inline"`
-//			Files      []*layerEntry `mapstructure:"files"`
-//		} `mapstructure:"map"`
-//
-//		RawLegend []*legendEntry `mapstructure:"legend"`
-//		Legend    map[string]string
-//	} `mapstructure:"atlas"`
-//
-//	Entities map[string]*Entity `mapstructure:"entities"`
-//}
-//
-//type layerEntry struct {
-//	Name string `mapstructure:"name"`
-//	Grid string `mapstructure:"grid"`
-//}
-//
-//type legendEntry struct {
-//	Symbol    string `mapstructure:"symbol"`
-//	EntityKey string `mapstructure:"entity"`
-//}
-//
-//// ParseMapfile reads and parses a Mapfile at the given file path.
-////
-//// It uses Viper to read and unmarshal the Mapfile into a Mapfile struct.
-//// The Mapfile specification is in the docs (TODO).
-////
-//// After reading and unmarshaling, the Mapfile struct is then validated and
-//// modified with the Mapfile#clean() function.
-//func ParseMapfile(filePath string) (mapfile *Mapfile, err error) {
-//	v := viper.New()
-//	v.SetTypeByDefaultValue(true)
-//
-//	v.SetDefault("defaults.empty_tile", ".")
-//	v.SetDefault("defaults.display_legend", false)
-//
-//	v.SetConfigFile(filePath)
-//	v.SetConfigType("yaml")
-//	if err = v.ReadInConfig(); err != nil {
-//		err = errors.Wrapf(err, "error reading Mapfile '%s'", filePath)
-//		return
-//	}
-//
-//	if err = v.Unmarshal(&mapfile); err != nil {
-//		err = errors.Wrap(err, "error unmarshaling config")
-//		return
-//	}
-//
-//	if err = mapfile.clean(); err != nil {
-//		return
-//	}
-//	return
-//}
-//
-//// Clean validates a Mapfile, preparing it to be converted to a World just
-//// enough to facilitate validation.
-////
-//// Validate
-//// --------
-//// - Assert all required params are set and defined correctly.
-//// - Assert exactly one map source is provided (inline or file).
-//// - Assert all symbols used in map are defined in legend
-//// - Assert no symbol occurs more than once in legend.
-//// - Assert all entities used in legend are defined in entities.
-//// - Validate entity attributes.
-////
-//// Prepare
-//// -------
-//// - Import world map.
-//// - Convert raw map into grid of characters ([][]string).
-//// - Convert raw legend into key/value map.
-//func (m *Mapfile) clean() (err error) {
-//	// Validate map input sources
-//	mapSourceInline := len(m.Atlas.Map.Inline) > 0
-//	mapSourceFiles := len(m.Atlas.Map.Files) > 0
-//	if !(mapSourceInline || mapSourceFiles) {
-//		return errors.New("one of ``atlas.map.inline`` or ``atlas.map.files`` must be present")
-//	}
-//	if mapSourceInline && mapSourceFiles {
-//		return errors.New("``atlas.map.inline`` and ``atlas.map.files`` cannot both be present")
-//	}
-//
-//	// Read world map
-//	var depth int
-//	var layers []string
-//	var layerNames []string
-//
-//	if mapSourceInline {
-//		depth = len(m.Atlas.Map.Inline)
-//		layers = make([]string, depth)
-//		layerNames = make([]string, depth)
-//
-//		for z := range m.Atlas.Map.Inline {
-//			data := m.Atlas.Map.Inline[z]
-//			layers[z] = data.Grid
-//			layerNames[z] = data.Name
-//		}
-//
-//	} else {
-//		depth = len(m.Atlas.Map.Files)
-//		layers = make([]string, depth)
-//		layerNames = make([]string, depth)
-//
-//		for z := range m.Atlas.Map.Files {
-//			data := m.Atlas.Map.Files[z]
-//			bytes, err := ioutil.ReadFile(data.Grid)
-//			if err != nil {
-//				return errors.Wrap(err, "error reading ``atlas.map.files``")
-//			}
-//			layers[z] = string(bytes)
-//			layerNames[z] = data.Name
-//		}
-//	}
-//
-//	m.Atlas.Map.layers = gridify(layers)
-//	m.Atlas.Map.layerNames = layerNames
-//
-//	// Validate and read legend
-//	if len(m.Atlas.RawLegend) == 0 {
-//		return errors.New("``atlas.legend`` must have at least one entry")
-//	}
-//
-//	m.Atlas.Legend = make(map[string]string)
-//	for i := range m.Atlas.RawLegend {
-//		entry := m.Atlas.RawLegend[i]
-//		_, exists := m.Atlas.Legend[entry.Symbol]
-//		if exists {
-//			err = errors.Errorf(
-//				"symbol '%s' occurs more than once in `atlas.legend``",
-//				entry.Symbol,
-//			)
-//			return errors.WithMessage(err, "symbol must be unique")
-//		}
-//		m.Atlas.Legend[entry.Symbol] = entry.EntityKey
-//	}
-//
-//	// Validate map/legend relationship
-//	if err = m.cleanMapLegend(); err != nil {
-//		return
-//	}
-//
-//	// Validate legend/entity relationship
-//	if len(m.Entities) == 0 {
-//		return errors.New("``entities`` must have at least one entry")
-//	}
-//	if err = m.cleanLegendEntities(); err != nil {
-//		return
-//	}
-//
-//	return
-//}
-//
-//func (m *Mapfile) cleanMapLegend() error {
-//	for _, layer := range m.Atlas.Map.layers {
-//		for _, row := range layer {
-//			for _, char := range row {
-//				if char == m.Defaults.EmptyTile {
-//					continue
-//				}
-//				_, ok := m.Atlas.Legend[char]
-//				if !ok {
-//					return errors.Errorf("map symbol '%s' not found in ``atlas.legend``", char)
-//				}
-//			}
-//		}
-//	}
-//	return nil
-//}
-//
-//func (m *Mapfile) cleanLegendEntities() error {
-//	for _, key := range m.Atlas.Legend {
-//		_, ok := m.Entities[key]
-//		if !ok {
-//			return errors.Errorf("'%s' is referenced in ``atlas.legend``, but no entry is found in ``entities``", key)
-//		}
-//	}
-//	return nil
-//}
-//
-//func (m *Mapfile) cleanEntityAttrs() error {
-//	var result error
-//	for _, ent := range m.Entities {
-//		if err := vStringMinLen(ent.Name, 2, "name"); err != nil {
-//			result = multierror.Append(result, err)
-//		}
-//		if err := vIntMinVal(ent.Attrs.Energy, 1, "energy"); err != nil {
-//			result = multierror.Append(result, err)
-//		}
-//		if err := vIntMinVal(ent.Attrs.Size, 1, "size"); err != nil {
-//			result = multierror.Append(result, err)
-//		}
-//		if err := vIntMinVal(ent.Attrs.Mass, 1, "mass"); err != nil {
-//			result = multierror.Append(result, err)
-//		}
-//	}
-//	return result
-//}
-//
-//func vStringMinLen(val string, min int, key string) (err error) {
-//	if len(val) < min {
-//		err = errors.Errorf("entity attribute \"%s\" must have %d or more characters", key, min)
-//	}
-//	return
-//}
-//
-//func vIntMinVal(val int, min int, key string) (err error) {
-//	if val < min {
-//		err = errors.Errorf("entity attribute \"%s\" must be %d or greater", key, min)
-//	}
-//	return
-//}
-//
-//func gridify(layers []string) [][][]string {
-//	stack := make([][][]string, len(layers))
-//	for z, layer := range layers {
-//		rows := strings.Split(strings.TrimSpace(layer), "\n")
-//		grid := make([][]string, len(rows))
-//		for y, row := range rows {
-//			grid[y] = strings.Split(strings.TrimSpace(row), "")
-//		}
-//		stack[z] = grid
-//	}
-//	return stack
-//}
-//
-//// ToWorld creates a World from a Mapfile.
-////
-//// Steps
-//// -----
-//// - Determine World dimensions.
-//// - Initialize World.
-//// - For each tile in each Layer:
-////   - Get map symbol for that tile.
-////   - If symbol is the empty tile symbol:
-////     - Leave the tile blank.
-////   - Otherwise:
-////     - Look up Entity data in the legend.
-////     - Create a new Entity with that data.
-////     - Add the Entity to the layer.
-//// - Return the World.
-//func (m *Mapfile) ToWorld() *World {
-//	atlasLayers := m.Atlas.Map.layers
-//	layerNames := m.Atlas.Map.layerNames
-//
-//	height := len(atlasLayers[0])
-//	width := len(atlasLayers[0][0])
-//	world := NewWorld(width, height, layerNames)
-//
-//	for z := range atlasLayers {
-//		layer := world.Layer(z)
-//
-//		for y := 0; y < height; y++ {
-//			for x := 0; x < width; x++ {
-//				// Skip empty tiles.
-//				symbol := atlasLayers[z][y][x]
-//				if symbol == m.Defaults.EmptyTile {
-//					continue
-//				}
-//
-//				// Create new Entity.
-//				key := m.Atlas.Legend[symbol]
-//				data := m.Entities[key]
-//
-//				abilities := make([]*Ability, len(data.Abilities))
-//				for i := range data.Abilities {
-//					rawAbility := data.Abilities[i]
-//					behavior := Behaviors[rawAbility.Name]
-//					properties := rawAbility.Properties
-//					if properties == nil {
-//						properties = make(Properties)
-//					}
-//					abilities[i] = behavior.Ability(properties)
-//				}
-//
-//				ent := NewEntity(data.Name, data.Symbol, data.Attrs).
-//					WithTraits(data.Traits...).
-//					AddAbilities(abilities...)
+// It uses Viper to read and unmarshal the Mapfile into a Mapfile struct.
+// The Mapfile specification is in the docs (TODO).
 //
-//				// Add Entity to Layer.
-//				exec, ok := layer.Add(ent, Vec2D(x, y))
-//				if !ok {
-//					log.Printf("couldn't add an entity to a layer")
-//				}
-//				exec()
-//			}
-//		}
-//	}
-//	return world
-//}
+// After reading and unmarshaling, the Mapfile struct is then validated and
+// modified with the Mapfile#clean() function.
+func ParseMapfile(filePath string) (mapfile *Mapfile, err error) {
+	return ParseMapfileWith(filePath, nil)
+}
+
+// ParseMapfileWith is like ParseMapfile, but first overrides the values of
+// the Mapfile at the given keys. Keys are dotted paths like
+// "entities.sheep.attributes.energy". Within a list of abilities, an
+// ability is picked by name, as in "entities.sheep.abilities.move.speed".
+func ParseMapfileWith(filePath string, overrides map[string]interface{}) (mapfile *Mapfile, err error) {
+	v := viper.New()
+	v.SetTypeByDefaultValue(true)
+
+	v.SetDefault("defaults.empty_tile", ".")
+	v.SetDefault("defaults.display_legend", false)
+
+	clock := NewClock()
+	v.SetDefault("environment.clock.ticks_per_hour", clock.TicksPerHour)
+	v.SetDefault("environment.clock.hours_per_day", clock.HoursPerDay)
+	v.SetDefault("environment.clock.days_per_season", clock.DaysPerSeason)
+	v.SetDefault("environment.clock.dawn", clock.Dawn)
+	v.SetDefault("environment.clock.dusk", clock.Dusk)
+
+	v.SetConfigFile(filePath)
+	v.SetConfigType("yaml")
+	if err = v.ReadInConfig(); err != nil {
+		err = errors.Wrapf(err, "error reading Mapfile '%s'", filePath)
+		return
+	}
+
+	for _, key := range sortedOverrideKeys(overrides) {
+		if err = override(v, key, overrides[key]); err != nil {
+			return
+		}
+	}
+
+	if err = v.Unmarshal(&mapfile); err != nil {
+		err = errors.Wrap(err, "error unmarshaling config")
+		return
+	}
+	mapfile.dir = filepath.Dir(filePath)
+
+	if err = mapfile.clean(); err != nil {
+		return
+	}
+	return
+}
+
+func sortedOverrideKeys(overrides map[string]interface{}) []string {
+	keys := make([]string, 0, len(overrides))
+	for key := range overrides {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// override sets a value in the Mapfile, looking abilities up by name.
+func override(v *viper.Viper, key string, value interface{}) error {
+	parts := strings.Split(key, ".")
+	for i, part := range parts {
+		if part != "abilities" || i+2 >= len(parts) || i == 0 {
+			continue
+		}
+		listKey := strings.Join(parts[:i+1], ".")
+		name, property := parts[i+1], strings.Join(parts[i+2:], ".")
+
+		abilities, ok := v.Get(listKey).([]interface{})
+		if !ok {
+			return errors.Errorf("can't override '%s': '%s' is not a list of abilities", key, listKey)
+		}
+		for _, raw := range abilities {
+			ability, ok := raw.(map[string]interface{})
+			if !ok || ability["name"] != name {
+				continue
+			}
+			properties, ok := ability["properties"].(map[string]interface{})
+			if !ok {
+				properties = make(map[string]interface{})
+				ability["properties"] = properties
+			}
+			properties[property] = value
+			v.Set(listKey, abilities)
+			return nil
+		}
+		return errors.Errorf("can't override '%s': no ability named '%s'", key, name)
+	}
+
+	if !v.IsSet(key) {
+		return errors.Errorf("can't override '%s': the Mapfile has no such key", key)
+	}
+	v.Set(key, value)
+	return nil
+}
+
+// Clean validates a Mapfile, preparing it to be converted to a World just
+// enough to facilitate validation.
+//
+// Validate
+// --------
+// - Assert all required params are set and defined correctly.
+// - Assert exactly one map source is provided (inline or file).
+// - Assert all layers referenced by other layers exist.
+// - Assert all symbols used in terrain grids are defined in the terrain legend.
+// - Assert all symbols used in map are defined in legend
+// - Assert no symbol occurs more than once in legend.
+// - Assert all entities used in legend are defined in entities.
+// - Assert all extensions the Mapfile requires are registered.
+// - Assert all abilities used by entities are registered Behaviors.
+// - Assert all strategies used by entities are registered.
+// - Assert the colors of entity styles, terrain and fields are valid.
+// - Assert the clock, seasons, weather and fields are defined correctly.
+// - Validate entity attributes.
+//
+// Prepare
+// -------
+// - Import world map and terrain.
+// - Convert raw map into grid of characters ([][]string).
+// - Convert raw legend into key/value map.
+// - Convert raw terrain legend into map of symbol to Terrain.
+// - Convert raw seasons into the Seasons of the clock.
+func (m *Mapfile) clean() (err error) {
+	// Validate required extensions
+	if err = m.cleanExtensions(); err != nil {
+		return
+	}
+
+	// Validate map input sources
+	mapSourceInline := len(m.Atlas.Map.Inline) > 0
+	mapSourceFiles := len(m.Atlas.Map.Files) > 0
+	if !(mapSourceInline || mapSourceFiles) {
+		return errors.New("one of ``atlas.map.inline`` or ``atlas.map.files`` must be present")
+	}
+	if mapSourceInline && mapSourceFiles {
+		return errors.New("``atlas.map.inline`` and ``atlas.map.files`` cannot both be present")
+	}
+
+	// Read world map
+	var depth int
+	var layers []string
+	var terrain []string
+	var layerNames []string
+
+	if mapSourceInline {
+		depth = len(m.Atlas.Map.Inline)
+		layers = make([]string, depth)
+		terrain = make([]string, depth)
+		layerNames = make([]string, depth)
+
+		for z := range m.Atlas.Map.Inline {
+			data := m.Atlas.Map.Inline[z]
+			layers[z] = data.Grid
+			terrain[z] = data.Terrain
+			layerNames[z] = data.Name
+		}
+
+	} else {
+		depth = len(m.Atlas.Map.Files)
+		layers = make([]string, depth)
+		terrain = make([]string, depth)
+		layerNames = make([]string, depth)
+
+		for z := range m.Atlas.Map.Files {
+			data := m.Atlas.Map.Files[z]
+			bytes, err := ioutil.ReadFile(m.resolve(data.Grid))
+			if err != nil {
+				return errors.Wrap(err, "error reading ``atlas.map.files``")
+			}
+			layers[z] = string(bytes)
+			layerNames[z] = data.Name
+
+			if data.Terrain != "" {
+				bytes, err = ioutil.ReadFile(m.resolve(data.Terrain))
+				if err != nil {
+					return errors.Wrap(err, "error reading ``atlas.map.files``")
+				}
+				terrain[z] = string(bytes)
+			}
+		}
+	}
+
+	m.Atlas.Map.layers = gridify(layers)
+	m.Atlas.Map.terrain = gridify(terrain)
+	m.Atlas.Map.layerNames = layerNames
+
+	// Validate layer relationships
+	if err = m.cleanLayers(); err != nil {
+		return
+	}
+
+	// Validate and read legend
+	if len(m.Atlas.RawLegend) == 0 {
+		return errors.New("``atlas.legend`` must have at least one entry")
+	}
+
+	m.Atlas.Legend = make(map[string]string)
+	for i := range m.Atlas.RawLegend {
+		entry := m.Atlas.RawLegend[i]
+		_, exists := m.Atlas.Legend[entry.Symbol]
+		if exists {
+			err = errors.Errorf(
+				"symbol '%s' occurs more than once in `atlas.legend``",
+				entry.Symbol,
+			)
+			return errors.WithMessage(err, "symbol must be unique")
+		}
+		m.Atlas.Legend[entry.Symbol] = entry.EntityKey
+	}
+
+	// Validate map/legend relationship
+	if err = m.cleanMapLegend(); err != nil {
+		return
+	}
+
+	// Validate and read terrain
+	if err = m.cleanTerrain(); err != nil {
+		return
+	}
+
+	// Validate legend/entity relationship
+	if len(m.Entities) == 0 {
+		return errors.New("``entities`` must have at least one entry")
+	}
+	if err = m.cleanLegendEntities(); err != nil {
+		return
+	}
+	if err = m.cleanEntityAbilities(); err != nil {
+		return
+	}
+	if err = m.cleanEntityStyles(); err != nil {
+		return
+	}
+	if err = m.cleanEntitySpeeds(); err != nil {
+		return
+	}
+	for key, species := range m.Entities {
+		species.Key = key
+	}
+
+	// Validate environment
+	if err = m.cleanEnvironment(); err != nil {
+		return
+	}
+
+	// Validate stop conditions
+	if err = m.cleanStop(); err != nil {
+		return errors.WithMessage(err, "invalid entry in ``stop``")
+	}
+
+	return
+}
+
+func (m *Mapfile) layerEntries() []*layerEntry {
+	if len(m.Atlas.Map.Inline) > 0 {
+		return m.Atlas.Map.Inline
+	}
+	return m.Atlas.Map.Files
+}
+
+func (m *Mapfile) cleanLayers() error {
+	entries := m.layerEntries()
+	names := make(map[string]bool)
+	for _, entry := range entries {
+		if entry.Name == "" {
+			return errors.New("every layer in ``atlas.map`` must have a name")
+		}
+		if names[entry.Name] {
+			return errors.Errorf("layer name '%s' occurs more than once in ``atlas.map``", entry.Name)
+		}
+		names[entry.Name] = true
+	}
+
+	// Relate the layers of a throwaway World to catch conflicting or
+	// circular relationships before building the real one.
+	world := NewWorld(1, 1, m.Atlas.Map.layerNames)
+	if err := relateLayers(world, entries); err != nil {
+		return errors.WithMessage(err, "invalid layer relationship in ``atlas.map``")
+	}
+
+	// Check layer dimensions agree, so layers can be stacked.
+	grids := m.Atlas.Map.layers
+	for z := 1; z < len(grids); z++ {
+		if len(grids[z]) != len(grids[0]) || len(grids[z][0]) != len(grids[0][0]) {
+			return errors.Errorf("layer '%s' must have the same dimensions as layer '%s'", entries[z].Name, entries[0].Name)
+		}
+	}
+	for z, grid := range grids {
+		for y, row := range grid {
+			if len(row) != len(grids[0][0]) {
+				return errors.Errorf("row %d of layer '%s' must be as wide as the others", y+1, entries[z].Name)
+			}
+		}
+	}
+	return nil
+}
+
+func relateLayers(world *World, entries []*layerEntry) error {
+	for z, entry := range entries {
+		world.Layer(z).
+			SetBlocksLight(entry.BlocksLight).
+			SetPermeable(entry.Permeable).
+			SetDrawOrder(entry.DrawOrder).
+			SetOpaque(entry.Opaque)
+		if entry.Above != "" {
+			if err := world.Relate(entry.Name, entry.Above); err != nil {
+				return err
+			}
+		}
+		if entry.Below != "" {
+			if err := world.Relate(entry.Below, entry.Name); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (m *Mapfile) cleanMapLegend() error {
+	for _, layer := range m.Atlas.Map.layers {
+		for _, row := range layer {
+			for _, char := range row {
+				if char == m.Defaults.EmptyTile {
+					continue
+				}
+				_, ok := m.Atlas.Legend[char]
+				if !ok {
+					return errors.Errorf("map symbol '%s' not found in ``atlas.legend``", char)
+				}
+			}
+		}
+	}
+	return nil
+}
+
+func (m *Mapfile) cleanTerrain() error {
+	m.Atlas.TerrainLegend = make(map[string]Terrain)
+	for _, entry := range m.Atlas.RawTerrain {
+		if _, exists := m.Atlas.TerrainLegend[entry.Symbol]; exists {
+			return errors.Errorf("symbol '%s' occurs more than once in ``atlas.terrain``", entry.Symbol)
+		}
+		terrain, err := entry.toTerrain()
+		if err != nil {
+			return errors.WithMessage(err, "invalid entry in ``atlas.terrain``")
+		}
+		m.Atlas.TerrainLegend[entry.Symbol] = terrain
+	}
+
+	entries := m.layerEntries()
+	grids := m.Atlas.Map.layers
+	for z, grid := range m.Atlas.Map.terrain {
+		if entries[z].Terrain == "" {
+			continue
+		}
+		if len(grid) != len(grids[z]) || len(grid[0]) != len(grids[z][0]) {
+			return errors.Errorf("terrain of layer '%s' must have the same dimensions as its grid", entries[z].Name)
+		}
+		for _, row := range grid {
+			for _, char := range row {
+				if _, ok := m.Atlas.TerrainLegend[char]; !ok {
+					return errors.Errorf("terrain symbol '%s' not found in ``atlas.terrain``", char)
+				}
+			}
+		}
+	}
+	return nil
+}
+
+func (e *terrainEntry) toTerrain() (Terrain, error) {
+	terrain := DefaultTerrain
+	if e.Kind != "" {
+		terrain.Kind = e.Kind
+	}
+	if e.Moisture != nil {
+		terrain.Moisture = *e.Moisture
+	}
+	if e.Fertility != nil {
+		terrain.Fertility = *e.Fertility
+	}
+	if e.Elevation != nil {
+		terrain.Elevation = *e.Elevation
+	}
+	if e.MoveCost != nil {
+		terrain.MoveCost = *e.MoveCost
+	}
+	terrain.Impassable = e.Impassable
+	terrain.Color = e.Color
+
+	if terrain.Moisture < 0 || terrain.Moisture > 1 {
+		return terrain, errors.Errorf("moisture of '%s' must be between 0 and 1", e.Symbol)
+	}
+	if terrain.Fertility < 0 {
+		return terrain, errors.Errorf("fertility of '%s' must be 0 or greater", e.Symbol)
+	}
+	if terrain.MoveCost < 1 {
+		return terrain, errors.Errorf("move_cost of '%s' must be 1 or greater", e.Symbol)
+	}
+	if err := terrain.Color.Validate(); err != nil {
+		return terrain, errors.WithMessage(err, fmt.Sprintf("color of '%s'", e.Symbol))
+	}
+	return terrain, nil
+}
+
+func (m *Mapfile) cleanEnvironment() error {
+	clock := m.Environment.Clock
+	if clock == nil {
+		clock = NewClock()
+		m.Environment.Clock = clock
+	}
+	if clock.TicksPerHour < 1 || clock.HoursPerDay < 1 || clock.DaysPerSeason < 1 {
+		return errors.New("``environment.clock`` cycles must be 1 or greater")
+	}
+	if clock.Dawn < 0 || clock.Dusk > clock.HoursPerDay || clock.Dawn > clock.Dusk {
+		return errors.Errorf("``environment.clock`` dawn and dusk must be hours between 0 and %d", clock.HoursPerDay)
+	}
+
+	clock.Seasons = make([]*Season, len(m.Environment.RawSeasons))
+	for i, entry := range m.Environment.RawSeasons {
+		season := &Season{Name: entry.Name, Growth: 1, Metabolism: 1}
+		if entry.Growth != nil {
+			season.Growth = *entry.Growth
+		}
+		if entry.Metabolism != nil {
+			season.Metabolism = *entry.Metabolism
+		}
+		if season.Name == "" {
+			return errors.New("every season in ``environment.seasons`` must have a name")
+		}
+		if season.Growth < 0 || season.Metabolism < 0 {
+			return errors.Errorf("factors of season '%s' must be 0 or greater", season.Name)
+		}
+		clock.Seasons[i] = season
+	}
+
+	for _, wx := range m.Environment.Weather {
+		if err := behaviorValidator.Struct(wx); err != nil {
+			return errors.Wrapf(err, "invalid %s in ``environment.weather``", wx.Kind)
+		}
+		if wx.Layer != "" {
+			if _, ok := m.layerIndex(wx.Layer); !ok {
+				return errors.Errorf("%s in ``environment.weather`` refers to undefined layer '%s'", wx.Kind, wx.Layer)
+			}
+		}
+		for _, name := range wx.Seasons {
+			if !clock.hasSeason(name) {
+				return errors.Errorf("%s in ``environment.weather`` refers to undefined season '%s'", wx.Kind, name)
+			}
+		}
+	}
+
+	names := make(map[string]bool)
+	for _, field := range m.Environment.Fields {
+		if err := behaviorValidator.Struct(field); err != nil {
+			return errors.Wrapf(err, "invalid field '%s' in ``environment.fields``", field.Name)
+		}
+		if names[field.Name] {
+			return errors.Errorf("field '%s' occurs more than once in ``environment.fields``", field.Name)
+		}
+		if err := field.Color.Validate(); err != nil {
+			return errors.WithMessage(err, fmt.Sprintf("invalid field '%s' in ``environment.fields``", field.Name))
+		}
+		names[field.Name] = true
+	}
+	return nil
+}
+
+func (m *Mapfile) layerIndex(name string) (int, bool) {
+	for z, layerName := range m.Atlas.Map.layerNames {
+		if layerName == name {
+			return z, true
+		}
+	}
+	return -1, false
+}
+
+func (m *Mapfile) cleanLegendEntities() error {
+	for _, key := range m.Atlas.Legend {
+		_, ok := m.Entities[key]
+		if !ok {
+			return errors.Errorf("'%s' is referenced in ``atlas.legend``, but no entry is found in ``entities``", key)
+		}
+	}
+	return nil
+}
+
+func (m *Mapfile) cleanEntityAbilities() error {
+	for key, species := range m.Entities {
+		for _, ability := range species.Abilities {
+			if _, ok := behaviorKinds[ability.Name]; !ok {
+				return errors.Errorf("entity '%s' has unknown ability '%s'", key, ability.Name)
+			}
+			if ability.Name == "script" {
+				m.resolveScript(ability)
+			}
+			if _, err := defineAbility(ability); err != nil {
+				return errors.Wrapf(err, "entity '%s' has invalid properties for ability '%s'", key, ability.Name)
+			}
+		}
+		if _, ok := strategyKinds[species.Strategy]; species.Strategy != "" && !ok {
+			return errors.Errorf("entity '%s' has unknown strategy '%s'", key, species.Strategy)
+		}
+	}
+	return nil
+}
+
+func (m *Mapfile) cleanExtensions() error {
+	for _, name := range m.Extensions {
+		if _, ok := extensions[name]; !ok {
+			linked := "none"
+			if names := Extensions(); len(names) > 0 {
+				linked = strings.Join(names, ", ")
+			}
+			return errors.Errorf(
+				"extension '%s' is listed in ``extensions``, but isn't linked into this program (linked: %s)",
+				name, linked)
+		}
+	}
+	return nil
+}
+
+func (m *Mapfile) cleanEntityStyles() error {
+	for key, species := range m.Entities {
+		if err := species.Style.Validate(); err != nil {
+			return errors.WithMessage(err, fmt.Sprintf("entity '%s' has an invalid style", key))
+		}
+	}
+	return nil
+}
+
+func (m *Mapfile) cleanEntitySpeeds() error {
+	for key, species := range m.Entities {
+		if species.Attrs.Speed < 0 {
+			return errors.Errorf("entity '%s' has a negative speed", key)
+		}
+	}
+	return nil
+}
+
+// resolve makes a path relative to the Mapfile rather than the working
+// directory.
+func (m *Mapfile) resolve(path string) string {
+	if path == "" || filepath.IsAbs(path) {
+		return path
+	}
+	return filepath.Join(m.dir, path)
+}
+
+// resolveScript resolves the file of a script ability.
+func (m *Mapfile) resolveScript(ability *Ability) {
+	if file, ok := ability.Properties["file"].(string); ok {
+		ability.Properties["file"] = m.resolve(file)
+	}
+}
+
+// defineAbility creates the Behavior for an Ability, returning an error
+// instead of panicking if its properties are invalid.
+func defineAbility(ability *Ability) (behavior Behavior, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = errors.Errorf("%v", r)
+		}
+	}()
+	behavior, _ = NewBehavior(ability.Name, ability.Properties)
+	return
+}
+
+func (m *Mapfile) cleanEntityAttrs() error {
+	var result error
+	for _, ent := range m.Entities {
+		if err := vStringMinLen(ent.Name, 2, "name"); err != nil {
+			result = multierror.Append(result, err)
+		}
+		if err := vIntMinVal(ent.Attrs.Energy, 1, "energy"); err != nil {
+			result = multierror.Append(result, err)
+		}
+		if err := vIntMinVal(ent.Attrs.Size, 1, "size"); err != nil {
+			result = multierror.Append(result, err)
+		}
+		if err := vIntMinVal(ent.Attrs.Mass, 1, "mass"); err != nil {
+			result = multierror.Append(result, err)
+		}
+	}
+	return result
+}
+
+func vStringMinLen(val string, min int, key string) (err error) {
+	if len(val) < min {
+		err = errors.Errorf("entity attribute \"%s\" must have %d or more characters", key, min)
+	}
+	return
+}
+
+func vIntMinVal(val int, min int, key string) (err error) {
+	if val < min {
+		err = errors.Errorf("entity attribute \"%s\" must be %d or greater", key, min)
+	}
+	return
+}
+
+func gridify(layers []string) [][][]string {
+	stack := make([][][]string, len(layers))
+	for z, layer := range layers {
+		rows := strings.Split(strings.TrimSpace(layer), "\n")
+		grid := make([][]string, len(rows))
+		for y, row := range rows {
+			grid[y] = strings.Split(strings.TrimSpace(row), "")
+		}
+		stack[z] = grid
+	}
+	return stack
+}
+
+func (m *Mapfile) cleanStop() (err error) {
+	if m.Stop, err = stopStrings(m.RawStop); err != nil {
+		return
+	}
+	conditions, err := m.StopConditions()
+	if err != nil {
+		return
+	}
+	return m.CheckStopConditions(conditions)
+}
+
+// stopStrings converts stop conditions as read from YAML, where
+// "extinct: fox" is a map, back into strings.
+func stopStrings(raw []interface{}) ([]string, error) {
+	conditions := make([]string, 0, len(raw))
+	for _, item := range raw {
+		switch entry := item.(type) {
+		case string:
+			conditions = append(conditions, entry)
+		case map[string]interface{}:
+			if len(entry) != 1 {
+				return nil, errors.Errorf("'%v' should be a single condition", entry)
+			}
+			for key, value := range entry {
+				conditions = append(conditions, fmt.Sprintf("%s: %v", key, value))
+			}
+		default:
+			return nil, errors.Errorf("'%v' is not a condition", entry)
+		}
+	}
+	return conditions, nil
+}
+
+// StopConditions parses the stop conditions of the Mapfile. Conditions keep
+// state, so each run needs its own.
+func (m *Mapfile) StopConditions() ([]StopCondition, error) {
+	return ParseStopConditions(m.Stop)
+}
+
+// CheckStopConditions returns an error if a StopCondition counts a Species
+// that the Mapfile doesn't have.
+func (m *Mapfile) CheckStopConditions(conditions []StopCondition) error {
+	for _, condition := range conditions {
+		key, ok := stopSpecies(condition)
+		if ok && m.Entities[key] == nil {
+			return errors.Errorf("stop condition '%s' counts unknown species '%s'", condition, key)
+		}
+	}
+	return nil
+}
+
+// Lint looks for likely mistakes in a Mapfile that has been parsed
+// successfully, and returns a warning for each.
+func (m *Mapfile) Lint() []string {
+	warnings := make([]string, 0)
+
+	used := make(map[string]bool)
+	for _, layer := range m.Atlas.Map.layers {
+		for _, row := range layer {
+			for _, char := range row {
+				used[char] = true
+			}
+		}
+	}
+	placed := make(map[string]bool)
+	for _, entry := range m.Atlas.RawLegend {
+		if used[entry.Symbol] {
+			placed[entry.EntityKey] = true
+		} else {
+			warnings = append(warnings, fmt.Sprintf("legend symbol '%s' is never used in ``atlas.map``", entry.Symbol))
+		}
+	}
+
+	traits := make(map[Trait]bool)
+	keys := make([]string, 0, len(m.Entities))
+	for key, species := range m.Entities {
+		keys = append(keys, key)
+		for _, trait := range species.Traits {
+			traits[trait] = true
+		}
+	}
+	sort.Strings(keys)
+
+	listed := make(map[string]bool, len(m.Extensions))
+	for _, name := range m.Extensions {
+		listed[name] = true
+	}
+	unlisted := func(key, kind, name string) {
+		if ext, ok := extensionOf(kind, name); ok && !listed[ext] {
+			warnings = append(warnings, fmt.Sprintf(
+				"entity '%s' uses %s '%s' from extension '%s', which isn't listed in ``extensions``",
+				key, kind, name, ext))
+		}
+	}
+
+	for _, key := range keys {
+		species := m.Entities[key]
+		for _, ability := range species.Abilities {
+			unlisted(key, "behavior", ability.Name)
+		}
+		unlisted(key, "strategy", species.Strategy)
+		for _, trait := range species.Traits {
+			unlisted(key, "trait", string(trait))
+		}
+		if !placed[key] {
+			warnings = append(warnings, fmt.Sprintf("entity '%s' is never placed on the map", key))
+		}
+		if len(species.Abilities) == 0 {
+			warnings = append(warnings, fmt.Sprintf("entity '%s' has no abilities, so it never acts", key))
+		}
+		for _, ability := range species.Abilities {
+			behavior, err := defineAbility(ability)
+			if err != nil {
+				continue
+			}
+			var wanted []Trait
+			switch b := behavior.(type) {
+			case *Consume:
+				wanted = b.Diet
+			case *Pursue:
+				wanted = b.Prey
+			}
+			for _, trait := range wanted {
+				if !traits[trait] {
+					warnings = append(warnings, fmt.Sprintf(
+						"entity '%s' has ability '%s' for trait '%s', but no entity has that trait",
+						key, ability.Name, trait))
+				}
+			}
+		}
+	}
+	return warnings
+}
+
+// ToWorld creates a World from a Mapfile.
+//
+// Steps
+// -----
+// - Determine World dimensions.
+// - Initialize World.
+// - Set how the World is displayed.
+// - Relate Layers to each other.
+// - Add Species to the World.
+// - Set up the clock, weather and fields.
+// - Subscribe the Extensions that the Mapfile lists.
+// - For each tile in each Layer:
+//   - Set the terrain of that tile, if the Layer has any.
+//   - Get map symbol for that tile.
+//   - If symbol is the empty tile symbol:
+//     - Leave the tile blank.
+//   - Otherwise:
+//     - Look up Species in the legend.
+//     - Spawn a new Entity of that Species.
+//     - Add the Entity to the layer.
+// - Return the World.
+func (m *Mapfile) ToWorld() (*World, error) {
+	atlasLayers := m.Atlas.Map.layers
+	layerNames := m.Atlas.Map.layerNames
+
+	height := len(atlasLayers[0])
+	width := len(atlasLayers[0][0])
+	world := NewWorld(width, height, layerNames)
+	world.SetEmptyTile(m.Defaults.EmptyTile)
+	world.SetDisplayLegend(m.Defaults.DisplayLegend)
+
+	if err := relateLayers(world, m.layerEntries()); err != nil {
+		return nil, err
+	}
+
+	for _, species := range m.Entities {
+		world.AddSpecies(species)
+	}
+
+	clock := *m.Environment.Clock
+	world.SetClock(&clock)
+	for _, wx := range m.Environment.Weather {
+		weather := *wx
+		world.AddWeather(&weather)
+	}
+	for _, entry := range m.Environment.Fields {
+		field := world.AddField(entry.Name, entry.Diffusion, entry.Decay)
+		field.Color = entry.Color
+		if entry.Initial > 0 {
+			field.Fill(entry.Initial)
+		}
+	}
+	for _, name := range m.Extensions {
+		extensions[name].attach(world)
+	}
+
+	for z, entry := range m.layerEntries() {
+		layer := world.Layer(z)
+		terrain := m.Atlas.Map.terrain[z]
+
+		for y := 0; y < height; y++ {
+			for x := 0; x < width; x++ {
+				// Set terrain.
+				if entry.Terrain != "" {
+					layer.Cell(Vec2D(x, y)).SetTerrain(m.Atlas.TerrainLegend[terrain[y][x]])
+				}
+
+				// Skip empty tiles.
+				symbol := atlasLayers[z][y][x]
+				if symbol == m.Defaults.EmptyTile {
+					continue
+				}
+
+				// Create new Entity.
+				key := m.Atlas.Legend[symbol]
+				ent := m.Entities[key].Spawn(world)
+
+				// Add Entity to Layer.
+				exec, ok := layer.Add(ent, Vec2D(x, y))
+				if !ok {
+					return nil, errors.Errorf("couldn't add entity '%s' at (%d, %d) in layer '%s'",
+						key, x, y, layer.Name())
+				}
+				exec()
+			}
+		}
+	}
+	return world, nil
+}
@@ -0,0 +1,147 @@
+package ecoscript_test
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	. "github.com/dustinrohde/ecoscript"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Mapfile", func() {
+	It("should load the example Mapfile", func() {
+		mapfile, err := ParseMapfile("examples/Mapfile")
+		Expect(err).NotTo(HaveOccurred())
+		world, err := mapfile.ToWorld()
+		Expect(err).NotTo(HaveOccurred())
+
+		Expect(world.Width()).To(Equal(20))
+		Expect(world.Height()).To(Equal(20))
+		Expect(world.Depth()).To(Equal(3))
+
+		canopy, ground, soil := world.Layer(0), world.Layer(1), world.Layer(2)
+		Expect(canopy.Name()).To(Equal("canopy"))
+		Expect(canopy.BlocksLight()).To(BeTrue())
+		Expect(ground.Permeable()).To(BeTrue())
+		z, ok := canopy.Below()
+		Expect(ok).To(BeTrue())
+		Expect(z).To(Equal(1))
+		z, ok = soil.Above()
+		Expect(ok).To(BeTrue())
+		Expect(z).To(Equal(1))
+
+		crown := world.Cell(Vec(0, 0, 0)).Occupier()
+		Expect(crown).NotTo(BeNil())
+		Expect(crown.Species()).To(Equal("crown"))
+		Expect(crown.Behaviors).To(HaveKey("Grow"))
+		Expect(world.Cell(Vec(1, 0, 0)).Population()).To(Equal(0))
+
+		moles := 0
+		for y := 0; y < world.Height(); y++ {
+			for x := 0; x < world.Width(); x++ {
+				for _, ent := range world.Cell(Vec(x, y, 2)).Entities() {
+					if ent.Species() == "mole" {
+						moles++
+					}
+				}
+			}
+		}
+		Expect(moles).To(Equal(2))
+	})
+
+	It("should reject stop conditions for unknown species", func() {
+		mapfile, err := ParseMapfile("examples/Mapfile")
+		Expect(err).NotTo(HaveOccurred())
+
+		for s, known := range map[string]bool{
+			"extinct: sheep":         true,
+			"population:sheep > 10":  true,
+			"extinct: nosuchspecies": false,
+			"population:typo < 1":    false,
+		} {
+			conditions, err := ParseStopConditions([]string{s})
+			Expect(err).NotTo(HaveOccurred())
+			if known {
+				Expect(mapfile.CheckStopConditions(conditions)).To(Succeed(), s)
+			} else {
+				Expect(mapfile.CheckStopConditions(conditions)).NotTo(Succeed(), s)
+			}
+		}
+	})
+
+	It("should reject negative speeds", func() {
+		dir, err := ioutil.TempDir("", "mapfile")
+		Expect(err).NotTo(HaveOccurred())
+		defer os.RemoveAll(dir)
+
+		path := filepath.Join(dir, "Mapfile")
+		Expect(ioutil.WriteFile(path, []byte(`
+atlas:
+  map:
+    inline:
+      - name: ground
+        grid: |
+          s.
+  legend:
+    - symbol: 's'
+      entity: 'snail'
+entities:
+  snail:
+    name: snail
+    symbol: 's'
+    attributes: {energy: 5, speed: -1}
+`), 0644)).To(Succeed())
+
+		_, err = ParseMapfile(path)
+		Expect(err).To(MatchError(ContainSubstring("entity 'snail' has a negative speed")))
+	})
+
+	It("should read map files relative to the Mapfile", func() {
+		dir, err := ioutil.TempDir("", "mapfile")
+		Expect(err).NotTo(HaveOccurred())
+		defer os.RemoveAll(dir)
+
+		files := map[string]string{
+			"Mapfile": `
+atlas:
+  map:
+    files:
+      - name: ground
+        grid: maps/ground
+        terrain: maps/ground.terrain
+  terrain:
+    - symbol: 'r'
+      kind: rock
+      move_cost: 2
+    - symbol: 'g'
+      kind: grass
+  legend:
+    - symbol: 'o'
+      entity: 'stone'
+entities:
+  stone:
+    name: stone
+    symbol: 'o'
+    attributes: {energy: 1}
+`,
+			"maps/ground":         "o.\n..\n",
+			"maps/ground.terrain": "rr\ngg\n",
+		}
+		for name, content := range files {
+			path := filepath.Join(dir, name)
+			Expect(os.MkdirAll(filepath.Dir(path), 0755)).To(Succeed())
+			Expect(ioutil.WriteFile(path, []byte(content), 0644)).To(Succeed())
+		}
+
+		mapfile, err := ParseMapfile(filepath.Join(dir, "Mapfile"))
+		Expect(err).NotTo(HaveOccurred())
+		world, err := mapfile.ToWorld()
+		Expect(err).NotTo(HaveOccurred())
+		Expect(world.Cell(Vec(0, 0, 0)).Occupier().Species()).To(Equal("stone"))
+		Expect(world.Cell(Vec(1, 0, 0)).Terrain().Kind).To(Equal("rock"))
+		Expect(world.Cell(Vec(1, 1, 0)).Terrain().Kind).To(Equal("grass"))
+	})
+})
@@ -0,0 +1,304 @@
+package ecoscript
+
+import (
+	"bufio"
+	"encoding/json"
+	"io"
+
+	"github.com/pkg/errors"
+)
+
+// A recording is a stream of JSON Lines. The first line is a header holding
+// the seed, the checkpoint interval, the name of the Scheduler and a
+// Snapshot of the World before the first tick. Every line after it is a
+// Frame.
+//
+// The Recorder only looks at the World, so a recorded run plays out just
+// like one that isn't. Checkpoints are only taken between activities, since
+// a Snapshot can't hold what an activity in progress will do: once every
+// interval ticks, at the first tick after which no activity is in progress.
+// A World that is never between activities, as under a DiscreteScheduler,
+// is only ever replayed from the start.
+
+type recordingHeader struct {
+	Seed      int64     `json:"seed"`
+	Interval  int       `json:"checkpoint_interval"`
+	Scheduler string    `json:"scheduler,omitempty"`
+	Initial   *Snapshot `json:"snapshot"`
+}
+
+// Frame records one tick: the Events that happened during it and the Hash
+// of the World after it. Some Frames also hold a checkpoint.
+type Frame struct {
+	Tick       int       `json:"tick"`
+	Hash       string    `json:"hash"`
+	Events     []Event   `json:"events"`
+	Checkpoint *Snapshot `json:"checkpoint,omitempty"`
+}
+
+// ---------------------------------------------------------------------
+// Recorder
+
+// Recorder ticks a World and writes a recording of it.
+type Recorder struct {
+	world    *World
+	enc      *json.Encoder
+	interval int
+
+	// due is true once a checkpoint is due but hasn't been taken.
+	due bool
+
+	events      []Event
+	unsubscribe func()
+}
+
+// NewRecorder reseeds the World, writes the header of a recording to w, and
+// returns a Recorder that takes a checkpoint every interval ticks. The World
+// must have a Scheduler that NewScheduler can create again.
+func NewRecorder(w io.Writer, world *World, seed int64, interval int) (*Recorder, error) {
+	if interval < 1 {
+		interval = 1
+	}
+	scheduler, ok := schedulerName(world.Scheduler())
+	if !ok {
+		return nil, errors.Errorf("can't record a World with a %T set up that way", world.Scheduler())
+	}
+	world.Seed(seed)
+
+	initial, err := checkpoint(world)
+	if err != nil {
+		return nil, err
+	}
+
+	r := &Recorder{
+		world:    world,
+		enc:      json.NewEncoder(w),
+		interval: interval,
+		events:   make([]Event, 0),
+	}
+	header := recordingHeader{seed, interval, scheduler, initial}
+	if err := r.enc.Encode(header); err != nil {
+		return nil, errors.Wrap(err, "writing header")
+	}
+
+	r.unsubscribe = world.Events().Subscribe(Filter{}, func(ev Event) {
+		r.events = append(r.events, ev)
+	})
+	return r, nil
+}
+
+// Tick ticks the World and writes a Frame.
+func (r *Recorder) Tick() error {
+	r.world.Tick()
+	frame := Frame{
+		Tick:   r.world.Clock().Ticks(),
+		Hash:   r.world.Hash(),
+		Events: r.events,
+	}
+	r.events = make([]Event, 0)
+	r.due = r.due || frame.Tick%r.interval == 0
+	if r.due && r.world.resumable() {
+		var err error
+		if frame.Checkpoint, err = checkpoint(r.world); err != nil {
+			return err
+		}
+		r.due = false
+	}
+
+	return errors.Wrapf(r.enc.Encode(frame), "writing tick %d", frame.Tick)
+}
+
+// Close stops listening to the World.
+func (r *Recorder) Close() {
+	r.unsubscribe()
+}
+
+// checkpoint takes a Snapshot of the World.
+func checkpoint(world *World) (*Snapshot, error) {
+	snap, err := world.Snapshot()
+	return snap, errors.Wrap(err, "taking checkpoint")
+}
+
+// ---------------------------------------------------------------------
+// Recording
+
+// Recording is a recording read back into memory.
+type Recording struct {
+	Seed      int64
+	Interval  int
+	Scheduler string
+	Initial   *Snapshot
+	Frames    []Frame
+}
+
+// ReadRecording reads a recording written by a Recorder.
+func ReadRecording(r io.Reader) (*Recording, error) {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1<<30)
+
+	if !scanner.Scan() {
+		if err := scanner.Err(); err != nil {
+			return nil, errors.Wrap(err, "reading header")
+		}
+		return nil, errors.New("recording is empty")
+	}
+	var header recordingHeader
+	if err := json.Unmarshal(scanner.Bytes(), &header); err != nil {
+		return nil, errors.Wrap(err, "reading header")
+	}
+	if header.Initial == nil {
+		return nil, errors.New("recording has no initial snapshot")
+	}
+
+	if header.Scheduler == "" {
+		header.Scheduler = "serial"
+	}
+	rec := &Recording{
+		Seed:      header.Seed,
+		Interval:  header.Interval,
+		Scheduler: header.Scheduler,
+		Initial:   header.Initial,
+		Frames:    make([]Frame, 0),
+	}
+	for scanner.Scan() {
+		var frame Frame
+		if err := json.Unmarshal(scanner.Bytes(), &frame); err != nil {
+			return nil, errors.Wrapf(err, "reading frame %d", len(rec.Frames))
+		}
+		if want := rec.Start() + len(rec.Frames) + 1; frame.Tick != want {
+			return nil, errors.Errorf("frame %d is for tick %d, want %d", len(rec.Frames), frame.Tick, want)
+		}
+		rec.Frames = append(rec.Frames, frame)
+	}
+	return rec, errors.Wrap(scanner.Err(), "reading frames")
+}
+
+// Start returns the tick the recording starts at.
+func (rec *Recording) Start() int {
+	return rec.Initial.Ticks
+}
+
+// End returns the tick the recording ends at.
+func (rec *Recording) End() int {
+	return rec.Start() + len(rec.Frames)
+}
+
+// Frame returns the Frame recorded for the given tick.
+func (rec *Recording) Frame(tick int) (Frame, bool) {
+	i := tick - rec.Start() - 1
+	if i < 0 || i >= len(rec.Frames) {
+		return Frame{}, false
+	}
+	return rec.Frames[i], true
+}
+
+// Checkpoint returns the latest checkpoint taken on or before the given
+// tick that can be resumed exactly.
+func (rec *Recording) Checkpoint(tick int) *Snapshot {
+	for t := tick; t > rec.Start(); t-- {
+		if frame, ok := rec.Frame(t); ok && frame.Checkpoint != nil && frame.Checkpoint.Resumable() {
+			return frame.Checkpoint
+		}
+	}
+	return rec.Initial
+}
+
+// ---------------------------------------------------------------------
+// Player
+
+// Player replays a Recording in a World of its own, with the Scheduler it
+// was recorded with.
+type Player struct {
+	rec   *Recording
+	world *World
+}
+
+func NewPlayer(rec *Recording) (*Player, error) {
+	scheduler, err := NewScheduler(rec.Scheduler, 0)
+	if err != nil {
+		return nil, err
+	}
+	p := &Player{
+		rec:   rec,
+		world: NewWorld(0, 0, nil),
+	}
+	p.world.SetScheduler(scheduler)
+	if err := p.world.Restore(rec.Initial); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+// World returns the World being replayed.
+func (p *Player) World() *World {
+	return p.world
+}
+
+// Tick returns the tick the replay is at.
+func (p *Player) Tick() int {
+	return p.world.Clock().Ticks()
+}
+
+// Step replays one tick and returns its recorded Frame. It fails if the
+// World doesn't hash the same as it did when it was recorded.
+func (p *Player) Step() (Frame, error) {
+	recorded, ok := p.rec.Frame(p.Tick() + 1)
+	if !ok {
+		return Frame{}, io.EOF
+	}
+
+	p.world.Tick()
+	return recorded, p.check(recorded)
+}
+
+// check fails if the World doesn't hash as it did after the given Frame.
+func (p *Player) check(recorded Frame) error {
+	if hash := p.world.Hash(); hash != recorded.Hash {
+		return errors.Errorf("tick %d: replay hashes to %s, recorded %s", recorded.Tick, hash, recorded.Hash)
+	}
+	return nil
+}
+
+// Seek jumps to the given tick, restoring the nearest checkpoint before it
+// and replaying the rest. It fails if the World doesn't hash as it was
+// recorded to, at the given tick or any on the way.
+func (p *Player) Seek(tick int) error {
+	return p.seek(tick, true)
+}
+
+func (p *Player) seek(tick int, verify bool) error {
+	if tick < p.rec.Start() || tick > p.rec.End() {
+		return errors.Errorf("tick %d is outside the recording (%d-%d)", tick, p.rec.Start(), p.rec.End())
+	}
+	if tick < p.Tick() || p.rec.Checkpoint(tick).Ticks > p.Tick() {
+		if err := p.world.Restore(p.rec.Checkpoint(tick)); err != nil {
+			return err
+		}
+	}
+	for p.Tick() < tick {
+		if !verify {
+			p.world.Tick()
+			continue
+		}
+		if _, err := p.Step(); err != nil {
+			return err
+		}
+	}
+	if frame, ok := p.rec.Frame(tick); ok && verify {
+		return p.check(frame)
+	}
+	return nil
+}
+
+// Verify replays the whole Recording from the start, checking every tick.
+func (p *Player) Verify() error {
+	if err := p.Seek(p.rec.Start()); err != nil {
+		return err
+	}
+	for p.Tick() < p.rec.End() {
+		if _, err := p.Step(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,165 @@
+package ecoscript_test
+
+import (
+	"bytes"
+
+	. "github.com/dustinrohde/ecoscript"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Replay", func() {
+	var (
+		newWorld func() *World
+		record   func(seed int64) *Recording
+
+		recordWorld func(world *World, seed int64, ticks, interval int) *Recording
+	)
+
+	BeforeEach(func() {
+		newWorld = func() *World {
+			world := NewWorld(6, 6, []string{"ground"})
+			world.AddField(FieldNutrients, 0.1, 0.01)
+			world.AddSpecies(&Species{
+				Key:    "grass",
+				Name:   "grass",
+				Symbol: "\"",
+				Attrs:  Attributes{Walkable: true, Energy: 50, Size: 1, Mass: 1},
+				Traits: []Trait{"plant"},
+				Abilities: []*Ability{
+					{Name: "grow"},
+					{Name: "reproduce", Properties: Properties{"threshold": 60}},
+				},
+			}, &Species{
+				Key:    "sheep",
+				Name:   "sheep",
+				Symbol: "&",
+				Attrs:  Attributes{Energy: 80, Size: 2, Mass: 2},
+				Abilities: []*Ability{
+					{Name: "move", Properties: Properties{"speed": 1}},
+					{Name: "consume", Properties: Properties{"diet": []string{"plant"}}},
+				},
+			})
+			for i, key := range []string{"grass", "sheep", "grass", "sheep"} {
+				exec, ok := world.Add(world.Species(key).Spawn(world), Vec(i, i, 0))
+				Expect(ok).To(BeTrue())
+				exec()
+			}
+			return world
+		}
+		recordWorld = func(world *World, seed int64, ticks, interval int) *Recording {
+			var buf bytes.Buffer
+			recorder, err := NewRecorder(&buf, world, seed, interval)
+			Expect(err).NotTo(HaveOccurred())
+			for i := 0; i < ticks; i++ {
+				Expect(recorder.Tick()).To(Succeed())
+			}
+			recorder.Close()
+
+			rec, err := ReadRecording(&buf)
+			Expect(err).NotTo(HaveOccurred())
+			return rec
+		}
+		record = func(seed int64) *Recording {
+			return recordWorld(newWorld(), seed, 35, 10)
+		}
+	})
+
+	It("should play out identically from the same seed", func() {
+		a, b := record(1), record(1)
+		Expect(a.Frames).To(HaveLen(35))
+		for i := range a.Frames {
+			Expect(a.Frames[i].Hash).To(Equal(b.Frames[i].Hash))
+		}
+	})
+
+	It("should not change the run it records", func() {
+		rec := record(5)
+		world := newWorld()
+		world.Seed(5)
+		for i := range rec.Frames {
+			world.Tick()
+			Expect(world.Hash()).To(Equal(rec.Frames[i].Hash), "tick %d", i+1)
+		}
+	})
+
+	It("should reproduce the recorded hashes", func() {
+		rec := record(2)
+		player, err := NewPlayer(rec)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(player.Verify()).To(Succeed())
+		Expect(player.Tick()).To(Equal(35))
+	})
+
+	It("should jump to a tick from the nearest checkpoint", func() {
+		rec := record(3)
+		checkpoint := rec.Checkpoint(27)
+		Expect(checkpoint.Ticks).To(BeNumerically("<=", 27))
+		Expect(checkpoint.Resumable()).To(BeTrue())
+
+		player, err := NewPlayer(rec)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(player.Seek(27)).To(Succeed())
+		Expect(player.Tick()).To(Equal(27))
+		Expect(player.Seek(4)).To(Succeed())
+		Expect(player.Tick()).To(Equal(4))
+		Expect(player.Seek(36)).NotTo(Succeed())
+	})
+
+	It("should land on the recorded state from any checkpoint", func() {
+		rec := recordWorld(newWorld(), 7, 120, 40)
+		Expect(rec.Checkpoint(120).Ticks).To(BeNumerically(">", 0))
+
+		for tick := 41; tick <= 120; tick++ {
+			player, err := NewPlayer(rec)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(player.Seek(tick)).To(Succeed())
+			frame, _ := rec.Frame(tick)
+			Expect(player.World().Hash()).To(Equal(frame.Hash))
+		}
+	})
+
+	It("should replay with the scheduler it was recorded with", func() {
+		for _, name := range []string{"parallel", "sparse", "discrete"} {
+			world := newWorld()
+			scheduler, err := NewScheduler(name, 2)
+			Expect(err).NotTo(HaveOccurred())
+			world.SetScheduler(scheduler)
+			rec := recordWorld(world, 8, 40, 10)
+			Expect(rec.Scheduler).To(Equal(name))
+
+			player, err := NewPlayer(rec)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(player.Verify()).To(Succeed(), name)
+			for tick := 1; tick <= 40; tick++ {
+				player, err := NewPlayer(rec)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(player.Seek(tick)).To(Succeed(), "%s, tick %d", name, tick)
+			}
+		}
+	})
+
+	It("should notice a replay that diverges", func() {
+		rec := record(4)
+		rec.Frames[5].Hash = "tampered"
+
+		player, err := NewPlayer(rec)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(player.Verify()).To(MatchError(ContainSubstring("tick 6")))
+	})
+
+	It("should restore a snapshot exactly", func() {
+		world := newWorld()
+		for i := 0; i < 12; i++ {
+			world.Tick()
+		}
+		snap, err := world.Snapshot()
+		Expect(err).NotTo(HaveOccurred())
+		hash := world.Hash()
+
+		other := NewWorld(0, 0, nil)
+		Expect(other.Restore(snap)).To(Succeed())
+		Expect(other.Hash()).To(Equal(hash))
+	})
+})
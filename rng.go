@@ -0,0 +1,52 @@
+package ecoscript
+
+import (
+	"math/rand"
+	"time"
+)
+
+// source is a seeded random source that counts how many values it has
+// drawn, so that its state can be saved as a seed and a count and restored
+// later by drawing the same number of values again.
+type source struct {
+	seed  int64
+	draws uint64
+	src   rand.Source64
+}
+
+func newSource(seed int64) *source {
+	return &source{
+		seed: seed,
+		src:  rand.NewSource(seed).(rand.Source64),
+	}
+}
+
+func (s *source) Int63() int64 {
+	s.draws++
+	return s.src.Int63()
+}
+
+func (s *source) Uint64() uint64 {
+	s.draws++
+	return s.src.Uint64()
+}
+
+func (s *source) Seed(seed int64) {
+	s.seed = seed
+	s.draws = 0
+	s.src.Seed(seed)
+}
+
+// restore resets the source to the state it had after the given number of
+// draws from the given seed.
+func (s *source) restore(seed int64, draws uint64) {
+	s.Seed(seed)
+	for s.draws < draws {
+		s.Int63()
+	}
+}
+
+// defaultSeed seeds Worlds that aren't given a seed explicitly.
+func defaultSeed() int64 {
+	return time.Now().UnixNano()
+}
@@ -0,0 +1,685 @@
+package ecoscript
+
+import (
+	"container/heap"
+	"math/rand"
+	"runtime"
+	"sort"
+	"sync"
+
+	"github.com/pkg/errors"
+)
+
+// Scheduler decides in what order, and on which goroutines, the Entities of
+// a World act during a tick. World.Tick updates the weather, lets its
+// Scheduler tick the Entities, then steps the Fields and the clock.
+type Scheduler interface {
+	Tick(w *World)
+}
+
+// SetScheduler changes how the World ticks its Entities. Runs are only
+// reproducible with the same Scheduler, set up the same way.
+func (w *World) SetScheduler(s Scheduler) {
+	w.scheduler = s
+	w.resetTracker()
+}
+
+func (w *World) Scheduler() Scheduler {
+	return w.scheduler
+}
+
+// NewScheduler creates the built-in Scheduler with the given name: serial,
+// parallel, sparse or discrete. A ParallelScheduler ticks regions on the
+// given number of goroutines, or one per CPU if workers is less than 1.
+func NewScheduler(name string, workers int) (Scheduler, error) {
+	switch name {
+	case "serial":
+		return new(SerialScheduler), nil
+	case "parallel":
+		return NewParallelScheduler(workers), nil
+	case "sparse":
+		return NewSparseScheduler(), nil
+	case "discrete":
+		return NewDiscreteScheduler(), nil
+	}
+	return nil, errors.Errorf("unknown scheduler '%s'", name)
+}
+
+// schedulerName returns the name that NewScheduler creates a Scheduler
+// like the given one with, or false if it can't.
+func schedulerName(s Scheduler) (string, bool) {
+	switch s := s.(type) {
+	case *SerialScheduler:
+		return "serial", true
+	case *ParallelScheduler:
+		return "parallel", s.Reach == DefaultReach
+	case *SparseScheduler:
+		return "sparse", s.Priority == nil && s.Idle == DefaultIdle
+	case *DiscreteScheduler:
+		return "discrete", true
+	}
+	return "", false
+}
+
+// tracker is implemented by Schedulers that keep track of where Entities
+// are, instead of visiting every Cell. The World tells them whenever it
+// adds, moves or removes an Entity, and resets them when it changes in ways
+// they can't follow, after which they must look for every Entity again.
+// Entities added to a Layer directly, rather than to the World, are only
+// found that way.
+//
+// The schedule of a tracker is saved in Snapshots, and restored after
+// resetting it.
+type tracker interface {
+	placed(w *World, ent *Entity, vec Vector)
+	removed(ent *Entity)
+	reset()
+	schedule() []ScheduleEntry
+	reschedule(w *World, entries []ScheduleEntry)
+}
+
+func (w *World) placed(ent *Entity, vec Vector) {
+	if t, ok := w.scheduler.(tracker); ok && w.Cell(vec).Exists(ent) {
+		t.placed(w, ent, vec)
+	}
+}
+
+func (w *World) removed(ent *Entity, vec Vector) {
+	if t, ok := w.scheduler.(tracker); ok && !w.Cell(vec).Exists(ent) {
+		t.removed(ent)
+	}
+}
+
+func (w *World) resetTracker() {
+	if t, ok := w.scheduler.(tracker); ok {
+		t.reset()
+	}
+}
+
+// tickCell ticks the Entities of a Cell in random order.
+func tickCell(w *World, vec Vector, skip func(*Entity) bool) {
+	cell := w.Cell(vec)
+	entities := cell.Shuffled(w.rng)
+
+	for i := range entities {
+		// Skip entities that were removed earlier in the tick.
+		ent := entities[i]
+		if !cell.Exists(ent) || (skip != nil && skip(ent)) {
+			continue
+		}
+		// Entities that ran out of energy die.
+		if !ent.Alive() {
+			if exec, ok := w.destroy(ent, vec, "starved", true); ok {
+				exec()
+			}
+			continue
+		}
+		// Tick entity.
+		// TODO: MAYBE (?) suspend actions until end to resolve conflicts (?)
+		ent.Tick(w, vec)
+	}
+}
+
+// ---------------------------------------------------------------------
+// Scheduler: Serial
+
+// SerialScheduler visits every Cell of every Layer in random order, one
+// Layer at a time. It is the default.
+type SerialScheduler struct{}
+
+func (s *SerialScheduler) Tick(w *World) {
+	// For each layer...
+	for z := 0; z < w.Depth(); z++ {
+		layer := w.Layer(z)
+
+		// For each cell...
+		for _, y := range w.rng.Perm(layer.Height()) {
+			for _, x := range w.rng.Perm(layer.Width()) {
+				tickCell(w, Vec(x, y, z), nil)
+			}
+		}
+
+		//<<< sequential iteration through world >>>
+		//------------------------------------------
+		//for y := 0; y < layer.Height(); y++ {
+		//	for x := 0; x < layer.Width(); x++ {
+		//		vec := To2D(x, y)
+		//		cell := layer.Cell(vec)
+		//		entities := cell.Entities()
+		//
+		//		for i := range entities {
+		//			ent := entities[i]
+		//			ent.Tick(w, vec)
+		//		}
+		//	}
+		//}
+		//------------------------------------------
+	}
+}
+
+// ---------------------------------------------------------------------
+// Scheduler: Parallel
+
+// DefaultReach is the furthest from itself that any built-in Behavior looks
+// or acts, which is the largest radius Pursue allows.
+const DefaultReach = 10
+
+// ParallelScheduler splits the World into rectangular regions that tick
+// concurrently. Each region spans every Layer, and is at least twice Reach
+// wide and high. Regions are colored like a 2x2 checkerboard, and the
+// regions of one color tick together, so that no two regions ticking at
+// the same time are within Reach of each other. An Entity that acts across
+// a region boundary, by moving or by consuming a neighbor, only touches a
+// region that is idle until the next color. An Entity that moves into a
+// region that has yet to tick doesn't act again in the same tick.
+//
+// Each region draws from its own random source, seeded from the World's in
+// region order at the start of every tick, allocates Entity IDs from its own
+// stride, and buffers its Events until its color is done. Runs with the same
+// seed and Reach therefore play out identically, whatever the number of
+// Workers. They play out differently from runs with a SerialScheduler.
+//
+// Behaviors must not look or act further than Reach from their Entity, and
+// must draw randomness from the World they are given.
+type ParallelScheduler struct {
+	Workers int
+	Reach   int
+
+	width   int
+	height  int
+	reach   int
+	regions []*region
+}
+
+// region is a rectangle of the World, from (x0, y0) up to (x1, y1).
+type region struct {
+	x0, y0 int
+	x1, y1 int
+	color  int
+
+	// view is the World as seen from the region. It shares everything with
+	// the World but the random source, Event buffer and ID allocation, and
+	// outlives the tick, since activities hold on to it.
+	view   *World
+	src    *source
+	rng    *rand.Rand
+	events []Event
+}
+
+// NewParallelScheduler creates a ParallelScheduler that ticks regions on the
+// given number of goroutines, or one per CPU if workers is less than 1.
+func NewParallelScheduler(workers int) *ParallelScheduler {
+	if workers < 1 {
+		workers = runtime.NumCPU()
+	}
+	return &ParallelScheduler{
+		Workers: workers,
+		Reach:   DefaultReach,
+	}
+}
+
+func (s *ParallelScheduler) Tick(w *World) {
+	s.partition(w)
+
+	n := EntityID(len(s.regions))
+	for i, r := range s.regions {
+		*r.view = *w
+		r.view.src, r.view.rng = r.src, r.rng
+		r.view.nextID = w.nextID + EntityID(i)
+		r.view.idStride = n
+		r.view.buffer = &r.events
+		r.src.Seed(w.rng.Int63())
+	}
+
+	for color := 0; color < 4; color++ {
+		jobs := make(chan *region)
+		var wg sync.WaitGroup
+		for i := 0; i < s.Workers; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				for r := range jobs {
+					r.tick()
+				}
+			}()
+		}
+		for _, r := range s.regions {
+			if r.color == color {
+				jobs <- r
+			}
+		}
+		close(jobs)
+		wg.Wait()
+
+		// Publish what happened in region order.
+		for _, r := range s.regions {
+			if r.color != color {
+				continue
+			}
+			for _, ev := range r.events {
+				w.events.Publish(ev)
+			}
+			r.events = r.events[:0]
+		}
+	}
+
+	for _, r := range s.regions {
+		if r.view.nextID > w.nextID {
+			w.nextID = r.view.nextID
+		}
+	}
+}
+
+// partition splits the World into regions, unless it already has for a
+// World of the same size.
+func (s *ParallelScheduler) partition(w *World) {
+	if s.width == w.Width() && s.height == w.Height() && s.reach == s.Reach && s.regions != nil {
+		return
+	}
+	s.width, s.height, s.reach = w.Width(), w.Height(), s.Reach
+
+	size := 2 * s.Reach
+	if size < 1 {
+		size = 1
+	}
+	nx, ny := w.Width()/size, w.Height()/size
+	if nx < 1 {
+		nx = 1
+	}
+	if ny < 1 {
+		ny = 1
+	}
+
+	s.regions = make([]*region, 0, nx*ny)
+	for j := 0; j < ny; j++ {
+		for i := 0; i < nx; i++ {
+			src := newSource(0)
+			s.regions = append(s.regions, &region{
+				x0:    i * w.Width() / nx,
+				y0:    j * w.Height() / ny,
+				x1:    (i + 1) * w.Width() / nx,
+				y1:    (j + 1) * w.Height() / ny,
+				color: i%2 + j%2*2,
+				view:  new(World),
+				src:   src,
+				rng:   rand.New(src),
+			})
+		}
+	}
+}
+
+// tick ticks the Cells of the region in random order, one Layer at a time.
+func (r *region) tick() {
+	w := r.view
+	tick := w.clock.Ticks() + 1
+	acted := func(ent *Entity) bool {
+		if ent.ticked == tick {
+			return true
+		}
+		ent.ticked = tick
+		return false
+	}
+
+	for z := 0; z < w.Depth(); z++ {
+		for _, y := range w.rng.Perm(r.y1 - r.y0) {
+			for _, x := range w.rng.Perm(r.x1 - r.x0) {
+				tickCell(w, Vec(r.x0+x, r.y0+y, z), acted)
+			}
+		}
+	}
+}
+
+// ---------------------------------------------------------------------
+// Roster
+
+// roster keeps track of where Entities are and when they are next due, for
+// Schedulers that visit Entities rather than Cells. Times are in ticks, and
+// may fall between them.
+type roster struct {
+	synced  bool
+	entries map[EntityID]*entry
+	queue   wakeQueue
+
+	// now is the time of the Entity being visited, at which Entities that
+	// are placed in the meantime are due.
+	now float64
+}
+
+// entry is an Entity a roster knows of.
+type entry struct {
+	ent *Entity
+	vec Vector
+
+	// wake is the time at which the Entity is due, and last the time it was
+	// last visited at.
+	wake float64
+	last float64
+
+	// index is the position of the entry in the queue, or -1 if it isn't
+	// queued.
+	index int
+}
+
+// sync finds every Entity in the World, unless the roster already knows
+// them.
+func (r *roster) sync(w *World) {
+	if r.synced {
+		return
+	}
+	r.entries = make(map[EntityID]*entry)
+	r.queue = nil
+	r.now = float64(w.clock.Ticks())
+	for _, layer := range w.layers {
+		for y := 0; y < layer.Height(); y++ {
+			for x := 0; x < layer.Width(); x++ {
+				vec := Vec(x, y, layer.Z())
+				for _, ent := range layer.Cell(vec).Entities() {
+					r.placed(w, ent, vec)
+				}
+			}
+		}
+	}
+	r.synced = true
+}
+
+// next removes and returns the entry that is due first, if it is due before
+// the given time.
+func (r *roster) next(before float64) (*entry, bool) {
+	if len(r.queue) == 0 || r.queue[0].wake >= before {
+		return nil, false
+	}
+	return heap.Pop(&r.queue).(*entry), true
+}
+
+// current returns true if the entry is still the one for its Entity, which
+// isn't the case once the Entity has been removed.
+func (r *roster) current(e *entry) bool {
+	return r.entries[e.ent.ID()] == e
+}
+
+// requeue makes an entry due again at the given time, if its Entity is
+// still there.
+func (r *roster) requeue(e *entry, wake float64) {
+	if r.current(e) {
+		e.wake = wake
+		heap.Push(&r.queue, e)
+	}
+}
+
+func (r *roster) placed(w *World, ent *Entity, vec Vector) {
+	// Until the first tick, sync finds every Entity anyway.
+	if r.entries == nil {
+		return
+	}
+	if e, ok := r.entries[ent.ID()]; ok {
+		e.vec = vec
+		return
+	}
+	e := &entry{ent: ent, vec: vec, wake: r.now, last: r.now - 1, index: -1}
+	r.entries[ent.ID()] = e
+	heap.Push(&r.queue, e)
+}
+
+func (r *roster) removed(ent *Entity) {
+	e, ok := r.entries[ent.ID()]
+	if !ok {
+		return
+	}
+	delete(r.entries, ent.ID())
+	if e.index >= 0 {
+		heap.Remove(&r.queue, e.index)
+	}
+}
+
+func (r *roster) reset() {
+	r.synced = false
+	r.entries = nil
+	r.queue = nil
+}
+
+// ScheduleEntry is when a Scheduler that visits Entities is next due to
+// visit one, and when it last did.
+type ScheduleEntry struct {
+	Entity EntityID `json:"entity"`
+	Wake   float64  `json:"wake"`
+	Last   float64  `json:"last"`
+}
+
+// schedule returns an entry for every Entity the roster knows of, by ID, or
+// nil if it hasn't looked for them yet.
+func (r *roster) schedule() []ScheduleEntry {
+	if !r.synced {
+		return nil
+	}
+	entries := make([]ScheduleEntry, 0, len(r.entries))
+	for id, e := range r.entries {
+		entries = append(entries, ScheduleEntry{id, e.wake, e.last})
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].Entity < entries[j].Entity
+	})
+	return entries
+}
+
+// reschedule finds every Entity in the World again and makes them due as
+// the given schedule says. Entities that aren't in it are due right away.
+func (r *roster) reschedule(w *World, entries []ScheduleEntry) {
+	r.reset()
+	if entries == nil {
+		return
+	}
+	r.sync(w)
+	for _, saved := range entries {
+		if e, ok := r.entries[saved.Entity]; ok {
+			e.wake, e.last = saved.Wake, saved.Last
+		}
+	}
+	heap.Init(&r.queue)
+}
+
+// wakeQueue is a heap of entries by the time they are due at, then by ID.
+type wakeQueue []*entry
+
+func (q wakeQueue) Len() int {
+	return len(q)
+}
+
+func (q wakeQueue) Less(i, j int) bool {
+	if q[i].wake != q[j].wake {
+		return q[i].wake < q[j].wake
+	}
+	return q[i].ent.ID() < q[j].ent.ID()
+}
+
+func (q wakeQueue) Swap(i, j int) {
+	q[i], q[j] = q[j], q[i]
+	q[i].index = i
+	q[j].index = j
+}
+
+func (q *wakeQueue) Push(x interface{}) {
+	e := x.(*entry)
+	e.index = len(*q)
+	*q = append(*q, e)
+}
+
+func (q *wakeQueue) Pop() interface{} {
+	old := *q
+	e := old[len(old)-1]
+	old[len(old)-1] = nil
+	e.index = -1
+	*q = old[:len(old)-1]
+	return e
+}
+
+// ---------------------------------------------------------------------
+// Scheduler: Sparse
+
+// DefaultIdle is how long a SparseScheduler leaves Entities that have no
+// Behaviors before visiting them again.
+const DefaultIdle = 10
+
+// SparseScheduler visits Entities instead of Cells. It keeps track of where
+// every Entity is and when it next needs to act, and only visits those that
+// are due, so empty Cells cost nothing. An Entity is due again:
+//
+//   - when its activity finishes, if it has one in progress;
+//   - when it stops resting, if it rests past the next tick;
+//   - after Idle ticks, if it has no Behaviors;
+//   - on the next tick, otherwise.
+//
+// Activities progress on every tick an Entity waits for, including those
+// the Clock would otherwise have it sleep through. Entities that ran out of
+// energy die when they are next visited.
+//
+// A SparseScheduler belongs to a single World.
+type SparseScheduler struct {
+	roster
+
+	// Priority, if set, orders the Entities that are due in a tick: those
+	// with a higher priority act first, and those with the same priority act
+	// in random order. Otherwise they all act in random order.
+	Priority func(ent *Entity) int
+
+	Idle int
+}
+
+// NewSparseScheduler creates a SparseScheduler that visits Entities in
+// random order.
+func NewSparseScheduler() *SparseScheduler {
+	return &SparseScheduler{Idle: DefaultIdle}
+}
+
+func (s *SparseScheduler) Tick(w *World) {
+	s.sync(w)
+
+	now := w.clock.Ticks()
+	s.now = float64(now)
+	due := make([]*entry, 0)
+	for {
+		e, ok := s.next(s.now + 1)
+		if !ok {
+			break
+		}
+		due = append(due, e)
+	}
+	w.rng.Shuffle(len(due), func(i, j int) {
+		due[i], due[j] = due[j], due[i]
+	})
+	if s.Priority != nil {
+		sort.SliceStable(due, func(i, j int) bool {
+			return s.Priority(due[i].ent) > s.Priority(due[j].ent)
+		})
+	}
+
+	for _, e := range due {
+		// Skip entities that were removed earlier in the tick.
+		ent := e.ent
+		if !s.current(e) {
+			continue
+		}
+		// Entities that ran out of energy die.
+		if !ent.Alive() {
+			if exec, ok := w.destroy(ent, e.vec, "starved", true); ok {
+				exec()
+			}
+			continue
+		}
+
+		if ent.activity.InProgress() {
+			ent.activity.skip(now - int(e.last) - 1)
+		}
+		ent.Tick(w, e.vec)
+		e.last = s.now
+		s.requeue(e, float64(s.wake(ent, now)))
+	}
+}
+
+// wake returns the tick at which an Entity that just acted is due again.
+func (s *SparseScheduler) wake(ent *Entity, now int) int {
+	switch {
+	case ent.activity.InProgress():
+		return now + ent.activity.remaining()
+	case ent.rest > now+1:
+		return ent.rest
+	case len(ent.Behaviors) == 0 && s.Idle > 1:
+		return now + s.Idle
+	}
+	return now + 1
+}
+
+// ---------------------------------------------------------------------
+// Scheduler: Discrete
+
+// DiscreteScheduler is a discrete-event scheduler. Rather than counting the
+// ticks of every activity, it queues each Entity by the time its activity
+// finishes, which is its delay divided by the Entity's Speed, and visits
+// Entities in order of time. So an Entity with Speed 3 acts three times for
+// every time an Entity with Speed 1 acts, and Speeds needn't be whole.
+// Times fall between ticks; a tick handles all that is due before the next
+// one, and Entities that are due at the same time act in order of ID.
+//
+// When an Entity is visited, its activity finishes, and it starts the next
+// one right away. An Entity that has nothing to do, is resting, or is
+// asleep according to the Clock, is visited again after one tick, divided
+// by its Speed. Entities that ran out of energy die when they are next
+// visited.
+//
+// A DiscreteScheduler belongs to a single World.
+type DiscreteScheduler struct {
+	roster
+}
+
+func NewDiscreteScheduler() *DiscreteScheduler {
+	return new(DiscreteScheduler)
+}
+
+func (s *DiscreteScheduler) Tick(w *World) {
+	s.sync(w)
+
+	end := float64(w.clock.Ticks() + 1)
+	for {
+		e, ok := s.next(end)
+		if !ok {
+			break
+		}
+		s.now = e.wake
+		ent := e.ent
+
+		// Entities that ran out of energy die.
+		if !ent.Alive() {
+			if exec, ok := w.destroy(ent, e.vec, "starved", true); ok {
+				exec()
+			}
+			continue
+		}
+
+		if ent.activity.InProgress() {
+			ent.activity.finish()
+			ent.finished(w, e.vec)
+		}
+		e.last = s.now
+
+		// The Entity may have moved or died as its activity finished.
+		if !s.current(e) {
+			continue
+		}
+		step := 1.0
+		if w.clock.Awake(ent) && ent.start(w, e.vec) {
+			if _, needed := ent.activity.Progress(); needed > 0 {
+				step = float64(needed)
+			} else {
+				ent.activity.finish()
+				ent.finished(w, e.vec)
+			}
+		}
+		s.requeue(e, s.now+step/speed(ent))
+	}
+}
+
+func speed(ent *Entity) float64 {
+	if ent.Attrs.Speed > 0 {
+		return ent.Attrs.Speed
+	}
+	return 1
+}
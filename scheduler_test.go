@@ -0,0 +1,306 @@
+package ecoscript_test
+
+import (
+	"testing"
+
+	. "github.com/dustinrohde/ecoscript"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+// pasture creates a World of grass, sheep and foxes with the given seed.
+func pasture(size int, seed int64) *World {
+	world := NewWorld(size, size, []string{"ground"})
+	world.Seed(seed)
+	world.AddSpecies(
+		&Species{
+			Key: "grass", Name: "grass", Symbol: ",",
+			Attrs:  Attributes{Walkable: true, Energy: 20, Size: 1, Mass: 2},
+			Traits: []Trait{"plant"},
+			Abilities: []*Ability{
+				{Name: "grow", Properties: Properties{"rate": 5}},
+				{Name: "reproduce", Properties: Properties{"threshold": 40}},
+			},
+		},
+		&Species{
+			Key: "sheep", Name: "sheep", Symbol: "&",
+			Attrs:  Attributes{Walkable: false, Energy: 60, Size: 2, Mass: 10},
+			Traits: []Trait{"herbivore"},
+			Abilities: []*Ability{
+				{Name: "move", Properties: Properties{"speed": 2}},
+				{Name: "consume", Properties: Properties{"diet": []Trait{"plant"}}},
+				{Name: "reproduce", Properties: Properties{"threshold": 80}},
+			},
+		},
+		&Species{
+			Key: "fox", Name: "fox", Symbol: "f",
+			Attrs:  Attributes{Walkable: false, Energy: 80, Size: 2, Mass: 8},
+			Traits: []Trait{"carnivore"},
+			Abilities: []*Ability{
+				{Name: "pursue", Properties: Properties{"prey": []Trait{"herbivore"}, "radius": 10, "speed": 2}},
+				{Name: "consume", Properties: Properties{"diet": []Trait{"herbivore"}}},
+			},
+		},
+	)
+
+	rng := world.Rand()
+	for _, key := range []string{"grass", "grass", "grass", "sheep", "fox"} {
+		for i := 0; i < size*size/20; i++ {
+			ent := world.Species(key).Spawn(world)
+			if exec, ok := world.Spawn(ent, Vec(rng.Intn(size), rng.Intn(size), 0)); ok {
+				exec()
+			}
+		}
+	}
+	return world
+}
+
+// forest creates a World of trees that do nothing but grow, one in every
+// few Cells.
+func forest(size int, seed int64) *World {
+	world := NewWorld(size, size, []string{"ground"})
+	world.Seed(seed)
+	world.AddSpecies(&Species{
+		Key: "tree", Name: "tree", Symbol: "A",
+		Attrs:     Attributes{Walkable: false, Energy: 50, Size: 5, Mass: 100},
+		Traits:    []Trait{"plant"},
+		Abilities: []*Ability{{Name: "grow", Properties: Properties{"rate": 5}}},
+	})
+	for y := 0; y < size; y += 2 {
+		for x := y % 4; x < size; x += 4 {
+			if exec, ok := world.Spawn(world.Species("tree").Spawn(world), Vec(x, y, 0)); ok {
+				exec()
+			}
+		}
+	}
+	return world
+}
+
+var _ = Describe("ParallelScheduler", func() {
+	run := func(workers int) (*World, []Event) {
+		world := pasture(60, 7)
+		world.SetScheduler(NewParallelScheduler(workers))
+		events := make([]Event, 0)
+		world.Events().Subscribe(Filter{}, func(ev Event) {
+			events = append(events, ev)
+		})
+		for i := 0; i < 40; i++ {
+			world.Tick()
+		}
+		return world, events
+	}
+
+	It("should play out the same whatever the number of workers", func() {
+		a, eventsA := run(1)
+		b, eventsB := run(4)
+		Expect(a.Hash()).To(Equal(b.Hash()))
+		Expect(eventsA).To(Equal(eventsB))
+		Expect(CompareWorlds(a, b)).To(BeNil())
+	})
+
+	It("should give every Entity a unique ID", func() {
+		world, events := run(4)
+		born := 0
+		for _, ev := range events {
+			if ev.Type == EventReproduced {
+				born++
+			}
+		}
+		Expect(born).To(BeNumerically(">", 0))
+
+		seen := make(map[EntityID]bool)
+		for _, cell := range world.Layer(0).Cells() {
+			for _, ent := range cell.Entities() {
+				Expect(seen[ent.ID()]).To(BeFalse())
+				seen[ent.ID()] = true
+			}
+		}
+	})
+
+	It("should act once per tick per Entity", func() {
+		_, events := run(4)
+		started := make(map[[2]int]int)
+		for _, ev := range events {
+			if ev.Type == EventActivityStarted {
+				started[[2]int{ev.Tick, int(ev.Entity)}]++
+			}
+		}
+		for _, n := range started {
+			Expect(n).To(Equal(1))
+		}
+	})
+})
+
+var _ = Describe("SparseScheduler", func() {
+	It("should grow a forest like the SerialScheduler does", func() {
+		serial, sparse := forest(30, 3), forest(30, 3)
+		sparse.SetScheduler(NewSparseScheduler())
+		for i := 0; i < 100; i++ {
+			serial.Tick()
+			sparse.Tick()
+		}
+
+		trees := 0
+		for _, cell := range serial.Layer(0).Cells() {
+			for _, ent := range cell.Entities() {
+				other, _, ok := sparse.Find(ent.ID())
+				Expect(ok).To(BeTrue())
+				Expect(other.Attrs.Energy).To(Equal(ent.Attrs.Energy))
+				Expect(ent.Attrs.Energy).To(BeNumerically(">", 50))
+				trees++
+			}
+		}
+		Expect(trees).To(BeNumerically(">", 100))
+	})
+
+	It("should let Entities rest", func() {
+		world := forest(4, 3)
+		world.SetScheduler(NewSparseScheduler())
+		tree := world.Cell(Vec(0, 0, 0)).Entities()[0]
+		tree.RestUntil(50)
+
+		for i := 0; i < 50; i++ {
+			world.Tick()
+		}
+		Expect(tree.Attrs.Energy).To(Equal(50))
+		for i := 0; i < 20; i++ {
+			world.Tick()
+		}
+		Expect(tree.Attrs.Energy).To(BeNumerically(">", 50))
+	})
+
+	It("should follow Entities as they move, reproduce and die", func() {
+		run := func() (*World, []Event, map[EntityID]bool) {
+			world := pasture(40, 5)
+			world.SetScheduler(NewSparseScheduler())
+			initial := make(map[EntityID]bool)
+			for _, cell := range world.Layer(0).Cells() {
+				for _, ent := range cell.Entities() {
+					initial[ent.ID()] = true
+				}
+			}
+			events := make([]Event, 0)
+			world.Events().Subscribe(Filter{}, func(ev Event) {
+				events = append(events, ev)
+			})
+			for i := 0; i < 60; i++ {
+				world.Tick()
+			}
+			return world, events, initial
+		}
+		world, events, initial := run()
+		again, _, _ := run()
+		Expect(world.Hash()).To(Equal(again.Hash()))
+
+		types := make(map[EventType]bool)
+		started := make(map[EntityID]bool)
+		for _, ev := range events {
+			types[ev.Type] = true
+			if ev.Type == EventActivityStarted && ev.Tick >= 30 {
+				started[ev.Entity] = true
+			}
+		}
+		Expect(types).To(HaveKey(EventMoved))
+		Expect(types).To(HaveKey(EventReproduced))
+		Expect(types).To(HaveKey(EventDied))
+
+		// Everything that has been alive all along is still acting.
+		survivors := 0
+		for _, cell := range world.Layer(0).Cells() {
+			for _, ent := range cell.Entities() {
+				if initial[ent.ID()] && ent.Alive() {
+					Expect(started).To(HaveKey(ent.ID()))
+					survivors++
+				}
+			}
+		}
+		Expect(survivors).To(BeNumerically(">", 0))
+	})
+})
+
+var _ = Describe("DiscreteScheduler", func() {
+	It("should let fast Entities act more often", func() {
+		world := NewWorld(30, 30, []string{"ground"})
+		world.Seed(9)
+		world.SetScheduler(NewDiscreteScheduler())
+
+		walker := func(speed float64, vec Vector) *Entity {
+			ent := NewEntity("walker", "w").
+				AddAttributes(&Attributes{Walkable: true, Energy: 1000, Size: 1, Mass: 1, Speed: speed}).
+				AddBehaviors(&Move{Delay: 10, MoveRate: 1, SwitchRate: 1})
+			ent.AddStrategy(RandomStrategy(ent.Behaviors))
+			exec, ok := world.Add(ent, vec)
+			Expect(ok).To(BeTrue())
+			exec()
+			return ent
+		}
+		tortoise := walker(1, Vec(5, 5, 0))
+		rabbit := walker(3, Vec(20, 20, 0))
+		hare := walker(2.5, Vec(20, 5, 0))
+
+		moves := make(map[EntityID]int)
+		world.Events().Subscribe(Filter{Types: []EventType{EventMoved}}, func(ev Event) {
+			moves[ev.Entity]++
+		})
+		for i := 0; i < 200; i++ {
+			world.Tick()
+		}
+
+		Expect(moves[tortoise.ID()]).To(BeNumerically("~", 20, 1))
+		Expect(moves[rabbit.ID()]).To(BeNumerically("~", 60, 1))
+		Expect(moves[hare.ID()]).To(BeNumerically("~", 50, 1))
+	})
+
+	It("should play out the same with the same seed", func() {
+		run := func() (*World, map[EventType]int) {
+			world := pasture(40, 5)
+			world.SetScheduler(NewDiscreteScheduler())
+			counts := make(map[EventType]int)
+			world.Events().Subscribe(Filter{}, func(ev Event) {
+				counts[ev.Type]++
+			})
+			for i := 0; i < 60; i++ {
+				world.Tick()
+			}
+			return world, counts
+		}
+		world, counts := run()
+		again, _ := run()
+		Expect(world.Hash()).To(Equal(again.Hash()))
+		Expect(counts[EventMoved]).To(BeNumerically(">", 0))
+		Expect(counts[EventConsumed]).To(BeNumerically(">", 0))
+		Expect(counts[EventReproduced]).To(BeNumerically(">", 0))
+	})
+})
+
+func BenchmarkTick(b *testing.B) {
+	schedulers := []struct {
+		name string
+		new  func() Scheduler
+	}{
+		{"serial", func() Scheduler { return new(SerialScheduler) }},
+		{"parallel", func() Scheduler { return NewParallelScheduler(0) }},
+		{"sparse", func() Scheduler { return NewSparseScheduler() }},
+		{"discrete", func() Scheduler { return NewDiscreteScheduler() }},
+	}
+	worlds := []struct {
+		name string
+		new  func() *World
+	}{
+		{"pasture", func() *World { return pasture(200, 1) }},
+		{"forest", func() *World { return forest(200, 1) }},
+	}
+	for _, w := range worlds {
+		for _, s := range schedulers {
+			b.Run(w.name+"/"+s.name, func(b *testing.B) {
+				world := w.new()
+				world.SetScheduler(s.new())
+				b.ResetTimer()
+				for i := 0; i < b.N; i++ {
+					world.Tick()
+				}
+			})
+		}
+	}
+}
@@ -0,0 +1,975 @@
+package ecoscript
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/pkg/errors"
+	"go.starlark.net/starlark"
+	"go.starlark.net/starlarkstruct"
+	"go.starlark.net/syntax"
+)
+
+// ---------------------------------------------------------------------
+// Behavior: Script
+
+// Script is a Behavior written in Starlark, a small dialect of Python, so
+// that a Mapfile can add abilities without recompiling. Its File defines
+// two functions:
+//
+//	def define(props):
+//	    # Checks the properties of the ability, calling fail() if they're
+//	    # invalid, and returns the state of the Behavior. Each Entity gets
+//	    # its own state. If define is missing, the state is props.
+//
+//	def execute(world, entity, vec, state):
+//	    # Like Behavior.Execute: plans an activity and returns its delay
+//	    # and an action to call when it's done, or None to do nothing.
+//	    # The state parameter may be left out.
+//
+// Vectors are tuples of (x, y, z). The world and entity arguments mirror
+// the methods of World and Entity that the built-in Behaviors use; see
+// scriptWorldMethods and scriptEntityMethods. Scripts can't load modules or
+// touch anything outside the World, draw their randomness from the World so
+// that runs stay repeatable, and fail if they run too long. They can't look
+// or act further than DefaultReach from the Entity, like the built-in
+// Behaviors, and their global variables are frozen once the file has run.
+// Errors in execute and in the actions it returns are published as
+// EventFailed, and the Entity does nothing.
+type Script struct {
+	File string
+
+	program *scriptProgram
+	state   starlark.Value
+}
+
+// scriptMaxSteps limits how many steps a call into a script may take.
+const scriptMaxSteps = 1000000
+
+func (b *Script) Define(props Properties) Behavior {
+	file, _ := props["file"].(string)
+	if file == "" {
+		Guard(errors.New("property 'file' is required"))
+	}
+	program, err := loadScript(file)
+	Guard(err)
+	b.File, b.program = file, program
+
+	args := make(map[string]interface{}, len(props))
+	for key, value := range props {
+		if key != "file" {
+			args[key] = value
+		}
+	}
+	b.state, err = toStarlark(args)
+	Guard(err, "script '%s'", file)
+	if program.define != nil {
+		b.state, err = program.call(program.define, starlark.Tuple{b.state})
+		Guard(err, "script '%s'", file)
+	}
+	return b
+}
+
+func (b *Script) Execute(wld *World, ent *Entity, vec Vector) (delay int, exec func()) {
+	execute := b.program.execute
+	args := starlark.Tuple{scriptWorld{wld, vec}, scriptEntity{ent}, vectorValue(vec)}
+	if execute.NumParams() == 4 {
+		args = append(args, b.state)
+	}
+	result, err := b.program.call(execute, args)
+	if err != nil {
+		b.fail(wld, ent, vec, err)
+		return 0, nil
+	}
+
+	delay, action, err := scriptActivity(result)
+	if err != nil {
+		b.fail(wld, ent, vec, errors.WithMessage(err, "execute"))
+		return 0, nil
+	}
+	if action != nil {
+		exec = func() {
+			if _, err := b.program.call(action, nil); err != nil {
+				b.fail(wld, ent, vec, err)
+			}
+		}
+	}
+	return
+}
+
+// fail reports an error in a running script as an EventFailed. Unlike an
+// invalid ability, which is found when the Mapfile is loaded, it mustn't
+// stop the World in the middle of a tick.
+func (b *Script) fail(wld *World, ent *Entity, vec Vector, err error) {
+	ev := entityEvent(EventFailed, ent, vec)
+	ev.Detail = fmt.Sprintf("script '%s': %s", b.File, err)
+	wld.emit(ev)
+}
+
+// Name names the Script after its File, so that an Entity can have abilities
+// from more than one Script.
+func (b *Script) Name() string {
+	return strings.TrimSuffix(filepath.Base(b.File), filepath.Ext(b.File))
+}
+
+func (b *Script) String() string {
+	state, err := fromStarlark(b.state)
+	if err != nil {
+		return fmt.Sprintf("{File:%s State:%s}", b.File, b.state)
+	}
+	return fmt.Sprintf("{File:%s State:%v}", b.File, state)
+}
+
+type scriptJSON struct {
+	File  string      `json:"file"`
+	State interface{} `json:"state"`
+}
+
+// MarshalJSON saves the File and state of the Script, which must be made of
+// None, bools, numbers, strings, lists, tuples and dicts with string keys.
+func (b *Script) MarshalJSON() ([]byte, error) {
+	state, err := fromStarlark(b.state)
+	if err != nil {
+		return nil, errors.Wrapf(err, "script '%s'", b.File)
+	}
+	return json.Marshal(scriptJSON{b.File, state})
+}
+
+// UnmarshalJSON loads the File of the Script and restores its state, without
+// calling define again. Tuples in the state are restored as lists.
+func (b *Script) UnmarshalJSON(data []byte) error {
+	var snap scriptJSON
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	if err := decoder.Decode(&snap); err != nil {
+		return err
+	}
+	program, err := loadScript(snap.File)
+	if err != nil {
+		return err
+	}
+	state, err := toStarlark(snap.State)
+	if err != nil {
+		return errors.Wrapf(err, "script '%s'", snap.File)
+	}
+	b.File, b.program, b.state = snap.File, program, state
+	return nil
+}
+
+// scriptActivity reads the delay and action that execute returned.
+func scriptActivity(result starlark.Value) (delay int, action starlark.Callable, err error) {
+	if result == starlark.None {
+		return 0, nil, nil
+	}
+	tuple, ok := result.(starlark.Tuple)
+	if !ok || len(tuple) != 2 {
+		return 0, nil, errors.Errorf("got %s, want (delay, action) or None", result.Type())
+	}
+	if delay, err = starlark.AsInt32(tuple[0]); err != nil {
+		return 0, nil, errors.Errorf("got %s for delay, want int", tuple[0].Type())
+	}
+	if tuple[1] == starlark.None {
+		return delay, nil, nil
+	}
+	if action, ok = tuple[1].(starlark.Callable); !ok {
+		return 0, nil, errors.Errorf("got %s for action, want a function or None", tuple[1].Type())
+	}
+	return delay, action, nil
+}
+
+// ---------------------------------------------------------------------
+// Script loading
+
+// scriptProgram is a loaded script file.
+type scriptProgram struct {
+	file    string
+	define  *starlark.Function
+	execute *starlark.Function
+}
+
+// scripts caches loaded scripts by absolute path, since every Entity with a
+// Script ability shares its program.
+var scripts = struct {
+	sync.Mutex
+	programs map[string]*scriptProgram
+}{programs: make(map[string]*scriptProgram)}
+
+// loadScript loads a script file, or returns it from the cache.
+func loadScript(file string) (*scriptProgram, error) {
+	path, err := filepath.Abs(file)
+	if err != nil {
+		return nil, errors.Wrap(err, "error reading script")
+	}
+	scripts.Lock()
+	defer scripts.Unlock()
+	if program, ok := scripts.programs[path]; ok {
+		return program, nil
+	}
+
+	src, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, errors.Wrap(err, "error reading script")
+	}
+	program := &scriptProgram{file: file}
+	globals, err := starlark.ExecFile(program.thread(), file, src, nil)
+	if err != nil {
+		return nil, scriptError(err)
+	}
+	// Every Entity with the ability shares the globals, so they mustn't
+	// change.
+	globals.Freeze()
+
+	if fn, ok := globals["define"]; ok {
+		if program.define, ok = fn.(*starlark.Function); !ok || program.define.NumParams() != 1 {
+			return nil, errors.Errorf("script '%s': define must be a function of (props)", file)
+		}
+	}
+	fn, _ := globals["execute"].(*starlark.Function)
+	if fn == nil || fn.NumParams() < 3 || fn.NumParams() > 4 {
+		return nil, errors.Errorf("script '%s': execute must be a function of (world, entity, vec[, state])", file)
+	}
+	program.execute = fn
+
+	scripts.programs[path] = program
+	return program, nil
+}
+
+// thread returns a new thread to run the program in. Nothing can be loaded
+// and nothing is printed.
+func (p *scriptProgram) thread() *starlark.Thread {
+	thread := &starlark.Thread{
+		Name:  p.file,
+		Print: func(*starlark.Thread, string) {},
+	}
+	thread.SetMaxExecutionSteps(scriptMaxSteps)
+	return thread
+}
+
+func (p *scriptProgram) call(fn starlark.Value, args starlark.Tuple) (starlark.Value, error) {
+	result, err := starlark.Call(p.thread(), fn, args, nil)
+	if err != nil {
+		return nil, scriptError(err)
+	}
+	return result, nil
+}
+
+// scriptError adds the Starlark stack to an error, if it has one.
+func scriptError(err error) error {
+	if evalErr, ok := err.(*starlark.EvalError); ok {
+		return errors.New(evalErr.Backtrace())
+	}
+	return err
+}
+
+// toStarlark converts a value read from a Mapfile or JSON to Starlark.
+func toStarlark(v interface{}) (starlark.Value, error) {
+	switch v := v.(type) {
+	case nil:
+		return starlark.None, nil
+	case bool:
+		return starlark.Bool(v), nil
+	case int:
+		return starlark.MakeInt(v), nil
+	case int64:
+		return starlark.MakeInt64(v), nil
+	case float64:
+		return starlark.Float(v), nil
+	case json.Number:
+		if n, err := v.Int64(); err == nil {
+			return starlark.MakeInt64(n), nil
+		}
+		f, err := v.Float64()
+		return starlark.Float(f), err
+	case string:
+		return starlark.String(v), nil
+	case Trait:
+		return starlark.String(v), nil
+	case []string:
+		elems := make([]interface{}, len(v))
+		for i := range v {
+			elems[i] = v[i]
+		}
+		return toStarlark(elems)
+	case []Trait:
+		elems := make([]interface{}, len(v))
+		for i := range v {
+			elems[i] = v[i]
+		}
+		return toStarlark(elems)
+	case []interface{}:
+		elems := make([]starlark.Value, len(v))
+		for i := range v {
+			elem, err := toStarlark(v[i])
+			if err != nil {
+				return nil, err
+			}
+			elems[i] = elem
+		}
+		return starlark.NewList(elems), nil
+	case Properties:
+		return toStarlark(map[string]interface{}(v))
+	case map[string]interface{}:
+		keys := make([]string, 0, len(v))
+		for key := range v {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		dict := starlark.NewDict(len(v))
+		for _, key := range keys {
+			value, err := toStarlark(v[key])
+			if err != nil {
+				return nil, err
+			}
+			if err := dict.SetKey(starlark.String(key), value); err != nil {
+				return nil, err
+			}
+		}
+		return dict, nil
+	case map[interface{}]interface{}:
+		m := make(map[string]interface{}, len(v))
+		for key, value := range v {
+			m[fmt.Sprint(key)] = value
+		}
+		return toStarlark(m)
+	}
+	return nil, errors.Errorf("can't pass %T to a script", v)
+}
+
+// fromStarlark converts a Starlark value made of None, bools, numbers,
+// strings, lists, tuples and dicts with string keys to Go.
+func fromStarlark(v starlark.Value) (interface{}, error) {
+	switch v := v.(type) {
+	case starlark.NoneType:
+		return nil, nil
+	case starlark.Bool:
+		return bool(v), nil
+	case starlark.Int:
+		n, ok := v.Int64()
+		if !ok {
+			return nil, errors.Errorf("%s is too big", v)
+		}
+		return n, nil
+	case starlark.Float:
+		return float64(v), nil
+	case starlark.String:
+		return string(v), nil
+	case starlark.Indexable:
+		list := make([]interface{}, v.Len())
+		for i := range list {
+			elem, err := fromStarlark(v.Index(i))
+			if err != nil {
+				return nil, err
+			}
+			list[i] = elem
+		}
+		return list, nil
+	case *starlark.Dict:
+		m := make(map[string]interface{}, v.Len())
+		for _, item := range v.Items() {
+			key, ok := item[0].(starlark.String)
+			if !ok {
+				return nil, errors.Errorf("dict key %s is not a string", item[0])
+			}
+			value, err := fromStarlark(item[1])
+			if err != nil {
+				return nil, err
+			}
+			m[string(key)] = value
+		}
+		return m, nil
+	}
+	return nil, errors.Errorf("can't save a %s", v.Type())
+}
+
+// ---------------------------------------------------------------------
+// Script API
+
+// scriptVector unpacks a Vector from a tuple or list of (x, y, z).
+type scriptVector Vector
+
+func (v *scriptVector) Unpack(value starlark.Value) error {
+	seq, ok := value.(starlark.Indexable)
+	if _, isString := value.(starlark.String); !ok || isString || seq.Len() != 3 {
+		return errors.Errorf("got %s, want a vector (x, y, z)", value.Type())
+	}
+	var xyz [3]int
+	for i := range xyz {
+		n, err := starlark.AsInt32(seq.Index(i))
+		if err != nil {
+			return errors.Errorf("got %s in vector, want int", seq.Index(i).Type())
+		}
+		xyz[i] = n
+	}
+	*v = scriptVector(Vec(xyz[0], xyz[1], xyz[2]))
+	return nil
+}
+
+func vectorValue(vec Vector) starlark.Value {
+	return starlark.Tuple{starlark.MakeInt(vec.X), starlark.MakeInt(vec.Y), starlark.MakeInt(vec.Z)}
+}
+
+func vectorsValue(vectors []Vector) starlark.Value {
+	elems := make([]starlark.Value, len(vectors))
+	for i := range vectors {
+		elems[i] = vectorValue(vectors[i])
+	}
+	return starlark.NewList(elems)
+}
+
+func entitiesValue(ents []*Entity) starlark.Value {
+	elems := make([]starlark.Value, len(ents))
+	for i := range ents {
+		elems[i] = scriptEntity{ents[i]}
+	}
+	return starlark.NewList(elems)
+}
+
+// actionValue wraps an action as a function for a script to return or call,
+// or None if it isn't ok.
+func actionValue(name string, exec action, ok bool) starlark.Value {
+	if !ok {
+		return starlark.None
+	}
+	return starlark.NewBuiltin(name, func(thread *starlark.Thread, b *starlark.Builtin, args starlark.Tuple, kwargs []starlark.Tuple) (starlark.Value, error) {
+		if err := starlark.UnpackArgs(b.Name(), args, kwargs); err != nil {
+			return nil, err
+		}
+		exec()
+		return starlark.None, nil
+	})
+}
+
+// methodNames returns the sorted names of a table of methods, with the names
+// of some attributes.
+func methodNames(methods map[string]*starlark.Builtin, attrs ...string) []string {
+	names := append([]string(nil), attrs...)
+	for name := range methods {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// scriptWorld is the World as a script sees it, from the position of the
+// Entity whose Behavior is running.
+type scriptWorld struct {
+	w      *World
+	origin Vector
+}
+
+func (sw scriptWorld) String() string        { return "<world>" }
+func (sw scriptWorld) Type() string          { return "world" }
+func (sw scriptWorld) Freeze()               {}
+func (sw scriptWorld) Truth() starlark.Bool  { return starlark.True }
+func (sw scriptWorld) Hash() (uint32, error) { return 0, errors.New("unhashable type: world") }
+
+var scriptWorldAttrs = []string{"width", "height", "depth", "tick", "hour", "day", "season", "is_day"}
+
+func (sw scriptWorld) AttrNames() []string {
+	return methodNames(scriptWorldMethods, scriptWorldAttrs...)
+}
+
+func (sw scriptWorld) Attr(name string) (starlark.Value, error) {
+	clock := sw.w.Clock()
+	switch name {
+	case "width":
+		return starlark.MakeInt(sw.w.Width()), nil
+	case "height":
+		return starlark.MakeInt(sw.w.Height()), nil
+	case "depth":
+		return starlark.MakeInt(sw.w.Depth()), nil
+	case "tick":
+		return starlark.MakeInt(clock.Ticks()), nil
+	case "hour":
+		return starlark.MakeInt(clock.Hour()), nil
+	case "day":
+		return starlark.MakeInt(clock.Day()), nil
+	case "season":
+		if season := clock.Season(); season != nil {
+			return starlark.String(season.Name), nil
+		}
+		return starlark.None, nil
+	case "is_day":
+		return starlark.Bool(clock.IsDay()), nil
+	}
+	if method, ok := scriptWorldMethods[name]; ok {
+		return method.BindReceiver(sw), nil
+	}
+	return nil, nil
+}
+
+// scriptWorldMethods are the methods of the World in scripts, after World,
+// Cell, Field and Clock.
+var scriptWorldMethods = map[string]*starlark.Builtin{
+	"in_bounds":        starlark.NewBuiltin("in_bounds", worldVectorTest((*World).InBounds)),
+	"walkable":         starlark.NewBuiltin("walkable", worldVectorTest((*World).Walkable)),
+	"shaded":           starlark.NewBuiltin("shaded", worldVectorTest((*World).Shaded)),
+	"view":             starlark.NewBuiltin("view", worldView((*World).View)),
+	"view_r":           starlark.NewBuiltin("view_r", worldView((*World).ViewR)),
+	"view_walkable":    starlark.NewBuiltin("view_walkable", worldView((*World).ViewWalkable)),
+	"rand_walkable":    starlark.NewBuiltin("rand_walkable", worldRandWalkable),
+	"entities":         starlark.NewBuiltin("entities", worldEntities),
+	"shuffled":         starlark.NewBuiltin("shuffled", worldShuffled),
+	"terrain":          starlark.NewBuiltin("terrain", worldTerrain),
+	"project":          starlark.NewBuiltin("project", worldProject),
+	"field":            starlark.NewBuiltin("field", worldField),
+	"rand":             starlark.NewBuiltin("rand", worldRand),
+	"random":           starlark.NewBuiltin("random", worldRandom),
+	"scale_growth":     starlark.NewBuiltin("scale_growth", worldScale((*Clock).ScaleGrowth)),
+	"scale_metabolism": starlark.NewBuiltin("scale_metabolism", worldScale((*Clock).ScaleMetabolism)),
+	"move":             starlark.NewBuiltin("move", worldMove),
+	"walk":             starlark.NewBuiltin("walk", worldWalk),
+	"spawn":            starlark.NewBuiltin("spawn", worldSpawn),
+	"destroy":          starlark.NewBuiltin("destroy", worldDestroy),
+}
+
+type scriptBuiltin func(thread *starlark.Thread, b *starlark.Builtin, args starlark.Tuple, kwargs []starlark.Tuple) (starlark.Value, error)
+
+func receiverWorld(b *starlark.Builtin) *World {
+	return b.Receiver().(scriptWorld).w
+}
+
+// reach returns an error if a vector, or any Cell within radius of it, is
+// further than DefaultReach from the Entity, where the ParallelScheduler
+// can't promise that nothing else is changing the World.
+func reach(b *starlark.Builtin, vec Vector, radius int) error {
+	var origin Vector
+	switch recv := b.Receiver().(type) {
+	case scriptWorld:
+		origin = recv.origin
+	case scriptField:
+		origin = recv.origin
+	}
+	if distance(origin, vec)+radius <= DefaultReach {
+		return nil
+	}
+	if radius > 0 {
+		return errors.Errorf("%s: radius %d around (%d, %d, %d) is out of reach", b.Name(), radius, vec.X, vec.Y, vec.Z)
+	}
+	return errors.Errorf("%s: (%d, %d, %d) is out of reach", b.Name(), vec.X, vec.Y, vec.Z)
+}
+
+// worldVectorTest makes world.fn(vec), returning a bool.
+func worldVectorTest(fn func(*World, Vector) bool) scriptBuiltin {
+	return func(thread *starlark.Thread, b *starlark.Builtin, args starlark.Tuple, kwargs []starlark.Tuple) (starlark.Value, error) {
+		var vec scriptVector
+		if err := starlark.UnpackArgs(b.Name(), args, kwargs, "vec", &vec); err != nil {
+			return nil, err
+		}
+		if err := reach(b, Vector(vec), 0); err != nil {
+			return nil, err
+		}
+		return starlark.Bool(fn(receiverWorld(b), Vector(vec))), nil
+	}
+}
+
+// worldView makes world.fn(vec, radius=1), returning a list of vectors.
+func worldView(fn func(*World, Vector, int) []Vector) scriptBuiltin {
+	return func(thread *starlark.Thread, b *starlark.Builtin, args starlark.Tuple, kwargs []starlark.Tuple) (starlark.Value, error) {
+		var vec scriptVector
+		radius := 1
+		if err := starlark.UnpackArgs(b.Name(), args, kwargs, "vec", &vec, "radius?", &radius); err != nil {
+			return nil, err
+		}
+		if err := reach(b, Vector(vec), radius); err != nil {
+			return nil, err
+		}
+		return vectorsValue(fn(receiverWorld(b), Vector(vec), radius)), nil
+	}
+}
+
+// worldScale makes world.fn(energy), scaling energy by the current season.
+func worldScale(fn func(*Clock, int) int) scriptBuiltin {
+	return func(thread *starlark.Thread, b *starlark.Builtin, args starlark.Tuple, kwargs []starlark.Tuple) (starlark.Value, error) {
+		var energy int
+		if err := starlark.UnpackArgs(b.Name(), args, kwargs, "energy", &energy); err != nil {
+			return nil, err
+		}
+		return starlark.MakeInt(fn(receiverWorld(b).Clock(), energy)), nil
+	}
+}
+
+func worldRandWalkable(thread *starlark.Thread, b *starlark.Builtin, args starlark.Tuple, kwargs []starlark.Tuple) (starlark.Value, error) {
+	var vec scriptVector
+	radius := 1
+	if err := starlark.UnpackArgs(b.Name(), args, kwargs, "vec", &vec, "radius?", &radius); err != nil {
+		return nil, err
+	}
+	if err := reach(b, Vector(vec), radius); err != nil {
+		return nil, err
+	}
+	return vectorValue(receiverWorld(b).RandWalkable(Vector(vec), radius)), nil
+}
+
+// reachBoth checks that both ends of a move are within reach.
+func reachBoth(b *starlark.Builtin, src, dst Vector) error {
+	if err := reach(b, src, 0); err != nil {
+		return err
+	}
+	return reach(b, dst, 0)
+}
+
+// cellAt returns the Cell at a vector, or an error if it's out of reach or
+// out of bounds.
+func cellAt(b *starlark.Builtin, vec scriptVector) (*Cell, error) {
+	w := receiverWorld(b)
+	if err := reach(b, Vector(vec), 0); err != nil {
+		return nil, err
+	}
+	if !w.InBounds(Vector(vec)) {
+		return nil, errors.Errorf("%s: (%d, %d, %d) is out of bounds", b.Name(), vec.X, vec.Y, vec.Z)
+	}
+	return w.Cell(Vector(vec)), nil
+}
+
+func worldEntities(thread *starlark.Thread, b *starlark.Builtin, args starlark.Tuple, kwargs []starlark.Tuple) (starlark.Value, error) {
+	var vec scriptVector
+	if err := starlark.UnpackArgs(b.Name(), args, kwargs, "vec", &vec); err != nil {
+		return nil, err
+	}
+	cell, err := cellAt(b, vec)
+	if err != nil {
+		return nil, err
+	}
+	return entitiesValue(cell.Entities()), nil
+}
+
+func worldShuffled(thread *starlark.Thread, b *starlark.Builtin, args starlark.Tuple, kwargs []starlark.Tuple) (starlark.Value, error) {
+	var vec scriptVector
+	if err := starlark.UnpackArgs(b.Name(), args, kwargs, "vec", &vec); err != nil {
+		return nil, err
+	}
+	cell, err := cellAt(b, vec)
+	if err != nil {
+		return nil, err
+	}
+	return entitiesValue(cell.Shuffled(receiverWorld(b).Rand())), nil
+}
+
+func worldTerrain(thread *starlark.Thread, b *starlark.Builtin, args starlark.Tuple, kwargs []starlark.Tuple) (starlark.Value, error) {
+	var vec scriptVector
+	if err := starlark.UnpackArgs(b.Name(), args, kwargs, "vec", &vec); err != nil {
+		return nil, err
+	}
+	cell, err := cellAt(b, vec)
+	if err != nil {
+		return nil, err
+	}
+	t := cell.Terrain()
+	return starlarkstruct.FromStringDict(starlark.String("terrain"), starlark.StringDict{
+		"kind":       starlark.String(t.Kind),
+		"moisture":   starlark.Float(t.Moisture),
+		"fertility":  starlark.Float(t.Fertility),
+		"elevation":  starlark.Float(t.Elevation),
+		"cost":       starlark.MakeInt(t.Cost()),
+		"impassable": starlark.Bool(t.Impassable),
+	}), nil
+}
+
+func worldProject(thread *starlark.Thread, b *starlark.Builtin, args starlark.Tuple, kwargs []starlark.Tuple) (starlark.Value, error) {
+	var vec scriptVector
+	var layer string
+	if err := starlark.UnpackArgs(b.Name(), args, kwargs, "vec", &vec, "layer", &layer); err != nil {
+		return nil, err
+	}
+	if err := reach(b, Vector(vec), 0); err != nil {
+		return nil, err
+	}
+	dest, ok := receiverWorld(b).Project(Vector(vec), layer)
+	if !ok {
+		return starlark.None, nil
+	}
+	return vectorValue(dest), nil
+}
+
+func worldField(thread *starlark.Thread, b *starlark.Builtin, args starlark.Tuple, kwargs []starlark.Tuple) (starlark.Value, error) {
+	var name string
+	if err := starlark.UnpackArgs(b.Name(), args, kwargs, "name", &name); err != nil {
+		return nil, err
+	}
+	sw := b.Receiver().(scriptWorld)
+	field := sw.w.Field(name)
+	if field == nil {
+		return starlark.None, nil
+	}
+	return scriptField{field, sw.w, sw.origin}, nil
+}
+
+func worldRand(thread *starlark.Thread, b *starlark.Builtin, args starlark.Tuple, kwargs []starlark.Tuple) (starlark.Value, error) {
+	var n int
+	if err := starlark.UnpackArgs(b.Name(), args, kwargs, "n", &n); err != nil {
+		return nil, err
+	}
+	if n <= 0 {
+		return nil, errors.Errorf("%s: n must be positive", b.Name())
+	}
+	return starlark.MakeInt(receiverWorld(b).Rand().Intn(n)), nil
+}
+
+func worldRandom(thread *starlark.Thread, b *starlark.Builtin, args starlark.Tuple, kwargs []starlark.Tuple) (starlark.Value, error) {
+	if err := starlark.UnpackArgs(b.Name(), args, kwargs); err != nil {
+		return nil, err
+	}
+	return starlark.Float(receiverWorld(b).Rand().Float64()), nil
+}
+
+func worldMove(thread *starlark.Thread, b *starlark.Builtin, args starlark.Tuple, kwargs []starlark.Tuple) (starlark.Value, error) {
+	var ent scriptEntity
+	var src, dst scriptVector
+	if err := starlark.UnpackArgs(b.Name(), args, kwargs, "entity", &ent, "src", &src, "dst", &dst); err != nil {
+		return nil, err
+	}
+	if err := reachBoth(b, Vector(src), Vector(dst)); err != nil {
+		return nil, err
+	}
+	exec, ok := receiverWorld(b).Move(ent.e, Vector(src), Vector(dst))
+	return actionValue("move", exec, ok), nil
+}
+
+func worldWalk(thread *starlark.Thread, b *starlark.Builtin, args starlark.Tuple, kwargs []starlark.Tuple) (starlark.Value, error) {
+	var ent scriptEntity
+	var src, dst scriptVector
+	var speed int
+	if err := starlark.UnpackArgs(b.Name(), args, kwargs, "entity", &ent, "src", &src, "dst", &dst, "speed", &speed); err != nil {
+		return nil, err
+	}
+	if err := reachBoth(b, Vector(src), Vector(dst)); err != nil {
+		return nil, err
+	}
+	delay, exec := walk(receiverWorld(b), ent.e, Vector(src), Vector(dst), speed)
+	return starlark.Tuple{starlark.MakeInt(delay), actionValue("walk", exec, exec != nil)}, nil
+}
+
+func worldSpawn(thread *starlark.Thread, b *starlark.Builtin, args starlark.Tuple, kwargs []starlark.Tuple) (starlark.Value, error) {
+	var key string
+	var vec scriptVector
+	if err := starlark.UnpackArgs(b.Name(), args, kwargs, "species", &key, "vec", &vec); err != nil {
+		return nil, err
+	}
+	if err := reach(b, Vector(vec), 0); err != nil {
+		return nil, err
+	}
+	w := receiverWorld(b)
+	species := w.Species(key)
+	if species == nil {
+		return nil, errors.Errorf("%s: unknown species '%s'", b.Name(), key)
+	}
+	// Like Reproduce, the Entity only gets an ID once it's born.
+	dest := Vector(vec)
+	ok := w.InBounds(dest) && (species.Attrs.Walkable || !w.Cell(dest).Occupied())
+	spawn := func() {
+		if exec, ok := w.Spawn(species.Spawn(w), dest); ok {
+			exec()
+		}
+	}
+	return actionValue("spawn", spawn, ok), nil
+}
+
+func worldDestroy(thread *starlark.Thread, b *starlark.Builtin, args starlark.Tuple, kwargs []starlark.Tuple) (starlark.Value, error) {
+	var ent scriptEntity
+	var vec scriptVector
+	cause, remains := "destroyed", true
+	if err := starlark.UnpackArgs(b.Name(), args, kwargs,
+		"entity", &ent, "vec", &vec, "cause?", &cause, "remains?", &remains); err != nil {
+		return nil, err
+	}
+	if err := reach(b, Vector(vec), 0); err != nil {
+		return nil, err
+	}
+	exec, ok := receiverWorld(b).destroy(ent.e, Vector(vec), cause, remains)
+	return actionValue("destroy", exec, ok), nil
+}
+
+// scriptEntity is an Entity as a script sees it. Entities are equal if they
+// have the same ID.
+type scriptEntity struct{ e *Entity }
+
+func (se scriptEntity) String() string        { return fmt.Sprintf("<entity #%d %s>", se.e.ID(), se.e.Name) }
+func (se scriptEntity) Type() string          { return "entity" }
+func (se scriptEntity) Freeze()               {}
+func (se scriptEntity) Truth() starlark.Bool  { return starlark.True }
+func (se scriptEntity) Hash() (uint32, error) { return uint32(se.e.ID()), nil }
+
+func (se scriptEntity) CompareSameType(op syntax.Token, y starlark.Value, depth int) (bool, error) {
+	same := se.e.ID() == y.(scriptEntity).e.ID()
+	switch op {
+	case syntax.EQL:
+		return same, nil
+	case syntax.NEQ:
+		return !same, nil
+	}
+	return false, errors.Errorf("%s not supported for entities", op)
+}
+
+var scriptEntityAttrs = []string{
+	"id", "species", "name", "symbol", "energy", "size", "mass", "speed", "walkable", "traits", "alive", "biomass",
+}
+
+func (se scriptEntity) AttrNames() []string {
+	return methodNames(scriptEntityMethods, scriptEntityAttrs...)
+}
+
+func (se scriptEntity) Attr(name string) (starlark.Value, error) {
+	e := se.e
+	switch name {
+	case "id":
+		return starlark.MakeInt(int(e.ID())), nil
+	case "species":
+		return starlark.String(e.Species()), nil
+	case "name":
+		return starlark.String(e.Name), nil
+	case "symbol":
+		return starlark.String(e.Symbol), nil
+	case "energy":
+		return starlark.MakeInt(e.Attrs.Energy), nil
+	case "size":
+		return starlark.MakeInt(e.Attrs.Size), nil
+	case "mass":
+		return starlark.MakeInt(e.Attrs.Mass), nil
+	case "speed":
+		return starlark.Float(e.Attrs.Speed), nil
+	case "walkable":
+		return starlark.Bool(e.Walkable()), nil
+	case "traits":
+		traits := make(starlark.Tuple, len(e.Traits))
+		for i := range e.Traits {
+			traits[i] = starlark.String(e.Traits[i])
+		}
+		return traits, nil
+	case "alive":
+		return starlark.Bool(e.Alive()), nil
+	case "biomass":
+		return starlark.MakeInt(e.Biomass()), nil
+	}
+	if method, ok := scriptEntityMethods[name]; ok {
+		return method.BindReceiver(se), nil
+	}
+	return nil, nil
+}
+
+// scriptEntityMethods are the methods of Entities in scripts.
+var scriptEntityMethods = map[string]*starlark.Builtin{
+	"has_trait": starlark.NewBuiltin("has_trait", entityHasTrait),
+	"transfer":  starlark.NewBuiltin("transfer", entityTransfer),
+}
+
+func entityHasTrait(thread *starlark.Thread, b *starlark.Builtin, args starlark.Tuple, kwargs []starlark.Tuple) (starlark.Value, error) {
+	var trait string
+	if err := starlark.UnpackArgs(b.Name(), args, kwargs, "trait", &trait); err != nil {
+		return nil, err
+	}
+	return starlark.Bool(b.Receiver().(scriptEntity).e.HasTrait(Trait(trait))), nil
+}
+
+func entityTransfer(thread *starlark.Thread, b *starlark.Builtin, args starlark.Tuple, kwargs []starlark.Tuple) (starlark.Value, error) {
+	var energy int
+	if err := starlark.UnpackArgs(b.Name(), args, kwargs, "energy", &energy); err != nil {
+		return nil, err
+	}
+	return starlark.Bool(b.Receiver().(scriptEntity).e.Transfer(energy)), nil
+}
+
+// scriptField is a Field as a script sees it, from the same position as
+// the scriptWorld it came from.
+type scriptField struct {
+	f      *Field
+	w      *World
+	origin Vector
+}
+
+func (sf scriptField) String() string        { return fmt.Sprintf("<field %s>", sf.f.Name) }
+func (sf scriptField) Type() string          { return "field" }
+func (sf scriptField) Freeze()               {}
+func (sf scriptField) Truth() starlark.Bool  { return starlark.True }
+func (sf scriptField) Hash() (uint32, error) { return 0, errors.New("unhashable type: field") }
+
+func (sf scriptField) AttrNames() []string {
+	return methodNames(scriptFieldMethods, "name")
+}
+
+func (sf scriptField) Attr(name string) (starlark.Value, error) {
+	if name == "name" {
+		return starlark.String(sf.f.Name), nil
+	}
+	if method, ok := scriptFieldMethods[name]; ok {
+		return method.BindReceiver(sf), nil
+	}
+	return nil, nil
+}
+
+// scriptFieldMethods are the methods of Fields in scripts.
+var scriptFieldMethods = map[string]*starlark.Builtin{
+	"at":     starlark.NewBuiltin("at", fieldAt),
+	"add":    starlark.NewBuiltin("add", fieldAdd),
+	"take":   starlark.NewBuiltin("take", fieldTake),
+	"uphill": starlark.NewBuiltin("uphill", fieldUphill),
+}
+
+// fieldArgs unpacks the vector of a Field method, checking it's in bounds,
+// and an amount if one is wanted.
+func fieldArgs(b *starlark.Builtin, args starlark.Tuple, kwargs []starlark.Tuple, amount *float64) (scriptField, Vector, error) {
+	sf := b.Receiver().(scriptField)
+	var vec scriptVector
+	pairs := []interface{}{"vec", &vec}
+	if amount != nil {
+		pairs = append(pairs, "amount", amount)
+	}
+	if err := starlark.UnpackArgs(b.Name(), args, kwargs, pairs...); err != nil {
+		return sf, Vector{}, err
+	}
+	if err := reach(b, Vector(vec), 0); err != nil {
+		return sf, Vector{}, err
+	}
+	if !sf.w.InBounds(Vector(vec)) {
+		return sf, Vector{}, errors.Errorf("%s: (%d, %d, %d) is out of bounds", b.Name(), vec.X, vec.Y, vec.Z)
+	}
+	return sf, Vector(vec), nil
+}
+
+func fieldAt(thread *starlark.Thread, b *starlark.Builtin, args starlark.Tuple, kwargs []starlark.Tuple) (starlark.Value, error) {
+	sf, vec, err := fieldArgs(b, args, kwargs, nil)
+	if err != nil {
+		return nil, err
+	}
+	return starlark.Float(sf.f.At(vec)), nil
+}
+
+func fieldAdd(thread *starlark.Thread, b *starlark.Builtin, args starlark.Tuple, kwargs []starlark.Tuple) (starlark.Value, error) {
+	var amount float64
+	sf, vec, err := fieldArgs(b, args, kwargs, &amount)
+	if err != nil {
+		return nil, err
+	}
+	sf.f.Add(vec, amount)
+	return starlark.None, nil
+}
+
+func fieldTake(thread *starlark.Thread, b *starlark.Builtin, args starlark.Tuple, kwargs []starlark.Tuple) (starlark.Value, error) {
+	var amount float64
+	sf, vec, err := fieldArgs(b, args, kwargs, &amount)
+	if err != nil {
+		return nil, err
+	}
+	return starlark.Float(sf.f.Take(vec, amount)), nil
+}
+
+func fieldUphill(thread *starlark.Thread, b *starlark.Builtin, args starlark.Tuple, kwargs []starlark.Tuple) (starlark.Value, error) {
+	sf, vec, err := fieldArgs(b, args, kwargs, nil)
+	if err != nil {
+		return nil, err
+	}
+	if err := reach(b, vec, 1); err != nil {
+		return nil, err
+	}
+	dest, ok := sf.f.Uphill(sf.w, vec)
+	if !ok {
+		return starlark.None, nil
+	}
+	return vectorValue(dest), nil
+}
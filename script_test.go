@@ -0,0 +1,263 @@
+package ecoscript_test
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	. "github.com/dustinrohde/ecoscript"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Script", func() {
+	var dir string
+
+	// write writes a script file and returns its path. Scripts are cached by
+	// path, so each spec writes to its own directory.
+	write := func(name, src string) string {
+		path := filepath.Join(dir, name)
+		Expect(ioutil.WriteFile(path, []byte(src), 0644)).To(Succeed())
+		return path
+	}
+
+	BeforeEach(func() {
+		var err error
+		dir, err = ioutil.TempDir("", "script")
+		Expect(err).NotTo(HaveOccurred())
+	})
+
+	AfterEach(func() {
+		os.RemoveAll(dir)
+	})
+
+	It("should define and execute a Behavior", func() {
+		path := write("east.star", `
+def define(props):
+    return {"speed": props["speed"], "steps": 0}
+
+def execute(world, entity, vec, state):
+    dest = (vec[0] + 1, vec[1], vec[2])
+    if not world.walkable(dest):
+        return None
+    move = world.move(entity, vec, dest)
+
+    def step():
+        move()
+        entity.transfer(-1)
+        state["steps"] += 1
+
+    return state["speed"], step
+`)
+		world := NewWorld(4, 1, []string{"ground"})
+		world.AddSpecies(&Species{
+			Key:       "crab",
+			Name:      "crab",
+			Symbol:    "c",
+			Attrs:     Attributes{Energy: 10},
+			Abilities: []*Ability{{Name: "script", Properties: Properties{"file": path, "speed": 2}}},
+		})
+		crab := world.Species("crab").Spawn(world)
+		Expect(crab.Behaviors).To(HaveKey("Script:east"))
+		exec, ok := world.Add(crab, Vec(0, 0, 0))
+		Expect(ok).To(BeTrue())
+		exec()
+
+		for i := 0; i < 10; i++ {
+			world.Tick()
+		}
+		_, vec, ok := world.Find(crab.ID())
+		Expect(ok).To(BeTrue())
+		Expect(vec).To(Equal(Vec(3, 0, 0)))
+		Expect(crab.Attrs.Energy).To(Equal(7))
+		Expect(fmt.Sprint(crab.Behaviors["Script:east"])).To(ContainSubstring("steps:3"))
+
+		snap, err := world.Snapshot()
+		Expect(err).NotTo(HaveOccurred())
+		restored := NewWorld(4, 1, []string{"ground"})
+		Expect(restored.Restore(snap)).To(Succeed())
+		crab, _, ok = restored.Find(crab.ID())
+		Expect(ok).To(BeTrue())
+		Expect(fmt.Sprint(crab.Behaviors["Script:east"])).To(ContainSubstring("steps:3"))
+	})
+
+	It("should run scripts referenced by a Mapfile", func() {
+		mapfile, err := ParseMapfile("examples/scripted/Mapfile")
+		Expect(err).NotTo(HaveOccurred())
+		world, err := mapfile.ToWorld()
+		Expect(err).NotTo(HaveOccurred())
+		world.Seed(1)
+
+		var grazed int
+		world.Events().Subscribe(Filter{Types: []EventType{EventDied}}, func(ev Event) {
+			if ev.Detail == "grazed" {
+				grazed++
+			}
+		})
+		for i := 0; i < 200; i++ {
+			world.Tick()
+		}
+		Expect(grazed).To(BeNumerically(">", 0))
+	})
+
+	It("should reject invalid scripts and properties", func() {
+		path := write("graze.star", `
+def define(props):
+    if props.get("speed", 1) < 1:
+        fail("speed must be at least 1")
+    return props
+
+def execute(world, entity, vec):
+    return None
+`)
+		_, ok := NewBehavior("script", Properties{"file": path})
+		Expect(ok).To(BeTrue())
+		Expect(func() { NewBehavior("script", Properties{"file": path, "speed": 0}) }).
+			To(PanicWith(ContainSubstring("speed must be at least 1")))
+		Expect(func() { NewBehavior("script", Properties{}) }).To(Panic())
+		Expect(func() { NewBehavior("script", Properties{"file": filepath.Join(dir, "missing.star")}) }).To(Panic())
+
+		path = write("lazy.star", `
+def execute(world, entity):
+    return None
+`)
+		Expect(func() { NewBehavior("script", Properties{"file": path}) }).
+			To(PanicWith(ContainSubstring("execute must be a function")))
+	})
+
+	It("should keep scripts in a sandbox", func() {
+		path := write("escape.star", `
+load("os.star", "system")
+
+def execute(world, entity, vec):
+    return None
+`)
+		Expect(func() { NewBehavior("script", Properties{"file": path}) }).To(Panic())
+
+		path = write("spin.star", `
+def execute(world, entity, vec):
+    for i in range(100000000):
+        pass
+`)
+		behavior, ok := NewBehavior("script", Properties{"file": path})
+		Expect(ok).To(BeTrue())
+		world := NewWorld(1, 1, []string{"ground"})
+		var failures []Event
+		world.Events().Subscribe(Filter{Types: []EventType{EventFailed}}, func(ev Event) {
+			failures = append(failures, ev)
+		})
+		delay, exec := behavior.Execute(world, NewEntity("top", "o"), Vec(0, 0, 0))
+		Expect(delay).To(Equal(0))
+		Expect(exec).To(BeNil())
+		Expect(failures).To(HaveLen(1))
+		Expect(failures[0].Detail).To(ContainSubstring("too many steps"))
+	})
+
+	It("should report scripts that fail as the World runs", func() {
+		path := write("clumsy.star", `
+def execute(world, entity, vec):
+    def trip():
+        fail("tripped")
+    if world.tick % 2 == 0:
+        return 1, trip
+    fail("stumbled")
+`)
+		world := NewWorld(2, 1, []string{"ground"})
+		world.AddSpecies(&Species{
+			Key:       "clown",
+			Name:      "clown",
+			Attrs:     Attributes{Energy: 10},
+			Abilities: []*Ability{{Name: "script", Properties: Properties{"file": path}}},
+		})
+		exec, ok := world.Add(world.Species("clown").Spawn(world), Vec(0, 0, 0))
+		Expect(ok).To(BeTrue())
+		exec()
+
+		var failures []string
+		world.Events().Subscribe(Filter{Types: []EventType{EventFailed}}, func(ev Event) {
+			failures = append(failures, ev.Detail)
+		})
+		Expect(func() {
+			world.Tick()
+			world.Tick()
+		}).NotTo(Panic())
+		Expect(failures).To(HaveLen(2))
+		Expect(failures[0]).To(ContainSubstring("tripped"))
+		Expect(failures[1]).To(ContainSubstring("stumbled"))
+	})
+
+	It("should tell apart scripts with the same relative path", func() {
+		wd, err := os.Getwd()
+		Expect(err).NotTo(HaveOccurred())
+		defer os.Chdir(wd)
+
+		for _, name := range []string{"a", "b"} {
+			Expect(os.Mkdir(filepath.Join(dir, name), 0755)).To(Succeed())
+			write(filepath.Join(name, "same.star"), `
+def define(props):
+    return {"dir": "`+name+`"}
+
+def execute(world, entity, vec):
+    return None
+`)
+		}
+		for _, name := range []string{"a", "b"} {
+			Expect(os.Chdir(filepath.Join(dir, name))).To(Succeed())
+			behavior, ok := NewBehavior("script", Properties{"file": "same.star"})
+			Expect(ok).To(BeTrue())
+			Expect(fmt.Sprint(behavior)).To(ContainSubstring("dir:" + name))
+		}
+	})
+
+	It("should freeze the globals of scripts", func() {
+		path := write("hoard.star", `
+seen = []
+
+def execute(world, entity, vec):
+    seen.append(entity)
+`)
+		behavior, ok := NewBehavior("script", Properties{"file": path})
+		Expect(ok).To(BeTrue())
+		world := NewWorld(1, 1, []string{"ground"})
+		var failures []string
+		world.Events().Subscribe(Filter{Types: []EventType{EventFailed}}, func(ev Event) {
+			failures = append(failures, ev.Detail)
+		})
+		behavior.Execute(world, NewEntity("top", "o"), Vec(0, 0, 0))
+		Expect(failures).To(HaveLen(1))
+		Expect(failures[0]).To(ContainSubstring("frozen"))
+	})
+
+	It("should keep scripts within reach of their Entity", func() {
+		path := write("reach.star", `
+def execute(world, entity, vec, state):
+    far = (vec[0] + state["dx"], vec[1], vec[2])
+    if state["call"] == "view":
+        world.view(vec, radius = state["dx"])
+    elif state["call"] == "destroy":
+        world.destroy(entity, far)
+    else:
+        world.field("nutrients").at(far)
+`)
+		world := NewWorld(30, 1, []string{"ground"})
+		world.AddField(FieldNutrients, 0, 0)
+		var failures []string
+		world.Events().Subscribe(Filter{Types: []EventType{EventFailed}}, func(ev Event) {
+			failures = append(failures, ev.Detail)
+		})
+		execute := func(call string, dx int) []string {
+			behavior, ok := NewBehavior("script", Properties{"file": path, "call": call, "dx": dx})
+			Expect(ok).To(BeTrue())
+			failures = nil
+			behavior.Execute(world, NewEntity("top", "o"), Vec(5, 0, 0))
+			return failures
+		}
+
+		for _, call := range []string{"view", "destroy", "field"} {
+			Expect(execute(call, DefaultReach)).To(BeEmpty(), call)
+			Expect(execute(call, DefaultReach+1)).To(ConsistOf(ContainSubstring("out of reach")), call)
+		}
+	})
+})
@@ -0,0 +1,104 @@
+package server
+
+import (
+	"bufio"
+	"encoding/json"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// Mirror follows the stream of a Server, keeping a View of its World up to
+// date, so that another process can watch the World live.
+type Mirror struct {
+	body   io.ReadCloser
+	reader *bufio.Reader
+	view   *View
+}
+
+// Follow connects to the stream of the Server at the given base URL, like
+// "http://localhost:8080", and waits for its first keyframe.
+func Follow(url string) (*Mirror, error) {
+	resp, err := http.Get(strings.TrimSuffix(url, "/") + "/stream")
+	if err != nil {
+		return nil, errors.Wrap(err, "following stream")
+	}
+	if resp.StatusCode != http.StatusOK {
+		resp.Body.Close()
+		return nil, errors.Errorf("following stream: %s", resp.Status)
+	}
+
+	m := &Mirror{body: resp.Body, reader: bufio.NewReader(resp.Body)}
+	if _, err := m.Next(); err != nil {
+		m.Close()
+		return nil, err
+	}
+	return m, nil
+}
+
+// View returns the View of the World as of the last update.
+func (m *Mirror) View() *View {
+	return m.view
+}
+
+// Next waits for the next update of the stream and applies it to the View.
+// It returns the Diff, or nil if the update was a keyframe.
+func (m *Mirror) Next() (*Diff, error) {
+	event, data, err := m.read()
+	if err != nil {
+		return nil, err
+	}
+	switch event {
+	case "keyframe":
+		view := new(View)
+		if err := json.Unmarshal(data, view); err != nil {
+			return nil, errors.Wrap(err, "decoding keyframe")
+		}
+		m.view = view
+		return nil, nil
+	case "diff":
+		if m.view == nil {
+			return nil, errors.New("diff before the first keyframe")
+		}
+		d := new(Diff)
+		if err := json.Unmarshal(data, d); err != nil {
+			return nil, errors.Wrap(err, "decoding diff")
+		}
+		if err := m.view.Apply(*d); err != nil {
+			return nil, err
+		}
+		return d, nil
+	}
+	return nil, errors.Errorf("unknown event '%s'", event)
+}
+
+// read reads the next server-sent event.
+func (m *Mirror) read() (event string, data []byte, err error) {
+	var lines []string
+	for {
+		line, err := m.reader.ReadString('\n')
+		if err != nil {
+			return "", nil, err
+		}
+		line = strings.TrimRight(line, "\r\n")
+		switch {
+		case line == "":
+			if event != "" || len(lines) > 0 {
+				return event, []byte(strings.Join(lines, "\n")), nil
+			}
+		case strings.HasPrefix(line, ":"):
+			// A comment.
+		case strings.HasPrefix(line, "event:"):
+			event = strings.TrimSpace(strings.TrimPrefix(line, "event:"))
+		case strings.HasPrefix(line, "data:"):
+			lines = append(lines, strings.TrimPrefix(strings.TrimPrefix(line, "data:"), " "))
+		}
+	}
+}
+
+// Close disconnects from the stream.
+func (m *Mirror) Close() error {
+	return m.body.Close()
+}
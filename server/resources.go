@@ -0,0 +1,224 @@
+package server
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/dustinrohde/ecoscript"
+)
+
+// The resources of the API, as encoded to JSON. Each is described by the
+// schema of the same name.
+
+// World describes a World and the state of its run.
+type World struct {
+	Width   int      `json:"width"`
+	Height  int      `json:"height"`
+	Depth   int      `json:"depth"`
+	Tick    int      `json:"tick"`
+	Day     int      `json:"day"`
+	Hour    int      `json:"hour"`
+	Season  string   `json:"season"`
+	Paused  bool     `json:"paused"`
+	Layers  []string `json:"layers"`
+	Species []string `json:"species"`
+	Fields  []string `json:"fields"`
+	Weather []string `json:"weather"`
+}
+
+// Layer is a grid of the symbols in a Layer, a row per string.
+type Layer struct {
+	Z      int      `json:"z"`
+	Name   string   `json:"name"`
+	Width  int      `json:"width"`
+	Height int      `json:"height"`
+	Rows   []string `json:"rows"`
+}
+
+// Vector is a position in a World.
+type Vector struct {
+	X int `json:"x"`
+	Y int `json:"y"`
+	Z int `json:"z"`
+}
+
+// Cell describes a Cell, its Terrain, the value of each Field there and the
+// Entities in it, bottom first.
+type Cell struct {
+	Vector
+	Terrain  Terrain            `json:"terrain"`
+	Fields   map[string]float64 `json:"fields"`
+	Entities []Entity           `json:"entities"`
+}
+
+// Terrain is the ground of a Cell.
+type Terrain struct {
+	Kind      string  `json:"kind"`
+	Moisture  float64 `json:"moisture"`
+	Fertility float64 `json:"fertility"`
+	Elevation float64 `json:"elevation"`
+}
+
+// Entity describes an Entity and what it is doing.
+type Entity struct {
+	ID         ecoscript.EntityID `json:"id"`
+	Species    string             `json:"species"`
+	Name       string             `json:"name"`
+	Symbol     string             `json:"symbol"`
+	Vec        Vector             `json:"vec"`
+	Attributes Attributes         `json:"attributes"`
+	Traits     []string           `json:"traits"`
+	Behaviors  []string           `json:"behaviors"`
+	Behavior   string             `json:"behavior"`
+	Alive      bool               `json:"alive"`
+}
+
+// Attributes are the Attributes of an Entity.
+type Attributes struct {
+	Walkable bool    `json:"walkable"`
+	Energy   int     `json:"energy"`
+	Size     int     `json:"size"`
+	Mass     int     `json:"mass"`
+	Speed    float64 `json:"speed"`
+}
+
+// AttributesPatch edits the Attributes of an Entity. Missing Attributes are
+// left as they are.
+type AttributesPatch struct {
+	Walkable *bool    `json:"walkable"`
+	Energy   *int     `json:"energy"`
+	Size     *int     `json:"size"`
+	Mass     *int     `json:"mass"`
+	Speed    *float64 `json:"speed"`
+}
+
+// SpawnRequest spawns an Entity of a Species in a Cell.
+type SpawnRequest struct {
+	Species string `json:"species"`
+	Vec     Vector `json:"vec"`
+}
+
+// Error is the body of every response that fails.
+type Error struct {
+	Error string `json:"error"`
+}
+
+func newWorld(w *ecoscript.World, paused bool) World {
+	clock := w.Clock()
+	world := World{
+		Width:   w.Width(),
+		Height:  w.Height(),
+		Depth:   w.Depth(),
+		Tick:    clock.Ticks(),
+		Day:     clock.Day(),
+		Hour:    clock.Hour(),
+		Season:  clock.Season().Name,
+		Paused:  paused,
+		Layers:  make([]string, w.Depth()),
+		Species: w.SpeciesKeys(),
+		Fields:  make([]string, 0),
+		Weather: make([]string, 0),
+	}
+	for z := range world.Layers {
+		world.Layers[z] = w.Layer(z).Name()
+	}
+	for _, field := range w.Fields() {
+		world.Fields = append(world.Fields, field.Name)
+	}
+	for _, wx := range w.Weather() {
+		if wx.Active() {
+			world.Weather = append(world.Weather, wx.Kind)
+		}
+	}
+	return world
+}
+
+func newLayer(w *ecoscript.World, z int) Layer {
+	layer := w.Layer(z)
+	grid := layer.DisplayWith(ecoscript.DisplayOptions{EmptyTile: w.EmptyTile()})
+	return Layer{
+		Z:      z,
+		Name:   layer.Name(),
+		Width:  layer.Width(),
+		Height: layer.Height(),
+		Rows:   strings.Split(strings.TrimSuffix(grid, "\n"), "\n"),
+	}
+}
+
+func newVector(vec ecoscript.Vector) Vector {
+	return Vector{vec.X, vec.Y, vec.Z}
+}
+
+func (v Vector) vec() ecoscript.Vector {
+	return ecoscript.Vec(v.X, v.Y, v.Z)
+}
+
+func newCell(w *ecoscript.World, vec ecoscript.Vector) Cell {
+	terrain := w.Cell(vec).Terrain()
+	cell := Cell{
+		Vector: newVector(vec),
+		Terrain: Terrain{
+			Kind:      terrain.Kind,
+			Moisture:  terrain.Moisture,
+			Fertility: terrain.Fertility,
+			Elevation: terrain.Elevation,
+		},
+		Fields:   make(map[string]float64),
+		Entities: make([]Entity, 0),
+	}
+	for _, field := range w.Fields() {
+		cell.Fields[field.Name] = field.At(vec)
+	}
+	for _, ent := range w.Cell(vec).Entities() {
+		cell.Entities = append(cell.Entities, newEntity(ent, vec))
+	}
+	return cell
+}
+
+func newEntity(ent *ecoscript.Entity, vec ecoscript.Vector) Entity {
+	entity := Entity{
+		ID:      ent.ID(),
+		Species: ent.Species(),
+		Name:    ent.Name,
+		Symbol:  ent.Symbol,
+		Vec:     newVector(vec),
+		Attributes: Attributes{
+			Walkable: ent.Attrs.Walkable,
+			Energy:   ent.Attrs.Energy,
+			Size:     ent.Attrs.Size,
+			Mass:     ent.Attrs.Mass,
+			Speed:    ent.Attrs.Speed,
+		},
+		Traits:    make([]string, len(ent.Traits)),
+		Behaviors: make([]string, 0, len(ent.Behaviors)),
+		Behavior:  ent.Behavior(),
+		Alive:     ent.Alive(),
+	}
+	for i, trait := range ent.Traits {
+		entity.Traits[i] = string(trait)
+	}
+	for key := range ent.Behaviors {
+		entity.Behaviors = append(entity.Behaviors, key)
+	}
+	sort.Strings(entity.Behaviors)
+	return entity
+}
+
+// apply edits the Attributes of an Entity.
+func (p AttributesPatch) apply(attrs *ecoscript.Attributes) {
+	if p.Walkable != nil {
+		attrs.Walkable = *p.Walkable
+	}
+	if p.Energy != nil {
+		attrs.Energy = *p.Energy
+	}
+	if p.Size != nil {
+		attrs.Size = *p.Size
+	}
+	if p.Mass != nil {
+		attrs.Mass = *p.Mass
+	}
+	if p.Speed != nil {
+		attrs.Speed = *p.Speed
+	}
+}
@@ -0,0 +1,221 @@
+package server
+
+import (
+	"encoding/json"
+	"strings"
+)
+
+// Schemas are the JSON schemas of the resources of the API, by name.
+var Schemas = map[string]json.RawMessage{
+	"world":      schema("World", worldSchema),
+	"layer":      schema("Layer", layerSchema),
+	"cell":       schema("Cell", cellSchema),
+	"entity":     schema("Entity", entitySchema),
+	"attributes": schema("AttributesPatch", patchSchema),
+	"spawn":      schema("SpawnRequest", spawnSchema),
+	"stats":      schema("Sample", statsSchema),
+	"keyframe":   schema("View", viewSchema),
+	"diff":       schema("Diff", diffSchema),
+	"error":      schema("Error", errorSchema),
+}
+
+// schema completes the body of a schema of an object.
+func schema(title, body string) json.RawMessage {
+	s := `{"$schema": "http://json-schema.org/draft-07/schema#", "title": "` + title + `", ` +
+		strings.TrimPrefix(body, "{")
+	if !json.Valid([]byte(s)) {
+		panic("invalid schema for " + title)
+	}
+	return json.RawMessage(s)
+}
+
+const (
+	intsSchema = `{"type": "object", "additionalProperties": {"type": "integer"}}`
+
+	vectorSchema = `{
+		"type": "object",
+		"required": ["x", "y", "z"],
+		"properties": {
+			"x": {"type": "integer", "minimum": 0},
+			"y": {"type": "integer", "minimum": 0},
+			"z": {"type": "integer", "minimum": 0}
+		}
+	}`
+
+	worldSchema = `{
+		"type": "object",
+		"required": ["width", "height", "depth", "tick", "day", "hour", "season", "paused", "layers", "species", "fields", "weather"],
+		"properties": {
+			"width": {"type": "integer"},
+			"height": {"type": "integer"},
+			"depth": {"type": "integer"},
+			"tick": {"type": "integer"},
+			"day": {"type": "integer"},
+			"hour": {"type": "integer"},
+			"season": {"type": "string"},
+			"paused": {"type": "boolean"},
+			"layers": {"type": "array", "items": {"type": "string"}},
+			"species": {"type": "array", "items": {"type": "string"}},
+			"fields": {"type": "array", "items": {"type": "string"}},
+			"weather": {"type": "array", "items": {"type": "string"}}
+		}
+	}`
+
+	layerSchema = `{
+		"type": "object",
+		"required": ["z", "name", "width", "height", "rows"],
+		"properties": {
+			"z": {"type": "integer"},
+			"name": {"type": "string"},
+			"width": {"type": "integer"},
+			"height": {"type": "integer"},
+			"rows": {"type": "array", "items": {"type": "string"}}
+		}
+	}`
+
+	attributesSchema = `{
+		"type": "object",
+		"required": ["walkable", "energy", "size", "mass", "speed"],
+		"properties": {
+			"walkable": {"type": "boolean"},
+			"energy": {"type": "integer"},
+			"size": {"type": "integer"},
+			"mass": {"type": "integer"},
+			"speed": {"type": "number"}
+		}
+	}`
+
+	entitySchema = `{
+		"type": "object",
+		"required": ["id", "species", "name", "symbol", "vec", "attributes", "traits", "behaviors", "behavior", "alive"],
+		"properties": {
+			"id": {"type": "integer"},
+			"species": {"type": "string"},
+			"name": {"type": "string"},
+			"symbol": {"type": "string"},
+			"vec": ` + vectorSchema + `,
+			"attributes": ` + attributesSchema + `,
+			"traits": {"type": "array", "items": {"type": "string"}},
+			"behaviors": {"type": "array", "items": {"type": "string"}},
+			"behavior": {"type": "string"},
+			"alive": {"type": "boolean"}
+		}
+	}`
+
+	cellSchema = `{
+		"type": "object",
+		"required": ["x", "y", "z", "terrain", "fields", "entities"],
+		"properties": {
+			"x": {"type": "integer"},
+			"y": {"type": "integer"},
+			"z": {"type": "integer"},
+			"terrain": {
+				"type": "object",
+				"required": ["kind", "moisture", "fertility", "elevation"],
+				"properties": {
+					"kind": {"type": "string"},
+					"moisture": {"type": "number"},
+					"fertility": {"type": "number"},
+					"elevation": {"type": "number"}
+				}
+			},
+			"fields": {"type": "object", "additionalProperties": {"type": "number"}},
+			"entities": {"type": "array", "items": ` + entitySchema + `}
+		}
+	}`
+
+	patchSchema = `{
+		"type": "object",
+		"additionalProperties": false,
+		"properties": {
+			"walkable": {"type": "boolean"},
+			"energy": {"type": "integer"},
+			"size": {"type": "integer"},
+			"mass": {"type": "integer"},
+			"speed": {"type": "number"}
+		}
+	}`
+
+	spawnSchema = `{
+		"type": "object",
+		"required": ["species", "vec"],
+		"additionalProperties": false,
+		"properties": {
+			"species": {"type": "string"},
+			"vec": ` + vectorSchema + `
+		}
+	}`
+
+	statsSchema = `{
+		"type": "object",
+		"required": ["tick", "population", "traits", "total_energy", "mean_energy", "biomass", "births", "deaths", "causes"],
+		"properties": {
+			"tick": {"type": "integer"},
+			"population": ` + intsSchema + `,
+			"traits": ` + intsSchema + `,
+			"total_energy": {"type": "integer"},
+			"mean_energy": {"type": "number"},
+			"biomass": {"type": "integer"},
+			"births": {"type": "integer"},
+			"deaths": {"type": "integer"},
+			"causes": ` + intsSchema + `
+		}
+	}`
+
+	viewSchema = `{
+		"type": "object",
+		"required": ["world", "symbols", "entities"],
+		"properties": {
+			"world": ` + worldSchema + `,
+			"symbols": {
+				"type": "array",
+				"items": {"type": "array", "items": {"type": "array", "items": {"type": "string"}}}
+			},
+			"entities": {"type": "object", "additionalProperties": ` + entitySchema + `}
+		}
+	}`
+
+	diffSchema = `{
+		"type": "object",
+		"required": ["world", "spawned", "moved", "died", "changed", "cells"],
+		"properties": {
+			"world": ` + worldSchema + `,
+			"spawned": {"type": "array", "items": ` + entitySchema + `},
+			"moved": {
+				"type": "array",
+				"items": {
+					"type": "object",
+					"required": ["id", "from", "to"],
+					"properties": {
+						"id": {"type": "integer"},
+						"from": ` + vectorSchema + `,
+						"to": ` + vectorSchema + `
+					}
+				}
+			},
+			"died": {"type": "array", "items": {"type": "integer"}},
+			"changed": {"type": "array", "items": ` + entitySchema + `},
+			"cells": {
+				"type": "array",
+				"items": {
+					"type": "object",
+					"required": ["x", "y", "z", "symbol"],
+					"properties": {
+						"x": {"type": "integer"},
+						"y": {"type": "integer"},
+						"z": {"type": "integer"},
+						"symbol": {"type": "string"}
+					}
+				}
+			}
+		}
+	}`
+
+	errorSchema = `{
+		"type": "object",
+		"required": ["error"],
+		"properties": {
+			"error": {"type": "string"}
+		}
+	}`
+)
@@ -0,0 +1,377 @@
+// Package server serves a World over HTTP, so that it can be observed and
+// controlled from notebooks and dashboards while it runs. Every resource is
+// JSON, described by a JSON schema served at /schema.
+//
+//	GET   /world               the World and the state of its run
+//	GET   /layers/{layer}      a grid of the symbols in a Layer, by name or Z
+//	GET   /cells/{x}/{y}/{z}   a Cell and the Entities in it
+//	GET   /entities/{id}       an Entity
+//	PATCH /entities/{id}       edit the Attributes of an Entity
+//	POST  /entities            spawn an Entity of a Species
+//	GET   /stats               a census of the World
+//	POST  /pause               pause the run
+//	POST  /resume              resume the run
+//	POST  /step?n=N            tick the World N times, or once
+//	GET   /stream              server-sent keyframes and diffs of the World
+//	GET   /schema              the schemas of the resources, by name
+//	GET   /schema/{name}       the schema of a resource
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/dustinrohde/ecoscript"
+)
+
+const (
+	// DefaultDelay is the time to wait between ticks while running.
+	DefaultDelay = 500 * time.Millisecond
+
+	// maxSteps is the most ticks a single step request may ask for.
+	maxSteps = 10000
+)
+
+// Server runs a World and serves it over HTTP. Requests may be made while it
+// ticks: the World is only ever used by one of them at a time.
+type Server struct {
+	mu     sync.Mutex
+	world  *ecoscript.World
+	paused bool
+	delay  time.Duration
+	mux    *http.ServeMux
+
+	// reset wakes Run when the delay changes.
+	reset chan struct{}
+
+	// view is the View last sent to subscribers of the stream, if any, and
+	// updates the number of updates sent since the Server started.
+	view        *View
+	updates     int
+	keyframes   int
+	subscribers map[*subscriber]bool
+}
+
+// New creates a Server for world.
+func New(world *ecoscript.World) *Server {
+	s := &Server{
+		world:       world,
+		delay:       DefaultDelay,
+		mux:         http.NewServeMux(),
+		reset:       make(chan struct{}, 1),
+		keyframes:   DefaultKeyframeInterval,
+		subscribers: make(map[*subscriber]bool),
+	}
+	s.mux.HandleFunc("/world", s.handleWorld)
+	s.mux.HandleFunc("/layers/", s.handleLayer)
+	s.mux.HandleFunc("/cells/", s.handleCell)
+	s.mux.HandleFunc("/entities", s.handleSpawn)
+	s.mux.HandleFunc("/entities/", s.handleEntity)
+	s.mux.HandleFunc("/stats", s.handleStats)
+	s.mux.HandleFunc("/pause", s.handlePause(true))
+	s.mux.HandleFunc("/resume", s.handlePause(false))
+	s.mux.HandleFunc("/step", s.handleStep)
+	s.mux.HandleFunc("/stream", s.handleStream)
+	s.mux.HandleFunc("/schema", s.handleSchemas)
+	s.mux.HandleFunc("/schema/", s.handleSchema)
+	return s
+}
+
+// SetPaused sets whether the Server starts paused.
+func (s *Server) SetPaused(paused bool) *Server {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.paused = paused
+	return s
+}
+
+// SetDelay sets the time to wait between ticks while running.
+func (s *Server) SetDelay(delay time.Duration) *Server {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.delay = delay
+	select {
+	case s.reset <- struct{}{}:
+	default:
+	}
+	return s
+}
+
+// Delay returns the time to wait between ticks while running.
+func (s *Server) Delay() time.Duration {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.delay
+}
+
+// Paused reports whether the run is paused.
+func (s *Server) Paused() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.paused
+}
+
+// Do calls fn with the World while no request is using it, for code that
+// shares the World with the Server. What fn changes is streamed.
+func (s *Server) Do(fn func(world *ecoscript.World)) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	fn(s.world)
+	s.publish()
+}
+
+// Run ticks the World every delay, unless paused, until stop is closed. A
+// new delay takes effect at once.
+func (s *Server) Run(stop <-chan struct{}) {
+	timer := time.NewTimer(s.Delay())
+	defer timer.Stop()
+	for {
+		select {
+		case <-stop:
+			return
+		case <-s.reset:
+			if !timer.Stop() {
+				<-timer.C
+			}
+		case <-timer.C:
+			s.mu.Lock()
+			if !s.paused {
+				s.world.Tick()
+				s.publish()
+			}
+			s.mu.Unlock()
+		}
+		timer.Reset(s.Delay())
+	}
+}
+
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mux.ServeHTTP(w, r)
+}
+
+// ---------------------------------------------------------------------
+// Handlers
+
+func (s *Server) handleWorld(w http.ResponseWriter, r *http.Request) {
+	if !allow(w, r, http.MethodGet) {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	respond(w, http.StatusOK, newWorld(s.world, s.paused))
+}
+
+func (s *Server) handleLayer(w http.ResponseWriter, r *http.Request) {
+	if !allow(w, r, http.MethodGet) {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	name := strings.TrimPrefix(r.URL.Path, "/layers/")
+	z, ok := s.world.LayerIndex(name)
+	if !ok {
+		var err error
+		if z, err = strconv.Atoi(name); err != nil || z < 0 || z >= s.world.Depth() {
+			fail(w, http.StatusNotFound, "no layer '%s'", name)
+			return
+		}
+	}
+	respond(w, http.StatusOK, newLayer(s.world, z))
+}
+
+func (s *Server) handleCell(w http.ResponseWriter, r *http.Request) {
+	if !allow(w, r, http.MethodGet) {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	parts := strings.Split(strings.TrimPrefix(r.URL.Path, "/cells/"), "/")
+	coords := make([]int, len(parts))
+	for i, part := range parts {
+		n, err := strconv.Atoi(part)
+		if err != nil {
+			fail(w, http.StatusBadRequest, "cells are addressed by /cells/X/Y/Z")
+			return
+		}
+		coords[i] = n
+	}
+	if len(coords) != 3 {
+		fail(w, http.StatusBadRequest, "cells are addressed by /cells/X/Y/Z")
+		return
+	}
+	vec := ecoscript.Vec(coords[0], coords[1], coords[2])
+	if !s.world.InBounds(vec) {
+		fail(w, http.StatusNotFound, "no cell at (%d,%d,%d)", vec.X, vec.Y, vec.Z)
+		return
+	}
+	respond(w, http.StatusOK, newCell(s.world, vec))
+}
+
+func (s *Server) handleEntity(w http.ResponseWriter, r *http.Request) {
+	if !allow(w, r, http.MethodGet, http.MethodPatch) {
+		return
+	}
+	id, err := strconv.Atoi(strings.TrimPrefix(r.URL.Path, "/entities/"))
+	if err != nil {
+		fail(w, http.StatusBadRequest, "entities are addressed by /entities/ID")
+		return
+	}
+	var patch AttributesPatch
+	if r.Method == http.MethodPatch && !decode(w, r, &patch) {
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	ent, vec, ok := s.world.Find(ecoscript.EntityID(id))
+	if !ok {
+		fail(w, http.StatusNotFound, "no entity #%d", id)
+		return
+	}
+	if r.Method == http.MethodPatch {
+		patch.apply(ent.Attrs)
+		s.publish()
+	}
+	respond(w, http.StatusOK, newEntity(ent, vec))
+}
+
+func (s *Server) handleSpawn(w http.ResponseWriter, r *http.Request) {
+	if !allow(w, r, http.MethodPost) {
+		return
+	}
+	var req SpawnRequest
+	if !decode(w, r, &req) {
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	species := s.world.Species(req.Species)
+	if species == nil {
+		fail(w, http.StatusNotFound, "no species '%s'", req.Species)
+		return
+	}
+	vec := req.Vec.vec()
+	if !s.world.InBounds(vec) {
+		fail(w, http.StatusBadRequest, "no cell at (%d,%d,%d)", vec.X, vec.Y, vec.Z)
+		return
+	}
+	ent := species.Spawn(s.world)
+	exec, ok := s.world.Spawn(ent, vec)
+	if !ok {
+		fail(w, http.StatusConflict, "cell (%d,%d,%d) has no room for a %s", vec.X, vec.Y, vec.Z, req.Species)
+		return
+	}
+	exec()
+	s.publish()
+	respond(w, http.StatusCreated, newEntity(ent, vec))
+}
+
+func (s *Server) handleStats(w http.ResponseWriter, r *http.Request) {
+	if !allow(w, r, http.MethodGet) {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	respond(w, http.StatusOK, ecoscript.Census(s.world))
+}
+
+func (s *Server) handlePause(paused bool) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if !allow(w, r, http.MethodPost) {
+			return
+		}
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		s.paused = paused
+		s.publish()
+		respond(w, http.StatusOK, newWorld(s.world, s.paused))
+	}
+}
+
+func (s *Server) handleStep(w http.ResponseWriter, r *http.Request) {
+	if !allow(w, r, http.MethodPost) {
+		return
+	}
+	n := 1
+	if q := r.URL.Query().Get("n"); q != "" {
+		var err error
+		if n, err = strconv.Atoi(q); err != nil || n < 1 || n > maxSteps {
+			fail(w, http.StatusBadRequest, "n must be from 1 to %d", maxSteps)
+			return
+		}
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for i := 0; i < n; i++ {
+		s.world.Tick()
+		s.publish()
+	}
+	respond(w, http.StatusOK, newWorld(s.world, s.paused))
+}
+
+func (s *Server) handleSchemas(w http.ResponseWriter, r *http.Request) {
+	if !allow(w, r, http.MethodGet) {
+		return
+	}
+	respond(w, http.StatusOK, Schemas)
+}
+
+func (s *Server) handleSchema(w http.ResponseWriter, r *http.Request) {
+	if !allow(w, r, http.MethodGet) {
+		return
+	}
+	name := strings.TrimPrefix(r.URL.Path, "/schema/")
+	schema, ok := Schemas[name]
+	if !ok {
+		fail(w, http.StatusNotFound, "no schema '%s'", name)
+		return
+	}
+	respond(w, http.StatusOK, schema)
+}
+
+// ---------------------------------------------------------------------
+// Helpers
+
+// allow reports whether the request uses one of the given methods, and
+// responds with an error if not.
+func allow(w http.ResponseWriter, r *http.Request, methods ...string) bool {
+	for _, method := range methods {
+		if r.Method == method {
+			return true
+		}
+	}
+	w.Header().Set("Allow", strings.Join(methods, ", "))
+	fail(w, http.StatusMethodNotAllowed, "method %s not allowed", r.Method)
+	return false
+}
+
+// decode reads the JSON body of a request into v, and responds with an error
+// if it can't.
+func decode(w http.ResponseWriter, r *http.Request, v interface{}) bool {
+	dec := json.NewDecoder(r.Body)
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(v); err != nil {
+		fail(w, http.StatusBadRequest, "invalid request body: %s", err)
+		return false
+	}
+	return true
+}
+
+func respond(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+func fail(w http.ResponseWriter, status int, format string, args ...interface{}) {
+	respond(w, status, Error{fmt.Sprintf(format, args...)})
+}
@@ -0,0 +1,229 @@
+package server_test
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/dustinrohde/ecoscript"
+	. "github.com/dustinrohde/ecoscript/server"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Server", func() {
+	var (
+		world  *ecoscript.World
+		sheep  *ecoscript.Entity
+		server *Server
+		ts     *httptest.Server
+	)
+
+	BeforeEach(func() {
+		world = ecoscript.NewWorld(4, 3, []string{"ground", "sky"})
+		world.Seed(1)
+		world.SetEmptyTile(".")
+		world.AddSpecies(&ecoscript.Species{
+			Key:    "sheep",
+			Name:   "sheep",
+			Symbol: "&",
+			Attrs:  ecoscript.Attributes{Energy: 20, Size: 2, Mass: 10},
+		})
+		sheep = world.Species("sheep").Spawn(world)
+		exec, ok := world.Spawn(sheep, ecoscript.Vec(1, 2, 0))
+		Expect(ok).To(BeTrue())
+		exec()
+		world.AddField("scent", 0, 0).Set(ecoscript.Vec(1, 2, 0), 0.5)
+
+		server = New(world).SetPaused(true)
+		ts = httptest.NewServer(server)
+	})
+
+	AfterEach(func() {
+		ts.Close()
+	})
+
+	// call makes a request and decodes the JSON response into v, checking
+	// that it has every field its schema requires.
+	call := func(method, path, body, schema string, v interface{}) int {
+		req, err := http.NewRequest(method, ts.URL+path, strings.NewReader(body))
+		Expect(err).NotTo(HaveOccurred())
+		resp, err := http.DefaultClient.Do(req)
+		Expect(err).NotTo(HaveOccurred())
+		defer resp.Body.Close()
+		Expect(resp.Header.Get("Content-Type")).To(Equal("application/json"))
+
+		var raw map[string]json.RawMessage
+		Expect(json.NewDecoder(resp.Body).Decode(&raw)).To(Succeed())
+		var s struct {
+			Required []string `json:"required"`
+		}
+		Expect(json.Unmarshal(Schemas[schema], &s)).To(Succeed())
+		for _, key := range s.Required {
+			Expect(raw).To(HaveKey(key), "%s %s", method, path)
+		}
+
+		if v != nil {
+			data, _ := json.Marshal(raw)
+			Expect(json.Unmarshal(data, v)).To(Succeed())
+		}
+		return resp.StatusCode
+	}
+
+	It("should describe the World", func() {
+		var w World
+		Expect(call("GET", "/world", "", "world", &w)).To(Equal(http.StatusOK))
+		Expect(w.Width).To(Equal(4))
+		Expect(w.Layers).To(Equal([]string{"ground", "sky"}))
+		Expect(w.Species).To(Equal([]string{"sheep"}))
+		Expect(w.Fields).To(Equal([]string{"scent"}))
+		Expect(w.Paused).To(BeTrue())
+	})
+
+	It("should draw Layers by name or index", func() {
+		var layer Layer
+		Expect(call("GET", "/layers/ground", "", "layer", &layer)).To(Equal(http.StatusOK))
+		Expect(layer.Rows).To(Equal([]string{"....", "....", ".&.."}))
+		Expect(call("GET", "/layers/1", "", "layer", &layer)).To(Equal(http.StatusOK))
+		Expect(layer.Name).To(Equal("sky"))
+		Expect(call("GET", "/layers/sea", "", "error", nil)).To(Equal(http.StatusNotFound))
+	})
+
+	It("should describe Cells and Entities", func() {
+		var cell Cell
+		Expect(call("GET", "/cells/1/2/0", "", "cell", &cell)).To(Equal(http.StatusOK))
+		Expect(cell.Fields).To(Equal(map[string]float64{"scent": 0.5}))
+		Expect(cell.Entities).To(HaveLen(1))
+		Expect(cell.Entities[0].ID).To(Equal(sheep.ID()))
+
+		var ent Entity
+		path := fmt.Sprintf("/entities/%d", sheep.ID())
+		Expect(call("GET", path, "", "entity", &ent)).To(Equal(http.StatusOK))
+		Expect(ent.Species).To(Equal("sheep"))
+		Expect(ent.Vec).To(Equal(Vector{1, 2, 0}))
+		Expect(ent.Attributes.Energy).To(Equal(20))
+
+		Expect(call("GET", "/cells/9/9/0", "", "error", nil)).To(Equal(http.StatusNotFound))
+		Expect(call("GET", "/cells/1/x", "", "error", nil)).To(Equal(http.StatusBadRequest))
+		Expect(call("GET", "/entities/999", "", "error", nil)).To(Equal(http.StatusNotFound))
+	})
+
+	It("should edit Attributes", func() {
+		var ent Entity
+		path := fmt.Sprintf("/entities/%d", sheep.ID())
+		Expect(call("PATCH", path, `{"energy": 5, "walkable": true}`, "entity", &ent)).To(Equal(http.StatusOK))
+		Expect(ent.Attributes.Energy).To(Equal(5))
+		Expect(sheep.Attrs.Energy).To(Equal(5))
+		Expect(sheep.Attrs.Walkable).To(BeTrue())
+		Expect(sheep.Attrs.Mass).To(Equal(10))
+
+		Expect(call("PATCH", path, `{"colour": "red"}`, "error", nil)).To(Equal(http.StatusBadRequest))
+		Expect(call("DELETE", path, "", "error", nil)).To(Equal(http.StatusMethodNotAllowed))
+	})
+
+	It("should spawn Entities of a Species", func() {
+		var ent Entity
+		body := `{"species": "sheep", "vec": {"x": 3, "y": 0, "z": 1}}`
+		Expect(call("POST", "/entities", body, "entity", &ent)).To(Equal(http.StatusCreated))
+		found, vec, ok := world.Find(ent.ID)
+		Expect(ok).To(BeTrue())
+		Expect(found.Species()).To(Equal("sheep"))
+		Expect(vec).To(Equal(ecoscript.Vec(3, 0, 1)))
+
+		Expect(call("POST", "/entities", body, "error", nil)).To(Equal(http.StatusConflict))
+		Expect(call("POST", "/entities", `{"species": "wolf", "vec": {"x": 0, "y": 0, "z": 0}}`, "error", nil)).
+			To(Equal(http.StatusNotFound))
+	})
+
+	It("should count the population", func() {
+		var sample ecoscript.Sample
+		Expect(call("GET", "/stats", "", "stats", &sample)).To(Equal(http.StatusOK))
+		Expect(sample.Population).To(Equal(map[string]int{"sheep": 1}))
+	})
+
+	It("should pause, resume and step", func() {
+		var w World
+		Expect(call("POST", "/step?n=3", "", "world", &w)).To(Equal(http.StatusOK))
+		Expect(w.Tick).To(Equal(3))
+		Expect(call("POST", "/resume", "", "world", &w)).To(Equal(http.StatusOK))
+		Expect(server.Paused()).To(BeFalse())
+		Expect(call("POST", "/pause", "", "world", &w)).To(Equal(http.StatusOK))
+		Expect(w.Paused).To(BeTrue())
+
+		Expect(call("POST", "/step?n=0", "", "error", nil)).To(Equal(http.StatusBadRequest))
+		Expect(call("GET", "/step", "", "error", nil)).To(Equal(http.StatusMethodNotAllowed))
+	})
+
+	It("should serve its schemas", func() {
+		var schemas map[string]json.RawMessage
+		resp, err := http.Get(ts.URL + "/schema")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(json.NewDecoder(resp.Body).Decode(&schemas)).To(Succeed())
+		resp.Body.Close()
+		Expect(schemas).To(HaveKey("entity"))
+
+		Expect(call("GET", "/schema/planet", "", "error", nil)).To(Equal(http.StatusNotFound))
+		var schema map[string]interface{}
+		resp, err = http.Get(ts.URL + "/schema/world")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(json.NewDecoder(resp.Body).Decode(&schema)).To(Succeed())
+		resp.Body.Close()
+		Expect(schema["title"]).To(Equal("World"))
+	})
+
+	It("should change its delay while running", func() {
+		server.SetDelay(time.Hour).SetPaused(false)
+		stop := make(chan struct{})
+		done := make(chan struct{})
+		go func() {
+			server.Run(stop)
+			close(done)
+		}()
+
+		server.SetDelay(time.Millisecond)
+		Expect(server.Delay()).To(Equal(time.Millisecond))
+		Eventually(func() int {
+			var ticks int
+			server.Do(func(w *ecoscript.World) { ticks = w.Clock().Ticks() })
+			return ticks
+		}).Should(BeNumerically(">", 0))
+		close(stop)
+		Eventually(done).Should(BeClosed())
+	})
+
+	It("should serve requests while the World ticks", func() {
+		server.SetDelay(time.Millisecond).SetPaused(false)
+		stop := make(chan struct{})
+		done := make(chan struct{})
+		go func() {
+			server.Run(stop)
+			close(done)
+		}()
+
+		var wg sync.WaitGroup
+		for i := 0; i < 4; i++ {
+			wg.Add(1)
+			go func() {
+				defer GinkgoRecover()
+				defer wg.Done()
+				for j := 0; j < 10; j++ {
+					Expect(call("GET", "/layers/ground", "", "layer", nil)).To(Equal(http.StatusOK))
+					Expect(call("GET", "/stats", "", "stats", nil)).To(Equal(http.StatusOK))
+				}
+			}()
+		}
+		wg.Wait()
+		Eventually(func() int {
+			var ticks int
+			server.Do(func(w *ecoscript.World) { ticks = w.Clock().Ticks() })
+			return ticks
+		}).Should(BeNumerically(">", 0))
+		close(stop)
+		Eventually(done).Should(BeClosed())
+	})
+})
@@ -0,0 +1,299 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"reflect"
+	"sort"
+
+	"github.com/dustinrohde/ecoscript"
+	"github.com/pkg/errors"
+)
+
+const (
+	// DefaultKeyframeInterval is the number of updates between keyframes in
+	// a stream, by default.
+	DefaultKeyframeInterval = 50
+
+	// streamBuffer is the number of updates a subscriber may fall behind by
+	// before it is sent a keyframe to catch up instead.
+	streamBuffer = 64
+)
+
+// View is what can be seen of a World: its state, the symbol shown in each
+// Cell, and every Entity by ID. A stream starts with a View as a keyframe,
+// and a client keeps its copy up to date by applying each Diff after it.
+type View struct {
+	World World `json:"world"`
+
+	// Symbols are the symbols shown in each Cell, by Z, Y and X.
+	Symbols [][][]string `json:"symbols"`
+
+	Entities map[ecoscript.EntityID]Entity `json:"entities"`
+}
+
+// Diff is what changed in a View since the last update.
+type Diff struct {
+	World World `json:"world"`
+
+	Spawned []Entity             `json:"spawned"`
+	Moved   []Move               `json:"moved"`
+	Died    []ecoscript.EntityID `json:"died"`
+
+	// Changed are Entities that changed in some other way, as they are now.
+	Changed []Entity `json:"changed"`
+
+	// Cells are the Cells that show a different symbol.
+	Cells []CellChange `json:"cells"`
+}
+
+// Move is an Entity that moved.
+type Move struct {
+	ID   ecoscript.EntityID `json:"id"`
+	From Vector             `json:"from"`
+	To   Vector             `json:"to"`
+}
+
+// CellChange is the symbol a Cell shows now.
+type CellChange struct {
+	Vector
+	Symbol string `json:"symbol"`
+}
+
+func newView(w *ecoscript.World, paused bool) *View {
+	empty := w.EmptyTile()
+	if empty == "" {
+		empty = " "
+	}
+	view := &View{
+		World:    newWorld(w, paused),
+		Symbols:  make([][][]string, w.Depth()),
+		Entities: make(map[ecoscript.EntityID]Entity),
+	}
+	for z := range view.Symbols {
+		view.Symbols[z] = make([][]string, w.Height())
+		for y := range view.Symbols[z] {
+			view.Symbols[z][y] = make([]string, w.Width())
+			for x := range view.Symbols[z][y] {
+				vec := ecoscript.Vec(x, y, z)
+				ents := w.Cell(vec).Entities()
+				if len(ents) == 0 {
+					view.Symbols[z][y][x] = empty
+					continue
+				}
+				view.Symbols[z][y][x] = ents[len(ents)-1].Display()
+				for _, ent := range ents {
+					view.Entities[ent.ID()] = newEntity(ent, vec)
+				}
+			}
+		}
+	}
+	return view
+}
+
+// Rows returns the symbols in a Layer of the View, a row per string.
+func (v *View) Rows(z int) []string {
+	rows := make([]string, len(v.Symbols[z]))
+	for y, row := range v.Symbols[z] {
+		for _, symbol := range row {
+			rows[y] += symbol
+		}
+	}
+	return rows
+}
+
+// diff returns what changed from one View to the next.
+func diff(prev, next *View) Diff {
+	d := Diff{
+		World:   next.World,
+		Spawned: make([]Entity, 0),
+		Moved:   make([]Move, 0),
+		Died:    make([]ecoscript.EntityID, 0),
+		Changed: make([]Entity, 0),
+		Cells:   make([]CellChange, 0),
+	}
+	for _, id := range sortedIDs(next.Entities) {
+		ent := next.Entities[id]
+		old, ok := prev.Entities[id]
+		if !ok {
+			d.Spawned = append(d.Spawned, ent)
+			continue
+		}
+		if old.Vec != ent.Vec {
+			d.Moved = append(d.Moved, Move{id, old.Vec, ent.Vec})
+			old.Vec = ent.Vec
+		}
+		if !reflect.DeepEqual(old, ent) {
+			d.Changed = append(d.Changed, ent)
+		}
+	}
+	for _, id := range sortedIDs(prev.Entities) {
+		if _, ok := next.Entities[id]; !ok {
+			d.Died = append(d.Died, id)
+		}
+	}
+	for z := range next.Symbols {
+		for y := range next.Symbols[z] {
+			for x, symbol := range next.Symbols[z][y] {
+				if prev.Symbols[z][y][x] != symbol {
+					d.Cells = append(d.Cells, CellChange{Vector{x, y, z}, symbol})
+				}
+			}
+		}
+	}
+	return d
+}
+
+// Apply updates the View with a Diff.
+func (v *View) Apply(d Diff) error {
+	v.World = d.World
+	for _, ent := range d.Spawned {
+		v.Entities[ent.ID] = ent
+	}
+	for _, move := range d.Moved {
+		ent, ok := v.Entities[move.ID]
+		if !ok {
+			return errors.Errorf("entity #%d moved, but isn't in view", move.ID)
+		}
+		ent.Vec = move.To
+		v.Entities[move.ID] = ent
+	}
+	for _, id := range d.Died {
+		delete(v.Entities, id)
+	}
+	for _, ent := range d.Changed {
+		v.Entities[ent.ID] = ent
+	}
+	for _, cell := range d.Cells {
+		if cell.Z >= len(v.Symbols) || cell.Y >= len(v.Symbols[cell.Z]) || cell.X >= len(v.Symbols[cell.Z][cell.Y]) {
+			return errors.Errorf("cell (%d,%d,%d) isn't in view", cell.X, cell.Y, cell.Z)
+		}
+		v.Symbols[cell.Z][cell.Y][cell.X] = cell.Symbol
+	}
+	return nil
+}
+
+func sortedIDs(entities map[ecoscript.EntityID]Entity) []ecoscript.EntityID {
+	ids := make([]ecoscript.EntityID, 0, len(entities))
+	for id := range entities {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+	return ids
+}
+
+// ---------------------------------------------------------------------
+// Streaming
+
+// update is an event of a stream: a keyframe or a diff, encoded.
+type update struct {
+	event string
+	data  []byte
+}
+
+type subscriber struct {
+	updates chan update
+
+	// behind is set when the subscriber missed an update, so that it is
+	// sent a keyframe next.
+	behind bool
+}
+
+// SetKeyframeInterval sets the number of updates between keyframes in the
+// Server's streams.
+func (s *Server) SetKeyframeInterval(n int) *Server {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if n < 1 {
+		n = 1
+	}
+	s.keyframes = n
+	return s
+}
+
+// publish sends what changed since the last update to every subscriber. It
+// must be called with the lock held, after anything that changes the World.
+func (s *Server) publish() {
+	if len(s.subscribers) == 0 {
+		s.view = nil
+		return
+	}
+	next := newView(s.world, s.paused)
+	s.updates++
+	keyframe := s.updates%s.keyframes == 0
+
+	var diffData, keyframeData []byte
+	for sub := range s.subscribers {
+		var u update
+		if keyframe || sub.behind {
+			if keyframeData == nil {
+				keyframeData = encode(next)
+			}
+			u = update{"keyframe", keyframeData}
+		} else {
+			if diffData == nil {
+				diffData = encode(diff(s.view, next))
+			}
+			u = update{"diff", diffData}
+		}
+		select {
+		case sub.updates <- u:
+			sub.behind = false
+		default:
+			sub.behind = true
+		}
+	}
+	s.view = next
+}
+
+func encode(v interface{}) []byte {
+	data, err := json.Marshal(v)
+	ecoscript.Guard(err)
+	return data
+}
+
+// handleStream streams updates of the World as server-sent events: a
+// keyframe of the whole View first, then a Diff after each change, with a
+// keyframe in place of every so many Diffs.
+func (s *Server) handleStream(w http.ResponseWriter, r *http.Request) {
+	if !allow(w, r, http.MethodGet) {
+		return
+	}
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		fail(w, http.StatusInternalServerError, "streaming is not supported")
+		return
+	}
+
+	sub := &subscriber{updates: make(chan update, streamBuffer)}
+	s.mu.Lock()
+	if s.view == nil {
+		s.view = newView(s.world, s.paused)
+	}
+	sub.updates <- update{"keyframe", encode(s.view)}
+	s.subscribers[sub] = true
+	s.mu.Unlock()
+	defer func() {
+		s.mu.Lock()
+		delete(s.subscribers, sub)
+		s.mu.Unlock()
+	}()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case u := <-sub.updates:
+			if _, err := fmt.Fprintf(w, "event: %s\ndata: %s\n\n", u.event, u.data); err != nil {
+				return
+			}
+			flusher.Flush()
+		}
+	}
+}
@@ -0,0 +1,143 @@
+package server_test
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+
+	"github.com/dustinrohde/ecoscript"
+	. "github.com/dustinrohde/ecoscript/server"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Stream", func() {
+	var (
+		world  *ecoscript.World
+		sheep  *ecoscript.Entity
+		server *Server
+		ts     *httptest.Server
+		mirror *Mirror
+	)
+
+	post := func(path, body string) {
+		resp, err := http.Post(ts.URL+path, "application/json", strings.NewReader(body))
+		Expect(err).NotTo(HaveOccurred())
+		resp.Body.Close()
+		Expect(resp.StatusCode).To(BeNumerically("<", 300))
+	}
+
+	BeforeEach(func() {
+		world = ecoscript.NewWorld(3, 2, []string{"ground"})
+		world.SetEmptyTile(".")
+		world.AddSpecies(&ecoscript.Species{Key: "sheep", Name: "sheep", Symbol: "&"})
+		sheep = world.Species("sheep").Spawn(world)
+		exec, ok := world.Spawn(sheep, ecoscript.Vec(0, 0, 0))
+		Expect(ok).To(BeTrue())
+		exec()
+
+		server = New(world).SetPaused(true)
+		ts = httptest.NewServer(server)
+		var err error
+		mirror, err = Follow(ts.URL)
+		Expect(err).NotTo(HaveOccurred())
+	})
+
+	AfterEach(func() {
+		mirror.Close()
+		ts.Close()
+	})
+
+	It("should start with a keyframe", func() {
+		Expect(mirror.View().Rows(0)).To(Equal([]string{"&..", "..."}))
+		Expect(mirror.View().Entities).To(HaveKey(sheep.ID()))
+	})
+
+	It("should stream moves, spawns, deaths and changes", func() {
+		server.Do(func(w *ecoscript.World) {
+			exec, ok := w.Move(sheep, ecoscript.Vec(0, 0, 0), ecoscript.Vec(1, 1, 0))
+			Expect(ok).To(BeTrue())
+			exec()
+		})
+		d, err := mirror.Next()
+		Expect(err).NotTo(HaveOccurred())
+		Expect(d.Moved).To(Equal([]Move{{sheep.ID(), Vector{0, 0, 0}, Vector{1, 1, 0}}}))
+		Expect(d.Cells).To(HaveLen(2))
+		Expect(mirror.View().Rows(0)).To(Equal([]string{"...", ".&."}))
+
+		post("/entities", `{"species": "sheep", "vec": {"x": 2, "y": 0, "z": 0}}`)
+		d, err = mirror.Next()
+		Expect(err).NotTo(HaveOccurred())
+		Expect(d.Spawned).To(HaveLen(1))
+		Expect(mirror.View().Rows(0)).To(Equal([]string{"..&", ".&."}))
+
+		req, _ := http.NewRequest("PATCH", fmt.Sprintf("%s/entities/%d", ts.URL, sheep.ID()), strings.NewReader(`{"energy": 7}`))
+		resp, err := http.DefaultClient.Do(req)
+		Expect(err).NotTo(HaveOccurred())
+		resp.Body.Close()
+		d, err = mirror.Next()
+		Expect(err).NotTo(HaveOccurred())
+		Expect(d.Changed).To(HaveLen(1))
+		Expect(mirror.View().Entities[sheep.ID()].Attributes.Energy).To(Equal(7))
+
+		server.Do(func(w *ecoscript.World) {
+			exec, ok := w.Remove(sheep, ecoscript.Vec(1, 1, 0))
+			Expect(ok).To(BeTrue())
+			exec()
+		})
+		d, err = mirror.Next()
+		Expect(err).NotTo(HaveOccurred())
+		Expect(d.Died).To(Equal([]ecoscript.EntityID{sheep.ID()}))
+		Expect(mirror.View().Entities).NotTo(HaveKey(sheep.ID()))
+		Expect(mirror.View().Rows(0)).To(Equal([]string{"..&", "..."}))
+	})
+
+	It("should send keyframes periodically", func() {
+		server.SetKeyframeInterval(2)
+		post("/step?n=2", "")
+		d, err := mirror.Next()
+		Expect(err).NotTo(HaveOccurred())
+		Expect(d).NotTo(BeNil())
+		Expect(d.World.Tick).To(Equal(1))
+		d, err = mirror.Next()
+		Expect(err).NotTo(HaveOccurred())
+		Expect(d).To(BeNil())
+		Expect(mirror.View().World.Tick).To(Equal(2))
+	})
+
+	It("should mirror a running World", func() {
+		mirror.Close()
+		ts.Close()
+
+		mapfile, err := ecoscript.ParseMapfile("../examples/Mapfile")
+		Expect(err).NotTo(HaveOccurred())
+		world, err = mapfile.ToWorld()
+		Expect(err).NotTo(HaveOccurred())
+		world.Seed(7)
+		server = New(world).SetPaused(true).SetKeyframeInterval(1000)
+		ts = httptest.NewServer(server)
+		mirror, err = Follow(ts.URL)
+		Expect(err).NotTo(HaveOccurred())
+
+		post("/step?n=30", "")
+		for i := 0; i < 30; i++ {
+			_, err := mirror.Next()
+			Expect(err).NotTo(HaveOccurred())
+		}
+		Expect(mirror.View().World.Tick).To(Equal(30))
+
+		var entities int
+		server.Do(func(w *ecoscript.World) {
+			for z := 0; z < w.Depth(); z++ {
+				Expect(mirror.View().Rows(z)).To(Equal(strings.Split(strings.TrimSuffix(
+					w.Layer(z).DisplayWith(ecoscript.DisplayOptions{EmptyTile: w.EmptyTile()}), "\n"), "\n")))
+			}
+			for _, n := range ecoscript.Census(w).Population {
+				entities += n
+			}
+		})
+		Expect(mirror.View().Entities).To(HaveLen(entities))
+	})
+})
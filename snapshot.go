@@ -0,0 +1,389 @@
+package ecoscript
+
+import (
+	"encoding/json"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// Snapshot is the state of a World between ticks, in a form that can be
+// encoded as JSON and restored later.
+//
+// Activities in progress are saved, but not what they'll do when they're
+// done, which their Behaviors decide when they start them. A restored
+// Entity's Behavior plans its activity in progress again, against the
+// restored World, so the activity may turn out differently than it would
+// have. Only a Snapshot with no activities in progress is sure to play out
+// as the World would have; see Resumable. Entities that weren't spawned
+// from a Species choose their Behaviors at random.
+//
+// The schedule of a SparseScheduler or DiscreteScheduler is saved too, and
+// restored if the World has one when it's restored.
+type Snapshot struct {
+	Width  int      `json:"width"`
+	Height int      `json:"height"`
+	Seed   int64    `json:"seed"`
+	Draws  uint64   `json:"draws"`
+	NextID EntityID `json:"next_id"`
+	Ticks  int      `json:"ticks"`
+
+	EmptyTile     string `json:"empty_tile,omitempty"`
+	DisplayLegend bool   `json:"display_legend,omitempty"`
+
+	Clock    *Clock           `json:"clock"`
+	Layers   []LayerSnapshot  `json:"layers"`
+	Weather  []WeatherState   `json:"weather"`
+	Fields   []FieldSnapshot  `json:"fields"`
+	Species  []*Species       `json:"species"`
+	Entities []EntitySnapshot `json:"entities"`
+	Schedule []ScheduleEntry  `json:"schedule,omitempty"`
+}
+
+// Resumable returns true if no activity was in progress when the Snapshot
+// was taken, so that a restored World plays out exactly as the original.
+func (snap *Snapshot) Resumable() bool {
+	for i := range snap.Entities {
+		if snap.Entities[i].Activity != nil {
+			return false
+		}
+	}
+	return true
+}
+
+type LayerSnapshot struct {
+	Name        string    `json:"name"`
+	Above       int       `json:"above"`
+	Below       int       `json:"below"`
+	BlocksLight bool      `json:"blocks_light"`
+	Permeable   bool      `json:"permeable"`
+	DrawOrder   int       `json:"draw_order,omitempty"`
+	Opaque      bool      `json:"opaque,omitempty"`
+	Terrain     []Terrain `json:"terrain"`
+}
+
+type WeatherState struct {
+	Weather   *Weather `json:"weather"`
+	Remaining int      `json:"remaining"`
+	Flooded   []Vector `json:"flooded"`
+}
+
+type FieldSnapshot struct {
+	Name      string      `json:"name"`
+	Diffusion float64     `json:"diffusion"`
+	Decay     float64     `json:"decay"`
+	Values    [][]float64 `json:"values"`
+	Color     Color       `json:"color,omitempty"`
+}
+
+// EntitySnapshot holds an Entity and where it is. Behaviors are keyed like
+// Entity.Behaviors, and Behavior is the key of the one the Entity is doing
+// or did last.
+type EntitySnapshot struct {
+	ID        EntityID                   `json:"id"`
+	Species   string                     `json:"species"`
+	Name      string                     `json:"name"`
+	Symbol    string                     `json:"symbol"`
+	Vec       Vector                     `json:"vec"`
+	Attrs     Attributes                 `json:"attrs"`
+	Traits    []Trait                    `json:"traits"`
+	Behaviors map[string]json.RawMessage `json:"behaviors"`
+	Behavior  string                     `json:"behavior,omitempty"`
+	Activity  *ActivitySnapshot          `json:"activity,omitempty"`
+	Rest      int                        `json:"rest,omitempty"`
+	Style     Style                      `json:"style"`
+}
+
+// ActivitySnapshot is an activity in progress: how many ticks it has taken
+// and how many it needs.
+type ActivitySnapshot struct {
+	Ticks  int `json:"ticks"`
+	Needed int `json:"needed"`
+}
+
+// resumable returns true if no Entity in the World has an activity in
+// progress, like Snapshot.Resumable.
+func (w *World) resumable() bool {
+	for _, layer := range w.layers {
+		for _, cell := range layer.cells {
+			for _, ent := range cell.Entities() {
+				if ent.activity.InProgress() {
+					return false
+				}
+			}
+		}
+	}
+	return true
+}
+
+// Snapshot captures the state of the World.
+func (w *World) Snapshot() (*Snapshot, error) {
+	clock := *w.clock
+	snap := &Snapshot{
+		Width:    w.width,
+		Height:   w.height,
+		Seed:     w.src.seed,
+		Draws:    w.src.draws,
+		NextID:   w.nextID,
+		Ticks:    w.clock.Ticks(),
+		Clock:    &clock,
+		Layers:   make([]LayerSnapshot, w.depth),
+		Weather:  make([]WeatherState, len(w.weather)),
+		Fields:   make([]FieldSnapshot, len(w.fields)),
+		Species:  make([]*Species, 0, len(w.species)),
+		Entities: make([]EntitySnapshot, 0),
+
+		EmptyTile:     w.emptyTile,
+		DisplayLegend: w.displayLegend,
+	}
+
+	for z, layer := range w.layers {
+		terrain := make([]Terrain, len(layer.cells))
+		for i, cell := range layer.cells {
+			terrain[i] = cell.terrain
+		}
+		snap.Layers[z] = LayerSnapshot{
+			Name:        layer.name,
+			Above:       layer.above,
+			Below:       layer.below,
+			BlocksLight: layer.blocksLight,
+			Permeable:   layer.permeable,
+			DrawOrder:   layer.drawOrder,
+			Opaque:      layer.opaque,
+			Terrain:     terrain,
+		}
+
+		for i, cell := range layer.cells {
+			vec := Vec(i%w.width, i/w.width, z)
+			for _, ent := range cell.Entities() {
+				entSnap, err := snapshotEntity(ent, vec)
+				if err != nil {
+					return nil, err
+				}
+				snap.Entities = append(snap.Entities, entSnap)
+			}
+		}
+	}
+
+	for i, wx := range w.weather {
+		flooded := make([]Vector, 0, len(wx.flooded))
+		for vec := range wx.flooded {
+			flooded = append(flooded, vec)
+		}
+		sortVectors(flooded)
+		snap.Weather[i] = WeatherState{wx, wx.remaining, flooded}
+	}
+
+	for i, field := range w.fields {
+		values := make([][]float64, len(field.values))
+		for z := range values {
+			values[z] = append([]float64(nil), field.values[z]...)
+		}
+		snap.Fields[i] = FieldSnapshot{field.Name, field.Diffusion, field.Decay, values, field.Color}
+	}
+
+	for _, key := range w.SpeciesKeys() {
+		snap.Species = append(snap.Species, w.species[key])
+	}
+	if t, ok := w.scheduler.(tracker); ok {
+		snap.Schedule = t.schedule()
+	}
+	return snap, nil
+}
+
+func snapshotEntity(ent *Entity, vec Vector) (EntitySnapshot, error) {
+	behaviors := make(map[string]json.RawMessage, len(ent.Behaviors))
+	for key, behavior := range ent.Behaviors {
+		data, err := json.Marshal(behavior)
+		if err != nil {
+			return EntitySnapshot{}, errors.Wrapf(err, "entity #%d: behavior '%s'", ent.ID(), key)
+		}
+		behaviors[key] = data
+	}
+	var activity *ActivitySnapshot
+	if ent.activity.InProgress() {
+		ticks, needed := ent.activity.Progress()
+		activity = &ActivitySnapshot{ticks, needed}
+	}
+	return EntitySnapshot{
+		ID:        ent.ID(),
+		Species:   ent.Species(),
+		Name:      ent.Name,
+		Symbol:    ent.Symbol,
+		Vec:       vec,
+		Attrs:     *ent.Attrs,
+		Traits:    append([]Trait(nil), ent.Traits...),
+		Behaviors: behaviors,
+		Behavior:  ent.behavior,
+		Activity:  activity,
+		Rest:      ent.rest,
+		Style:     ent.Style,
+	}, nil
+}
+
+// Restore replaces the state of the World with a Snapshot. Subscribers to
+// the World's Events are kept.
+func (w *World) Restore(snap *Snapshot) error {
+	// Round trip through JSON, so that nothing is shared with the Snapshot.
+	data, err := json.Marshal(snap)
+	if err != nil {
+		return errors.Wrap(err, "encoding snapshot")
+	}
+	snap = new(Snapshot)
+	if err := json.Unmarshal(data, snap); err != nil {
+		return errors.Wrap(err, "decoding snapshot")
+	}
+
+	w.width = snap.Width
+	w.height = snap.Height
+	w.depth = len(snap.Layers)
+	w.layers = make([]*Layer, w.depth)
+	w.src.restore(snap.Seed, snap.Draws)
+	w.nextID = snap.NextID
+	w.emptyTile = snap.EmptyTile
+	w.displayLegend = snap.DisplayLegend
+
+	w.clock = snap.Clock
+	if w.clock == nil {
+		w.clock = NewClock()
+	}
+	w.clock.ticks = snap.Ticks
+
+	for z, layerSnap := range snap.Layers {
+		layer := w.addLayer(z, layerSnap.Name)
+		layer.above = layerSnap.Above
+		layer.below = layerSnap.Below
+		layer.blocksLight = layerSnap.BlocksLight
+		layer.permeable = layerSnap.Permeable
+		layer.drawOrder = layerSnap.DrawOrder
+		layer.opaque = layerSnap.Opaque
+		if len(layerSnap.Terrain) != len(layer.cells) {
+			return errors.Errorf("layer '%s' has %d cells of terrain, want %d",
+				layerSnap.Name, len(layerSnap.Terrain), len(layer.cells))
+		}
+		for i, cell := range layer.cells {
+			cell.terrain = layerSnap.Terrain[i]
+		}
+	}
+
+	w.weather = make([]*Weather, len(snap.Weather))
+	for i, state := range snap.Weather {
+		wx := state.Weather
+		wx.remaining = state.Remaining
+		if wx.Active() {
+			wx.flooded = make(map[Vector]bool, len(state.Flooded))
+			for _, vec := range state.Flooded {
+				wx.flooded[vec] = true
+			}
+		}
+		w.weather[i] = wx
+	}
+
+	w.fields = make([]*Field, len(snap.Fields))
+	for i, fieldSnap := range snap.Fields {
+		field := newField(fieldSnap.Name, fieldSnap.Diffusion, fieldSnap.Decay, w.width, w.height, w.depth)
+		field.Color = fieldSnap.Color
+		for z := range field.values {
+			if z < len(fieldSnap.Values) {
+				copy(field.values[z], fieldSnap.Values[z])
+			}
+		}
+		w.fields[i] = field
+	}
+
+	w.species = make(map[string]*Species)
+	w.AddSpecies(snap.Species...)
+
+	restored := make([]*Entity, len(snap.Entities))
+	for i := range snap.Entities {
+		entSnap := &snap.Entities[i]
+		ent, err := restoreEntity(entSnap, speciesStrategy(w.Species(entSnap.Species)))
+		if err != nil {
+			return err
+		}
+		vec := snap.Entities[i].Vec
+		if !w.InBounds(vec) {
+			return errors.Errorf("entity #%d is out of bounds", ent.ID())
+		}
+		exec, ok := w.Add(ent, vec)
+		if !ok {
+			return errors.Errorf("entity #%d doesn't fit at (%d,%d,%d)", ent.ID(), vec.X, vec.Y, vec.Z)
+		}
+		exec()
+		restored[i] = ent
+	}
+
+	// Plan the activities in progress again, once every Entity is back. The
+	// random source is put back afterwards, so that the rest of the run
+	// draws what it would have.
+	for i, ent := range restored {
+		activity := snap.Entities[i].Activity
+		if activity == nil {
+			continue
+		}
+		behavior, ok := ent.Behaviors[ent.behavior]
+		if !ok {
+			return errors.Errorf("entity #%d: unknown behavior '%s' in progress", ent.ID(), ent.behavior)
+		}
+		_, exec := behavior.Execute(w, ent, snap.Entities[i].Vec)
+		ent.activity.resume(activity.Ticks, activity.Needed, exec)
+	}
+	if w.src.draws != snap.Draws {
+		w.src.restore(snap.Seed, snap.Draws)
+	}
+	if t, ok := w.scheduler.(tracker); ok {
+		t.reschedule(w, snap.Schedule)
+	}
+	return nil
+}
+
+func restoreEntity(snap *EntitySnapshot, newStrategy func(Behaviors) Strategy) (*Entity, error) {
+	attrs := snap.Attrs
+	ent := newEntity(snap.ID, snap.Name, snap.Symbol).
+		AddAttributes(&attrs).
+		AddTraits(snap.Traits...)
+	ent.species = snap.Species
+	ent.behavior = snap.Behavior
+	ent.rest = snap.Rest
+	ent.Style = snap.Style
+
+	for key, data := range snap.Behaviors {
+		fn, ok := behaviorType(key)
+		if !ok {
+			return nil, errors.Errorf("entity #%d: unknown behavior '%s'", snap.ID, key)
+		}
+		behavior := fn()
+		if err := json.Unmarshal(data, behavior); err != nil {
+			return nil, errors.Wrapf(err, "entity #%d: behavior '%s'", snap.ID, key)
+		}
+		ent.Behaviors[key] = behavior
+	}
+	return ent.AddStrategy(newStrategy(ent.Behaviors)), nil
+}
+
+// behaviorType finds the constructor of a registered Behavior by its key in
+// Entity.Behaviors, which starts with its type name.
+func behaviorType(key string) (func() Behavior, bool) {
+	name := strings.SplitN(key, ":", 2)[0]
+	for _, fn := range behaviorKinds {
+		if reflect.TypeOf(fn()).Elem().Name() == name {
+			return fn, true
+		}
+	}
+	return nil, false
+}
+
+func sortVectors(vectors []Vector) {
+	sort.Slice(vectors, func(i, j int) bool {
+		a, b := vectors[i], vectors[j]
+		if a.Z != b.Z {
+			return a.Z < b.Z
+		}
+		if a.Y != b.Y {
+			return a.Y < b.Y
+		}
+		return a.X < b.X
+	})
+}
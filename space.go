@@ -15,6 +15,9 @@ type Space interface {
 	// Cell returns the Cell at the given vector.
 	Cell(vec Vector) *Cell
 
+	// Rand returns the random source to draw from.
+	Rand() *rand.Rand
+
 	// InBounds returns true if the given Vector is in bounds.
 	InBounds(vec Vector) bool
 
@@ -54,11 +57,15 @@ type Space interface {
 }
 
 func SpaceInBounds(s Space, vec Vector) bool {
-	return vec.Flatten(s.Height()) < s.Width()*s.Height()
+	return vec.X >= 0 && vec.X < s.Width() && vec.Y >= 0 && vec.Y < s.Height()
 }
 
 func SpaceWalkable(s Space, vec Vector) bool {
-	return s.InBounds(vec) && !s.Cell(vec).Occupied()
+	if !s.InBounds(vec) {
+		return false
+	}
+	cell := s.Cell(vec)
+	return !cell.Terrain().Impassable && !cell.Occupied()
 }
 
 func SpaceView(s Space, origin Vector, radius int) []Vector {
@@ -67,7 +74,7 @@ func SpaceView(s Space, origin Vector, radius int) []Vector {
 }
 
 func SpaceViewR(s Space, origin Vector, radius int) []Vector {
-	vectors := origin.RadiusR(radius)
+	vectors := origin.RadiusR(s.Rand(), radius)
 	return VecFilter(vectors, s.InBounds)
 }
 
@@ -77,13 +84,16 @@ func SpaceViewWalkable(s Space, origin Vector, radius int) []Vector {
 }
 
 func SpaceViewWalkableR(s Space, origin Vector, radius int) []Vector {
-	vectors := origin.RadiusR(radius)
+	vectors := origin.RadiusR(s.Rand(), radius)
 	return VecFilter(vectors, s.Walkable)
 }
 
 func SpaceRandWalkable(s Space, origin Vector, radius int) Vector {
 	vectors := s.ViewWalkable(origin, radius)
-	index := rand.Intn(len(vectors))
+	if len(vectors) == 0 {
+		return origin
+	}
+	index := s.Rand().Intn(len(vectors))
 	return vectors[index]
 }
 
@@ -105,7 +115,14 @@ func SpaceMove(s Space, entity *Entity, src Vector, dst Vector) (exec action, ok
 
 	ok = okAdd && okRm
 	if ok {
-		exec = chain(execAdd, execRm)
+		// Another action may have claimed the destination in the meantime,
+		// in which case the Entity stays where it is.
+		exec = func() {
+			if !newCell.Accepts(entity) || !oldCell.Exists(entity) {
+				return
+			}
+			chain(execRm, execAdd)()
+		}
 	}
 	return
 }
@@ -0,0 +1,97 @@
+package ecoscript
+
+import (
+	"math/rand"
+	"sort"
+)
+
+type (
+	// Species is a template from which Entities are spawned, as declared in
+	// the ``entities`` section of a Mapfile.
+	Species struct {
+		Key string
+
+		Name      string     `mapstructure:"name"`
+		Symbol    string     `mapstructure:"symbol"`
+		Attrs     Attributes `mapstructure:"attributes"`
+		Traits    []Trait    `mapstructure:"traits"`
+		Abilities []*Ability `mapstructure:"abilities"`
+		Style     Style      `mapstructure:"style"`
+
+		// Strategy names the registered Strategy that Entities of the
+		// Species choose their Behaviors with. Empty means "random".
+		Strategy string `mapstructure:"strategy"`
+	}
+
+	// Ability names a registered Behavior and the properties to define it
+	// with.
+	Ability struct {
+		Name       string     `mapstructure:"name"`
+		Properties Properties `mapstructure:"properties"`
+	}
+)
+
+// Spawn creates a new Entity of the Species. Each Entity gets its own
+// Attributes and Behaviors, so no state is shared between them, and an ID
+// allocated by the World it will live in.
+func (s *Species) Spawn(w *World) *Entity {
+	attrs := s.Attrs
+
+	behaviors := make([]Behavior, 0, len(s.Abilities))
+	for i := range s.Abilities {
+		ability := s.Abilities[i]
+		behavior, ok := NewBehavior(ability.Name, ability.Properties)
+		if !ok {
+			continue
+		}
+		behaviors = append(behaviors, behavior)
+	}
+
+	ent := newEntity(w.nextEntityID(), s.Name, s.Symbol).
+		AddAttributes(&attrs).
+		AddTraits(s.Traits...).
+		AddBehaviors(behaviors...)
+	ent.species = s.Key
+	ent.Style = s.Style
+	return ent.AddStrategy(speciesStrategy(s)(ent.Behaviors))
+}
+
+// strategyKinds maps the names of strategies in a Mapfile to functions that
+// make a Strategy for the given Behaviors of an Entity.
+var strategyKinds = map[string]func(Behaviors) Strategy{
+	"random": RandomStrategy,
+}
+
+// RegisterStrategy makes a Strategy available to Mapfiles under the given
+// name.
+func RegisterStrategy(name string, fn func(Behaviors) Strategy) {
+	strategyKinds[name] = fn
+}
+
+// speciesStrategy returns the function that makes the Strategy of a Species,
+// which may be nil.
+func speciesStrategy(s *Species) func(Behaviors) Strategy {
+	if s != nil {
+		if fn, ok := strategyKinds[s.Strategy]; ok {
+			return fn
+		}
+	}
+	return RandomStrategy
+}
+
+// RandomStrategy returns a Strategy that chooses uniformly between the given
+// Behaviors.
+func RandomStrategy(behaviors Behaviors) Strategy {
+	keys := make([]string, 0, len(behaviors))
+	for key := range behaviors {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	return func(rng *rand.Rand) string {
+		if len(keys) == 0 {
+			return ""
+		}
+		return keys[rng.Intn(len(keys))]
+	}
+}
@@ -0,0 +1,260 @@
+package ecoscript
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"io"
+	"sort"
+	"strconv"
+)
+
+// Sample is a census of a World, taken after a tick.
+type Sample struct {
+	Tick int `json:"tick"`
+
+	// Population counts living Entities by Species and by Trait.
+	Population map[string]int `json:"population"`
+	Traits     map[string]int `json:"traits"`
+
+	TotalEnergy int     `json:"total_energy"`
+	MeanEnergy  float64 `json:"mean_energy"`
+	Biomass     int     `json:"biomass"`
+
+	// Births, Deaths and Causes of death count what happened since the
+	// previous Sample.
+	Births int            `json:"births"`
+	Deaths int            `json:"deaths"`
+	Causes map[string]int `json:"causes"`
+}
+
+// Census counts the living Entities of a World. Births and deaths are left
+// at zero.
+func Census(w *World) Sample {
+	sample := Sample{
+		Tick:       w.Clock().Ticks(),
+		Population: make(map[string]int),
+		Traits:     make(map[string]int),
+		Causes:     make(map[string]int),
+	}
+
+	count := 0
+	for z := 0; z < w.Depth(); z++ {
+		for _, cell := range w.Layer(z).Cells() {
+			for _, ent := range cell.Entities() {
+				if !ent.Alive() {
+					continue
+				}
+				sample.Population[speciesOf(ent)]++
+				for _, trait := range ent.Traits {
+					sample.Traits[string(trait)]++
+				}
+				sample.TotalEnergy += ent.Attrs.Energy
+				sample.Biomass += ent.Biomass()
+				count++
+			}
+		}
+	}
+	if count > 0 {
+		sample.MeanEnergy = float64(sample.TotalEnergy) / float64(count)
+	}
+	return sample
+}
+
+// speciesOf returns the Species key of an Entity, or its name if it wasn't
+// spawned from a Species.
+func speciesOf(ent *Entity) string {
+	if ent.Species() != "" {
+		return ent.Species()
+	}
+	return ent.Name
+}
+
+// Collector records a Sample of a World every Interval ticks, keeping the
+// most recent ones in a ring buffer of fixed capacity.
+type Collector struct {
+	Interval int
+
+	samples []Sample
+	start   int
+	count   int
+
+	births      int
+	deaths      int
+	causes      map[string]int
+	unsubscribe func()
+}
+
+// NewCollector creates a Collector that listens to the World's births and
+// deaths. Call Collect after each tick, and Close when done.
+func NewCollector(w *World, interval, capacity int) *Collector {
+	if interval < 1 {
+		interval = 1
+	}
+	if capacity < 1 {
+		capacity = 1
+	}
+	c := &Collector{
+		Interval: interval,
+		samples:  make([]Sample, capacity),
+		causes:   make(map[string]int),
+	}
+
+	filter := Filter{Types: []EventType{EventSpawned, EventDied}}
+	c.unsubscribe = w.Events().Subscribe(filter, func(ev Event) {
+		if ev.Type == EventSpawned {
+			c.births++
+		} else {
+			c.deaths++
+			c.causes[ev.Detail]++
+		}
+	})
+	return c
+}
+
+// Close stops listening to the World.
+func (c *Collector) Close() {
+	c.unsubscribe()
+}
+
+// Collect takes a Sample of the World if one is due. It returns the Sample
+// and true if it did.
+func (c *Collector) Collect(w *World) (sample Sample, ok bool) {
+	if w.Clock().Ticks()%c.Interval != 0 {
+		return
+	}
+
+	sample = Census(w)
+	sample.Births = c.births
+	sample.Deaths = c.deaths
+	for cause, n := range c.causes {
+		sample.Causes[cause] = n
+	}
+	c.births, c.deaths = 0, 0
+	c.causes = make(map[string]int)
+
+	c.push(sample)
+	return sample, true
+}
+
+func (c *Collector) push(sample Sample) {
+	capacity := len(c.samples)
+	if c.count < capacity {
+		c.samples[(c.start+c.count)%capacity] = sample
+		c.count++
+		return
+	}
+	c.samples[c.start] = sample
+	c.start = (c.start + 1) % capacity
+}
+
+// Len returns the number of Samples held.
+func (c *Collector) Len() int {
+	return c.count
+}
+
+// Samples returns the Samples held, oldest first.
+func (c *Collector) Samples() []Sample {
+	samples := make([]Sample, c.count)
+	for i := range samples {
+		samples[i] = c.samples[(c.start+i)%len(c.samples)]
+	}
+	return samples
+}
+
+// Since returns the Samples taken on or after the given tick, oldest first.
+func (c *Collector) Since(tick int) []Sample {
+	samples := c.Samples()
+	i := sort.Search(len(samples), func(i int) bool {
+		return samples[i].Tick >= tick
+	})
+	return samples[i:]
+}
+
+// Latest returns the most recent Sample, if any.
+func (c *Collector) Latest() (Sample, bool) {
+	if c.count == 0 {
+		return Sample{}, false
+	}
+	return c.samples[(c.start+c.count-1)%len(c.samples)], true
+}
+
+// WriteJSONLines writes each Sample as a JSON object on its own line.
+func WriteJSONLines(w io.Writer, samples []Sample) error {
+	enc := json.NewEncoder(w)
+	for i := range samples {
+		if err := enc.Encode(samples[i]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// WriteCSV writes the Samples as CSV with a header row. Species, traits and
+// causes of death each get a column, prefixed with "population:",
+// "trait:" and "died:", for every key that occurs in any Sample.
+func WriteCSV(w io.Writer, samples []Sample) error {
+	species := make(map[string]bool)
+	traits := make(map[string]bool)
+	causes := make(map[string]bool)
+	for _, sample := range samples {
+		for key := range sample.Population {
+			species[key] = true
+		}
+		for key := range sample.Traits {
+			traits[key] = true
+		}
+		for key := range sample.Causes {
+			causes[key] = true
+		}
+	}
+	speciesKeys, traitKeys, causeKeys := sortedKeys(species), sortedKeys(traits), sortedKeys(causes)
+
+	header := []string{"tick", "total_energy", "mean_energy", "biomass", "births", "deaths"}
+	for _, key := range speciesKeys {
+		header = append(header, "population:"+key)
+	}
+	for _, key := range traitKeys {
+		header = append(header, "trait:"+key)
+	}
+	for _, key := range causeKeys {
+		header = append(header, "died:"+key)
+	}
+
+	out := csv.NewWriter(w)
+	if err := out.Write(header); err != nil {
+		return err
+	}
+	for _, sample := range samples {
+		row := []string{
+			strconv.Itoa(sample.Tick),
+			strconv.Itoa(sample.TotalEnergy),
+			strconv.FormatFloat(sample.MeanEnergy, 'f', 3, 64),
+			strconv.Itoa(sample.Biomass),
+			strconv.Itoa(sample.Births),
+			strconv.Itoa(sample.Deaths),
+		}
+		for _, key := range speciesKeys {
+			row = append(row, strconv.Itoa(sample.Population[key]))
+		}
+		for _, key := range traitKeys {
+			row = append(row, strconv.Itoa(sample.Traits[key]))
+		}
+		for _, key := range causeKeys {
+			row = append(row, strconv.Itoa(sample.Causes[key]))
+		}
+		if err := out.Write(row); err != nil {
+			return err
+		}
+	}
+	out.Flush()
+	return out.Error()
+}
+
+func sortedKeys(set map[string]bool) []string {
+	keys := make([]string, 0, len(set))
+	for key := range set {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
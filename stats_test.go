@@ -0,0 +1,98 @@
+package ecoscript_test
+
+import (
+	"bytes"
+	"strings"
+
+	. "github.com/dustinrohde/ecoscript"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Collector", func() {
+	var (
+		world     *World
+		collector *Collector
+	)
+
+	spawn := func(name string, energy int, vec Vector) *Entity {
+		ent := NewEntity(name, "e").
+			AddAttributes(&Attributes{Walkable: true, Energy: energy, Size: 1, Mass: 2}).
+			AddTraits("animal")
+		exec, ok := world.Spawn(ent, vec)
+		Expect(ok).To(BeTrue())
+		exec()
+		return ent
+	}
+
+	BeforeEach(func() {
+		world = NewWorld(3, 3, []string{"ground"})
+		collector = NewCollector(world, 2, 3)
+	})
+
+	AfterEach(func() {
+		collector.Close()
+	})
+
+	It("should sample every interval", func() {
+		spawn("sheep", 10, Vec(0, 0, 0))
+		spawn("sheep", 20, Vec(1, 0, 0))
+		dead := spawn("wolf", 5, Vec(2, 0, 0))
+		dead.EndLife()
+
+		for i := 0; i < 4; i++ {
+			world.Tick()
+			collector.Collect(world)
+		}
+		Expect(collector.Len()).To(Equal(2))
+
+		samples := collector.Samples()
+		Expect(samples[0].Tick).To(Equal(2))
+		Expect(samples[0].Population).To(Equal(map[string]int{"sheep": 2}))
+		Expect(samples[0].Traits["animal"]).To(Equal(2))
+		Expect(samples[0].TotalEnergy).To(Equal(30))
+		Expect(samples[0].MeanEnergy).To(BeNumerically("~", 15))
+		Expect(samples[0].Biomass).To(Equal(4))
+		Expect(samples[0].Births).To(Equal(3))
+		Expect(samples[0].Deaths).To(Equal(1))
+		Expect(samples[0].Causes["starved"]).To(Equal(1))
+
+		Expect(samples[1].Tick).To(Equal(4))
+		Expect(samples[1].Births).To(Equal(0))
+	})
+
+	It("should keep only the most recent Samples", func() {
+		for i := 0; i < 10; i++ {
+			world.Tick()
+			collector.Collect(world)
+		}
+		samples := collector.Samples()
+		Expect(samples).To(HaveLen(3))
+		Expect(samples[0].Tick).To(Equal(6))
+		Expect(samples[2].Tick).To(Equal(10))
+		Expect(collector.Since(8)).To(HaveLen(2))
+
+		latest, ok := collector.Latest()
+		Expect(ok).To(BeTrue())
+		Expect(latest.Tick).To(Equal(10))
+	})
+
+	It("should export CSV and JSON Lines", func() {
+		spawn("sheep", 10, Vec(0, 0, 0))
+		world.Tick()
+		world.Tick()
+		collector.Collect(world)
+
+		var buf bytes.Buffer
+		Expect(WriteCSV(&buf, collector.Samples())).To(Succeed())
+		lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+		Expect(lines).To(HaveLen(2))
+		Expect(lines[0]).To(Equal("tick,total_energy,mean_energy,biomass,births,deaths,population:sheep,trait:animal"))
+		Expect(lines[1]).To(Equal("2,10,10.000,2,1,0,1,1"))
+
+		buf.Reset()
+		Expect(WriteJSONLines(&buf, collector.Samples())).To(Succeed())
+		Expect(buf.String()).To(HavePrefix(`{"tick":2,"population":{"sheep":1}`))
+	})
+})
@@ -0,0 +1,290 @@
+package ecoscript
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// StopCondition decides when a headless run should stop. It is checked
+// against the World and a census of it after every tick. Conditions may
+// keep state between checks, so each belongs to a single run.
+//
+// Conditions are written as strings:
+//
+//   - "METRIC OP VALUE" compares a metric with a number. Metrics are tick,
+//     energy (total), biomass, population (total) and population:SPECIES.
+//     OP is one of <, <=, >, >= and ==.
+//   - "extinct: SPECIES" is met when no Entity of the Species is alive.
+//   - "stable: K" is met when no Species has grown or shrunk for K ticks.
+type StopCondition interface {
+	Met(w *World, sample Sample) bool
+	String() string
+}
+
+// ParseStopCondition parses a StopCondition from a string.
+func ParseStopCondition(s string) (StopCondition, error) {
+	s = strings.TrimSpace(s)
+
+	if i := strings.Index(s, ":"); i != -1 {
+		key, value := strings.TrimSpace(s[:i]), strings.TrimSpace(s[i+1:])
+		switch key {
+		case "extinct":
+			if value == "" {
+				return nil, errors.Errorf("stop condition '%s' names no species", s)
+			}
+			return &compareCondition{"population:" + value, "==", 0, s}, nil
+		case "stable":
+			ticks, err := strconv.Atoi(value)
+			if err != nil || ticks < 1 {
+				return nil, errors.Errorf("stop condition '%s' needs a positive number of ticks", s)
+			}
+			return &stableCondition{ticks: ticks}, nil
+		}
+	}
+
+	for _, op := range []string{"<=", ">=", "==", "<", ">"} {
+		i := strings.Index(s, op)
+		if i == -1 {
+			continue
+		}
+		metric := strings.Join(strings.Fields(s[:i]), "")
+		value, err := strconv.ParseFloat(strings.TrimSpace(s[i+len(op):]), 64)
+		if err != nil {
+			return nil, errors.Errorf("stop condition '%s' must compare with a number", s)
+		}
+		if !validMetric(metric) {
+			return nil, errors.Errorf("stop condition '%s' has unknown metric '%s'", s, metric)
+		}
+		return &compareCondition{metric, op, value, s}, nil
+	}
+	return nil, errors.Errorf("can't parse stop condition '%s'", s)
+}
+
+// ParseStopConditions parses each string as a StopCondition.
+func ParseStopConditions(ss []string) ([]StopCondition, error) {
+	conditions := make([]StopCondition, len(ss))
+	for i := range ss {
+		condition, err := ParseStopCondition(ss[i])
+		if err != nil {
+			return nil, err
+		}
+		conditions[i] = condition
+	}
+	return conditions, nil
+}
+
+// stopSpecies returns the Species counted by a StopCondition, if any.
+func stopSpecies(condition StopCondition) (string, bool) {
+	c, ok := condition.(*compareCondition)
+	if !ok || !strings.HasPrefix(c.metric, "population:") {
+		return "", false
+	}
+	return strings.TrimPrefix(c.metric, "population:"), true
+}
+
+func validMetric(metric string) bool {
+	switch metric {
+	case "tick", "energy", "biomass", "population":
+		return true
+	}
+	return strings.HasPrefix(metric, "population:") && len(metric) > len("population:")
+}
+
+// compareCondition compares a metric of a Sample with a value.
+type compareCondition struct {
+	metric string
+	op     string
+	value  float64
+	source string
+}
+
+func (c *compareCondition) Met(w *World, sample Sample) bool {
+	var n float64
+	switch c.metric {
+	case "tick":
+		n = float64(sample.Tick)
+	case "energy":
+		n = float64(sample.TotalEnergy)
+	case "biomass":
+		n = float64(sample.Biomass)
+	case "population":
+		for _, count := range sample.Population {
+			n += float64(count)
+		}
+	default:
+		n = float64(sample.Population[strings.TrimPrefix(c.metric, "population:")])
+	}
+
+	switch c.op {
+	case "<":
+		return n < c.value
+	case "<=":
+		return n <= c.value
+	case ">":
+		return n > c.value
+	case ">=":
+		return n >= c.value
+	}
+	return n == c.value
+}
+
+func (c *compareCondition) String() string {
+	return c.source
+}
+
+// stableCondition is met when the population of every Species has stayed
+// the same for a number of ticks.
+type stableCondition struct {
+	ticks int
+
+	last  map[string]int
+	count int
+}
+
+func (c *stableCondition) Met(w *World, sample Sample) bool {
+	if c.last != nil && samePopulation(c.last, sample.Population) {
+		c.count++
+	} else {
+		c.count = 0
+	}
+	c.last = sample.Population
+	return c.count >= c.ticks
+}
+
+func (c *stableCondition) String() string {
+	return fmt.Sprintf("stable: %d", c.ticks)
+}
+
+func samePopulation(a, b map[string]int) bool {
+	for key, n := range a {
+		if b[key] != n {
+			return false
+		}
+	}
+	for key, n := range b {
+		if a[key] != n {
+			return false
+		}
+	}
+	return true
+}
+
+// ---------------------------------------------------------------------
+// Batch runs
+
+// Report summarizes a headless run.
+type Report struct {
+	Ticks   int           `json:"ticks"`
+	Reason  string        `json:"reason"`
+	Elapsed time.Duration `json:"elapsed_ns"`
+
+	// Final is a census of the World when the run stopped, with the births,
+	// deaths and causes of death over the whole run.
+	Final Sample `json:"final"`
+
+	// Peak is the highest population each Species reached, and Extinct the
+	// tick at which each Species that died out did so.
+	Peak    map[string]int `json:"peak"`
+	Extinct map[string]int `json:"extinct"`
+}
+
+// RunUntil ticks the World as fast as it can until one of the conditions is
+// met. It runs forever if none ever are. Observers are called after every
+// tick, before the conditions are checked.
+func RunUntil(w *World, conditions []StopCondition, observers ...func(*World)) *Report {
+	start := time.Now()
+	report := &Report{
+		Peak:    make(map[string]int),
+		Extinct: make(map[string]int),
+	}
+
+	collector := NewCollector(w, 1, 1)
+	defer collector.Close()
+	births, deaths, causes := 0, 0, make(map[string]int)
+
+	for {
+		w.Tick()
+		for _, observe := range observers {
+			observe(w)
+		}
+		sample, _ := collector.Collect(w)
+		births += sample.Births
+		deaths += sample.Deaths
+		for cause, n := range sample.Causes {
+			causes[cause] += n
+		}
+
+		for key, n := range sample.Population {
+			if n > report.Peak[key] {
+				report.Peak[key] = n
+			}
+		}
+		for key := range report.Peak {
+			if _, ok := report.Extinct[key]; !ok && sample.Population[key] == 0 {
+				report.Extinct[key] = sample.Tick
+			}
+		}
+
+		// Check every condition, so that stateful ones see every tick.
+		met := make([]string, 0)
+		for _, condition := range conditions {
+			if condition.Met(w, sample) {
+				met = append(met, condition.String())
+			}
+		}
+		if len(met) > 0 {
+			sample.Births, sample.Deaths, sample.Causes = births, deaths, causes
+			report.Ticks = sample.Tick
+			report.Reason = strings.Join(met, ", ")
+			report.Final = sample
+			report.Elapsed = time.Since(start)
+			return report
+		}
+	}
+}
+
+// WriteText writes the Report for people to read.
+func (r *Report) WriteText(w io.Writer) error {
+	var b strings.Builder
+	fmt.Fprintf(&b, "stopped at tick %d: %s\n", r.Ticks, r.Reason)
+	if seconds := r.Elapsed.Seconds(); seconds > 0 {
+		fmt.Fprintf(&b, "ran for %s (%.0f ticks/s)\n", r.Elapsed.Round(time.Millisecond), float64(r.Ticks)/seconds)
+	}
+	fmt.Fprintf(&b, "energy %d total, %.1f mean; biomass %d\n",
+		r.Final.TotalEnergy, r.Final.MeanEnergy, r.Final.Biomass)
+	fmt.Fprintf(&b, "births %d, deaths %d\n", r.Final.Births, r.Final.Deaths)
+
+	keys := make(map[string]bool)
+	for key := range r.Peak {
+		keys[key] = true
+	}
+	fmt.Fprintln(&b, "population:")
+	for _, key := range sortedKeys(keys) {
+		fmt.Fprintf(&b, "  %-16s %6d (peak %d)", key, r.Final.Population[key], r.Peak[key])
+		if tick, ok := r.Extinct[key]; ok {
+			fmt.Fprintf(&b, ", extinct at tick %d", tick)
+		}
+		fmt.Fprintln(&b)
+	}
+
+	if len(r.Final.Causes) > 0 {
+		causes := make([]string, 0, len(r.Final.Causes))
+		for cause := range r.Final.Causes {
+			causes = append(causes, cause)
+		}
+		sort.Strings(causes)
+		fmt.Fprintln(&b, "deaths by cause:")
+		for _, cause := range causes {
+			fmt.Fprintf(&b, "  %-16s %6d\n", cause, r.Final.Causes[cause])
+		}
+	}
+
+	_, err := io.WriteString(w, b.String())
+	return err
+}
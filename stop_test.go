@@ -0,0 +1,78 @@
+package ecoscript_test
+
+import (
+	. "github.com/dustinrohde/ecoscript"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("StopCondition", func() {
+	var (
+		world  *World
+		sample func(tick int, population map[string]int) Sample
+	)
+
+	BeforeEach(func() {
+		world = NewWorld(3, 3, []string{"ground"})
+		sample = func(tick int, population map[string]int) Sample {
+			return Sample{Tick: tick, Population: population, TotalEnergy: 50}
+		}
+	})
+
+	It("should compare metrics", func() {
+		cond, err := ParseStopCondition("tick >= 10")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(cond.Met(world, sample(9, nil))).To(BeFalse())
+		Expect(cond.Met(world, sample(10, nil))).To(BeTrue())
+
+		cond, err = ParseStopCondition("energy<100")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(cond.Met(world, sample(1, nil))).To(BeTrue())
+
+		cond, err = ParseStopCondition("population:fox > 2")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(cond.Met(world, sample(1, map[string]int{"fox": 3}))).To(BeTrue())
+	})
+
+	It("should notice extinction", func() {
+		cond, err := ParseStopCondition("extinct: sheep")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(cond.Met(world, sample(1, map[string]int{"sheep": 1}))).To(BeFalse())
+		Expect(cond.Met(world, sample(2, map[string]int{"fox": 1}))).To(BeTrue())
+	})
+
+	It("should wait for populations to stay stable", func() {
+		cond, err := ParseStopCondition("stable: 2")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(cond.Met(world, sample(1, map[string]int{"fox": 1}))).To(BeFalse())
+		Expect(cond.Met(world, sample(2, map[string]int{"fox": 1}))).To(BeFalse())
+		Expect(cond.Met(world, sample(3, map[string]int{"fox": 2}))).To(BeFalse())
+		Expect(cond.Met(world, sample(4, map[string]int{"fox": 2}))).To(BeFalse())
+		Expect(cond.Met(world, sample(5, map[string]int{"fox": 2}))).To(BeTrue())
+	})
+
+	It("should reject conditions it can't parse", func() {
+		for _, s := range []string{"tick", "tick >= many", "height > 3", "extinct:", "stable: 0"} {
+			_, err := ParseStopCondition(s)
+			Expect(err).To(HaveOccurred(), s)
+		}
+	})
+
+	It("should run a World until a condition is met", func() {
+		ent := NewEntity("mayfly", "m").AddAttributes(&Attributes{Energy: 1, Size: 1, Mass: 1})
+		exec, ok := world.Add(ent, Vec(1, 1, 0))
+		Expect(ok).To(BeTrue())
+		exec()
+
+		conditions, err := ParseStopConditions([]string{"tick >= 5", "population == 0"})
+		Expect(err).NotTo(HaveOccurred())
+		ent.EndLife()
+		report := RunUntil(world, conditions)
+
+		Expect(report.Ticks).To(Equal(1))
+		Expect(report.Reason).To(Equal("population == 0"))
+		Expect(report.Final.Deaths).To(Equal(1))
+		Expect(report.Final.Causes).To(HaveKeyWithValue("starved", 1))
+	})
+})
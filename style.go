@@ -0,0 +1,281 @@
+package ecoscript
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// ColorMode is the kind of color a terminal can show.
+type ColorMode int
+
+const (
+	// Monochrome shows plain text, for files, pipes and dumb terminals.
+	Monochrome ColorMode = iota
+	// Color16 uses the 16 standard ANSI colors.
+	Color16
+	// Color256 uses the xterm 256-color palette.
+	Color256
+	// TrueColor uses 24-bit RGB colors.
+	TrueColor
+)
+
+// ParseColorMode parses a ColorMode from "none", "16", "256" or
+// "truecolor".
+func ParseColorMode(s string) (ColorMode, error) {
+	switch s {
+	case "none", "mono":
+		return Monochrome, nil
+	case "16":
+		return Color16, nil
+	case "256":
+		return Color256, nil
+	case "truecolor", "24bit":
+		return TrueColor, nil
+	}
+	return Monochrome, errors.Errorf("unknown color mode '%s'", s)
+}
+
+// DetectColorMode guesses the ColorMode of a terminal from the environment,
+// following the NO_COLOR, COLORTERM and TERM conventions. Output that isn't
+// going to a terminal is Monochrome.
+func DetectColorMode(terminal bool) ColorMode {
+	term := os.Getenv("TERM")
+	if !terminal || term == "dumb" || os.Getenv("NO_COLOR") != "" {
+		return Monochrome
+	}
+	switch colorterm := os.Getenv("COLORTERM"); colorterm {
+	case "truecolor", "24bit":
+		return TrueColor
+	}
+	if strings.Contains(term, "256color") {
+		return Color256
+	}
+	return Color16
+}
+
+// Color is a color as written in a Mapfile: one of the eight ANSI color
+// names, optionally prefixed with "bright-", an index into the 256-color
+// palette like "214", or an RGB value like "#2e8b57". The empty Color is the
+// terminal's default.
+type Color string
+
+var colorNames = []string{"black", "red", "green", "yellow", "blue", "magenta", "cyan", "white"}
+
+// Validate returns an error if the Color can't be parsed.
+func (c Color) Validate() error {
+	if c == "" {
+		return nil
+	}
+	_, err := c.resolve()
+	return err
+}
+
+// rgbColor is a resolved Color. Palette colors keep their index, so they can
+// be shown exactly in the modes that have them; RGB colors have index -1.
+type rgbColor struct {
+	index   int
+	r, g, b uint8
+}
+
+func (c Color) resolve() (rgbColor, error) {
+	s := strings.ToLower(strings.TrimSpace(string(c)))
+	if strings.HasPrefix(s, "#") {
+		if len(s) != 7 {
+			return rgbColor{}, errors.Errorf("color '%s' should be #RRGGBB", c)
+		}
+		v, err := strconv.ParseUint(s[1:], 16, 32)
+		if err != nil {
+			return rgbColor{}, errors.Errorf("color '%s' should be #RRGGBB", c)
+		}
+		return rgbColor{index: -1, r: uint8(v >> 16), g: uint8(v >> 8), b: uint8(v)}, nil
+	}
+	if n, err := strconv.Atoi(s); err == nil {
+		if n < 0 || n > 255 {
+			return rgbColor{}, errors.Errorf("color '%s' must be between 0 and 255", c)
+		}
+		return paletteColor(n), nil
+	}
+	bright := strings.HasPrefix(s, "bright-")
+	name := strings.TrimPrefix(s, "bright-")
+	for i, known := range colorNames {
+		if name == known {
+			if bright {
+				i += 8
+			}
+			return paletteColor(i), nil
+		}
+	}
+	return rgbColor{}, errors.Errorf("unknown color '%s'", c)
+}
+
+// ansi16 holds the RGB values xterm uses for the 16 standard colors.
+var ansi16 = [16][3]uint8{
+	{0, 0, 0}, {205, 0, 0}, {0, 205, 0}, {205, 205, 0},
+	{0, 0, 238}, {205, 0, 205}, {0, 205, 205}, {229, 229, 229},
+	{127, 127, 127}, {255, 0, 0}, {0, 255, 0}, {255, 255, 0},
+	{92, 92, 255}, {255, 0, 255}, {0, 255, 255}, {255, 255, 255},
+}
+
+// cubeLevels are the channel values of the 6x6x6 color cube in the
+// 256-color palette.
+var cubeLevels = [6]uint8{0, 95, 135, 175, 215, 255}
+
+func paletteColor(n int) rgbColor {
+	switch {
+	case n < 16:
+		rgb := ansi16[n]
+		return rgbColor{n, rgb[0], rgb[1], rgb[2]}
+	case n < 232:
+		n -= 16
+		return rgbColor{n + 16, cubeLevels[n/36], cubeLevels[n/6%6], cubeLevels[n%6]}
+	}
+	gray := uint8(8 + 10*(n-232))
+	return rgbColor{n, gray, gray, gray}
+}
+
+// scale darkens the color by a factor between 0 and 1.
+func (c rgbColor) scale(factor float64) rgbColor {
+	return rgbColor{
+		index: -1,
+		r:     uint8(float64(c.r) * factor),
+		g:     uint8(float64(c.g) * factor),
+		b:     uint8(float64(c.b) * factor),
+	}
+}
+
+// nearest returns the index of the closest of the first n palette colors,
+// or of the whole palette past the 16 standard colors if n is 256, whose
+// values vary between terminals.
+func (c rgbColor) nearest(n int) int {
+	best, bestDist := 0, -1
+	first := 0
+	if n == 256 {
+		first = 16
+	}
+	for i := first; i < n; i++ {
+		p := paletteColor(i)
+		dr, dg, db := int(c.r)-int(p.r), int(c.g)-int(p.g), int(c.b)-int(p.b)
+		if dist := dr*dr + dg*dg + db*db; bestDist < 0 || dist < bestDist {
+			best, bestDist = i, dist
+		}
+	}
+	return best
+}
+
+// sgr returns the SGR parameters that select the color in the given mode,
+// as a foreground color or a background one.
+func (c rgbColor) sgr(mode ColorMode, background bool) string {
+	switch mode {
+	case TrueColor:
+		if c.index >= 0 && c.index < 16 {
+			return c.sgr(Color16, background)
+		}
+		if background {
+			return fmt.Sprintf("48;2;%d;%d;%d", c.r, c.g, c.b)
+		}
+		return fmt.Sprintf("38;2;%d;%d;%d", c.r, c.g, c.b)
+	case Color256:
+		if c.index >= 0 && c.index < 16 {
+			return c.sgr(Color16, background)
+		}
+		index := c.index
+		if index < 0 {
+			index = c.nearest(256)
+		}
+		if background {
+			return fmt.Sprintf("48;5;%d", index)
+		}
+		return fmt.Sprintf("38;5;%d", index)
+	case Color16:
+		index := c.index
+		if index < 0 || index >= 16 {
+			index = c.nearest(16)
+		}
+		base := 30
+		if background {
+			base = 40
+		}
+		if index >= 8 {
+			base += 60
+			index -= 8
+		}
+		return strconv.Itoa(base + index)
+	}
+	return ""
+}
+
+// Style is how an Entity's Symbol is drawn in a terminal, as declared by
+// the style of an entity in a Mapfile.
+type Style struct {
+	Foreground Color `mapstructure:"fg" json:"fg,omitempty"`
+	Background Color `mapstructure:"bg" json:"bg,omitempty"`
+	Bold       bool  `mapstructure:"bold" json:"bold,omitempty"`
+	Dim        bool  `mapstructure:"dim" json:"dim,omitempty"`
+	Italic     bool  `mapstructure:"italic" json:"italic,omitempty"`
+	Underline  bool  `mapstructure:"underline" json:"underline,omitempty"`
+}
+
+// Validate returns an error if either of the Style's colors is invalid.
+func (s Style) Validate() error {
+	if err := s.Foreground.Validate(); err != nil {
+		return errors.WithMessage(err, "invalid fg")
+	}
+	if err := s.Background.Validate(); err != nil {
+		return errors.WithMessage(err, "invalid bg")
+	}
+	return nil
+}
+
+// Render returns text drawn in the Style, or text as it is in Monochrome.
+func (s Style) Render(text string, mode ColorMode) string {
+	return s.render(text, mode, 1, "")
+}
+
+// render draws text in the Style with its foreground darkened by shade, on
+// the given background color if the Style has none of its own.
+func (s Style) render(text string, mode ColorMode, shade float64, background Color) string {
+	if mode == Monochrome {
+		return text
+	}
+
+	params := make([]string, 0, 6)
+	if s.Bold {
+		params = append(params, "1")
+	}
+	if s.Dim || (shade < 0.5 && mode == Color16) {
+		params = append(params, "2")
+	}
+	if s.Italic {
+		params = append(params, "3")
+	}
+	if s.Underline {
+		params = append(params, "4")
+	}
+
+	fg := s.Foreground
+	if fg == "" && shade < 1 {
+		fg = "white"
+	}
+	if c, err := fg.resolve(); fg != "" && err == nil {
+		if shade < 1 && mode != Color16 {
+			c = c.scale(shade)
+		}
+		params = append(params, c.sgr(mode, false))
+	}
+	bg := s.Background
+	if bg == "" {
+		bg = background
+	}
+	if c, err := bg.resolve(); bg != "" && err == nil {
+		params = append(params, c.sgr(mode, true))
+	}
+
+	if len(params) == 0 {
+		return text
+	}
+	return "\x1b[" + strings.Join(params, ";") + "m" + text + "\x1b[0m"
+}
@@ -0,0 +1,81 @@
+package ecoscript_test
+
+import (
+	. "github.com/dustinrohde/ecoscript"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Style", func() {
+	It("should accept color names, palette indexes and RGB values", func() {
+		for _, c := range []Color{"", "green", "bright-cyan", "214", "#2e8b57"} {
+			Expect(c.Validate()).To(Succeed())
+		}
+		for _, c := range []Color{"greenish", "256", "#2e8b5", "#zzzzzz"} {
+			Expect(c.Validate()).NotTo(Succeed())
+		}
+	})
+
+	It("should render in every color mode", func() {
+		style := Style{Foreground: "#30c030", Background: "blue", Bold: true}
+		Expect(style.Render("A", Monochrome)).To(Equal("A"))
+		Expect(style.Render("A", Color16)).To(Equal("\x1b[1;32;44mA\x1b[0m"))
+		Expect(style.Render("A", Color256)).To(Equal("\x1b[1;38;5;71;44mA\x1b[0m"))
+		Expect(style.Render("A", TrueColor)).To(Equal("\x1b[1;38;2;48;192;48;44mA\x1b[0m"))
+		Expect(Style{}.Render("A", TrueColor)).To(Equal("A"))
+	})
+
+	It("should parse color modes", func() {
+		mode, err := ParseColorMode("256")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(mode).To(Equal(Color256))
+		_, err = ParseColorMode("rainbow")
+		Expect(err).To(HaveOccurred())
+		Expect(DetectColorMode(false)).To(Equal(Monochrome))
+	})
+})
+
+var _ = Describe("Layer.DisplayWith", func() {
+	var world *World
+
+	BeforeEach(func() {
+		world = NewWorld(3, 1, []string{"ground"})
+		for x, energy := range []int{100, 0} {
+			ent := NewEntity("sheep", "&").AddAttributes(&Attributes{Energy: energy})
+			ent.Style = Style{Foreground: "#c8c8c8"}
+			exec, ok := world.Add(ent, Vec(x, 0, 0))
+			Expect(ok).To(BeTrue())
+			exec()
+		}
+		world.Cell(Vec(2, 0, 0)).SetTerrain(Terrain{Kind: "water", Color: "#102a5c"})
+	})
+
+	It("should draw plain symbols in Monochrome", func() {
+		opts := DisplayOptions{Mode: Monochrome, Terrain: true, ShadeEnergy: true}
+		Expect(world.Layer(0).DisplayWith(opts)).To(Equal(world.Layer(0).Display()))
+	})
+
+	It("should color entities and terrain", func() {
+		tiles := world.Layer(0).Tiles(DisplayOptions{Mode: TrueColor, Terrain: true})
+		Expect(tiles[0][0]).To(Equal("\x1b[38;2;200;200;200m&\x1b[0m"))
+		Expect(tiles[0][2]).To(Equal("\x1b[48;2;16;42;92m \x1b[0m"))
+	})
+
+	It("should shade entities by energy", func() {
+		tiles := world.Layer(0).Tiles(DisplayOptions{Mode: TrueColor, ShadeEnergy: true})
+		Expect(tiles[0][0]).To(Equal("\x1b[38;2;200;200;200m&\x1b[0m"))
+		Expect(tiles[0][1]).To(Equal("\x1b[38;2;70;70;70m&\x1b[0m"))
+	})
+
+	It("should color the background by a Field", func() {
+		field := world.AddField("scent", 0, 0)
+		field.Color = "#ff0000"
+		field.Set(Vec(0, 0, 0), 2)
+		field.Set(Vec(1, 0, 0), 1)
+		tiles := world.Layer(0).Tiles(DisplayOptions{Mode: TrueColor, Field: field})
+		Expect(tiles[0][0]).To(ContainSubstring("48;2;255;0;0"))
+		Expect(tiles[0][1]).To(ContainSubstring("48;2;127;0;0"))
+		Expect(tiles[0][2]).To(Equal(" "))
+	})
+})
@@ -0,0 +1,55 @@
+package ecoscript
+
+// Terrain describes the ground a Cell lies on.
+type Terrain struct {
+	// Kind is a human-readable name, like "grass" or "deep water".
+	Kind string
+
+	// Moisture is how wet the ground is, from 0 (dry) to 1 (saturated).
+	Moisture float64
+
+	// Fertility scales how quickly plants grow. 1 is normal growth.
+	Fertility float64
+
+	// Elevation is the height of the ground, in arbitrary units.
+	Elevation float64
+
+	// MoveCost is the number of ticks and energy it costs to move onto the
+	// Cell, relative to normal ground.
+	MoveCost int
+
+	// Impassable is true if nothing can walk onto the Cell, like deep water.
+	Impassable bool
+
+	// Color is the background of the Cell when it's displayed in color.
+	Color Color
+}
+
+// DefaultTerrain is the Terrain of Cells that are not given any other.
+var DefaultTerrain = Terrain{
+	Kind:      "ground",
+	Moisture:  0.5,
+	Fertility: 1,
+	MoveCost:  1,
+}
+
+func (c *Cell) Terrain() *Terrain {
+	return &c.terrain
+}
+
+func (c *Cell) SetTerrain(terrain Terrain) {
+	c.terrain = terrain
+}
+
+// GrowthFactor scales an amount of growth by the fertility of the ground.
+func (t *Terrain) GrowthFactor(energy int) int {
+	return int(float64(energy) * t.Fertility)
+}
+
+// Cost returns the movement cost of the Terrain, which is at least 1.
+func (t *Terrain) Cost() int {
+	if t.MoveCost < 1 {
+		return 1
+	}
+	return t.MoveCost
+}
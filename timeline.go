@@ -0,0 +1,284 @@
+package ecoscript
+
+import (
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"html/template"
+	"image/png"
+	"io"
+	"sort"
+	"strings"
+)
+
+// DefaultTimelineEvents is the number of Events a Timeline keeps for its
+// log, by default.
+const DefaultTimelineEvents = 2000
+
+// Timeline records a World as it runs for an HTML report: a frame of the
+// World and its population every Interval ticks, and a log of the deaths and
+// consumptions in between.
+type Timeline struct {
+	Interval int
+	Image    ImageOptions
+
+	// MaxEvents is the number of Events to keep for the log. Later Events
+	// are counted but not kept.
+	MaxEvents int
+
+	frames  []timelineFrame
+	events  []Event
+	dropped int
+	colors  map[string]string
+
+	unsubscribe func()
+}
+
+type timelineFrame struct {
+	Tick       int
+	Image      string
+	Population map[string]int
+}
+
+// NewTimeline creates a Timeline that listens to the World, and captures
+// its first frame. Call Observe after each tick, and Close when done.
+func NewTimeline(w *World, interval int, opts ImageOptions) *Timeline {
+	if interval < 1 {
+		interval = 1
+	}
+	t := &Timeline{
+		Interval:  interval,
+		Image:     opts,
+		MaxEvents: DefaultTimelineEvents,
+		colors:    make(map[string]string),
+	}
+
+	filter := Filter{Types: []EventType{EventDied, EventConsumed}}
+	t.unsubscribe = w.Events().Subscribe(filter, func(ev Event) {
+		if len(t.events) < t.MaxEvents {
+			t.events = append(t.events, ev)
+		} else {
+			t.dropped++
+		}
+	})
+	t.capture(w)
+	return t
+}
+
+// Close stops listening to the World.
+func (t *Timeline) Close() {
+	t.unsubscribe()
+}
+
+// Observe captures a frame of the World if one is due.
+func (t *Timeline) Observe(w *World) {
+	if w.Clock().Ticks()%t.Interval == 0 {
+		t.Capture(w)
+	}
+}
+
+// Capture captures a frame of the World, unless it already has one of the
+// current tick, as at the end of a run.
+func (t *Timeline) Capture(w *World) {
+	if n := len(t.frames); n > 0 && t.frames[n-1].Tick == w.Clock().Ticks() {
+		return
+	}
+	t.capture(w)
+}
+
+func (t *Timeline) capture(w *World) {
+	var buf bytes.Buffer
+	Guard(png.Encode(&buf, w.Image(t.Image)))
+	population := Census(w).Population
+	for key := range population {
+		if _, ok := t.colors[key]; ok {
+			continue
+		}
+		c := t.Image.colorOf(key, key, Style{})
+		if species := w.Species(key); species != nil {
+			c = t.Image.colorOf(key, species.Name, species.Style)
+		}
+		t.colors[key] = fmt.Sprintf("#%02x%02x%02x", c.R, c.G, c.B)
+	}
+
+	t.frames = append(t.frames, timelineFrame{
+		Tick:       w.Clock().Ticks(),
+		Image:      "data:image/png;base64," + base64.StdEncoding.EncodeToString(buf.Bytes()),
+		Population: population,
+	})
+}
+
+// Chart dimensions, in SVG user units.
+const (
+	chartWidth  = 800
+	chartHeight = 240
+)
+
+type chartSeries struct {
+	Key    string
+	Color  string
+	Points string
+	Last   int
+}
+
+// chart plots the population of each Species in the frames as SVG polyline
+// points.
+func (t *Timeline) chart() (series []chartSeries, peak int) {
+	keys := make(map[string]bool)
+	for _, frame := range t.frames {
+		for key, n := range frame.Population {
+			keys[key] = true
+			if n > peak {
+				peak = n
+			}
+		}
+	}
+	if len(t.frames) == 0 || peak == 0 {
+		return nil, peak
+	}
+
+	first, last := t.frames[0].Tick, t.frames[len(t.frames)-1].Tick
+	span := last - first
+	if span == 0 {
+		span = 1
+	}
+	for _, key := range sortedKeys(keys) {
+		points := make([]string, len(t.frames))
+		for i, frame := range t.frames {
+			x := float64(frame.Tick-first) / float64(span) * chartWidth
+			y := chartHeight - float64(frame.Population[key])/float64(peak)*chartHeight
+			points[i] = fmt.Sprintf("%.1f,%.1f", x, y)
+		}
+		series = append(series, chartSeries{
+			Key:    key,
+			Color:  t.colors[key],
+			Points: strings.Join(points, " "),
+			Last:   t.frames[len(t.frames)-1].Population[key],
+		})
+	}
+	return series, peak
+}
+
+// WriteHTML writes the Timeline as a single HTML page with no external
+// assets: a player for its frames, a chart of the population of each
+// Species, and the log. The Report of the run, if given, is summarized at
+// the top.
+func (t *Timeline) WriteHTML(out io.Writer, title string, report *Report) error {
+	type frame struct {
+		Tick  int    `json:"tick"`
+		Image string `json:"image"`
+	}
+	frames := make([]frame, len(t.frames))
+	for i, f := range t.frames {
+		frames[i] = frame{f.Tick, f.Image}
+	}
+
+	events := make([]Event, len(t.events))
+	copy(events, t.events)
+	sort.SliceStable(events, func(i, j int) bool {
+		return events[i].Tick < events[j].Tick
+	})
+
+	series, peak := t.chart()
+	var summary string
+	if report != nil {
+		var b strings.Builder
+		if err := report.WriteText(&b); err != nil {
+			return err
+		}
+		summary = b.String()
+	}
+
+	return timelineTemplate.Execute(out, map[string]interface{}{
+		"Title":   title,
+		"Summary": summary,
+		"Frames":  frames,
+		"Series":  series,
+		"Peak":    peak,
+		"Width":   chartWidth,
+		"Height":  chartHeight,
+		"Events":  events,
+		"Dropped": t.dropped,
+	})
+}
+
+var timelineTemplate = template.Must(template.New("timeline").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>{{.Title}}</title>
+<style>
+body { font-family: sans-serif; margin: 2em; color: #222; }
+pre { background: #f4f4f4; padding: 1em; }
+#frame { image-rendering: pixelated; border: 1px solid #ccc; max-width: 100%; }
+.legend span { margin-right: 1.5em; }
+.swatch { display: inline-block; width: 0.8em; height: 0.8em; margin-right: 0.3em; }
+table { border-collapse: collapse; }
+td, th { padding: 0.2em 0.8em; text-align: left; border-bottom: 1px solid #eee; }
+</style>
+</head>
+<body>
+<h1>{{.Title}}</h1>
+{{if .Summary}}<pre>{{.Summary}}</pre>{{end}}
+
+<h2>Timeline</h2>
+<div>
+<button id="play">play</button>
+<input id="slider" type="range" min="0" max="0" value="0">
+<span id="tick"></span>
+</div>
+<img id="frame" alt="frame">
+
+<h2>Population</h2>
+<svg viewBox="0 0 {{.Width}} {{.Height}}" width="{{.Width}}" height="{{.Height}}">
+<rect width="{{.Width}}" height="{{.Height}}" fill="#fafafa"/>
+{{range .Series}}<polyline fill="none" stroke="{{.Color}}" stroke-width="2" points="{{.Points}}"><title>{{.Key}}</title></polyline>
+{{end}}</svg>
+<p class="legend">peak {{.Peak}}:
+{{range .Series}}<span><i class="swatch" style="background: {{.Color}}"></i>{{.Key}} ({{.Last}})</span>{{end}}
+</p>
+
+<h2>Deaths and consumptions</h2>
+{{if .Dropped}}<p>{{.Dropped}} later events are not shown.</p>{{end}}
+<table>
+<tr><th>tick</th><th>event</th><th>entity</th><th>other</th><th>detail</th></tr>
+{{range .Events}}<tr><td>{{.Tick}}</td><td>{{.Type}}</td><td>#{{.Entity}} {{.Species}}</td><td>{{if .Other}}#{{.Other}}{{end}}</td><td>{{.Detail}}</td></tr>
+{{end}}</table>
+
+<script>
+var frames = {{.Frames}};
+var slider = document.getElementById("slider");
+var image = document.getElementById("frame");
+var label = document.getElementById("tick");
+var button = document.getElementById("play");
+var timer = null;
+
+function show(i) {
+  slider.value = i;
+  image.src = frames[i].image;
+  label.textContent = "tick " + frames[i].tick;
+}
+
+function stop() {
+  clearInterval(timer);
+  timer = null;
+  button.textContent = "play";
+}
+
+slider.max = frames.length - 1;
+slider.oninput = function() { stop(); show(+slider.value); };
+button.onclick = function() {
+  if (timer) { stop(); return; }
+  if (+slider.value === frames.length - 1) { show(0); }
+  button.textContent = "pause";
+  timer = setInterval(function() {
+    var next = +slider.value + 1;
+    if (next >= frames.length) { stop(); return; }
+    show(next);
+  }, 200);
+};
+if (frames.length > 0) { show(0); }
+</script>
+</body>
+</html>
+`))
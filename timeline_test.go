@@ -0,0 +1,53 @@
+package ecoscript_test
+
+import (
+	"bytes"
+	"strings"
+
+	. "github.com/dustinrohde/ecoscript"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Timeline", func() {
+	It("should write a self-contained HTML report of a run", func() {
+		world := pasture(20, 4)
+		timeline := NewTimeline(world, 5, ImageOptions{TileSize: 2})
+		conditions, err := ParseStopConditions([]string{"tick >= 22"})
+		Expect(err).NotTo(HaveOccurred())
+		report := RunUntil(world, conditions, timeline.Observe)
+		timeline.Capture(world)
+		timeline.Close()
+
+		var buf bytes.Buffer
+		Expect(timeline.WriteHTML(&buf, "pasture <test>", report)).To(Succeed())
+		html := buf.String()
+
+		Expect(html).To(ContainSubstring("<title>pasture &lt;test&gt;</title>"))
+		Expect(html).To(ContainSubstring("stopped at tick 22"))
+		for _, tick := range []string{"0", "5", "10", "15", "20", "22"} {
+			Expect(html).To(ContainSubstring(`"tick":` + tick + `,`))
+		}
+		Expect(strings.Count(html, "data:image/png;base64,")).To(Equal(6))
+		Expect(strings.Count(html, "<polyline")).To(Equal(3))
+		Expect(html).To(ContainSubstring("<td>consumed</td>"))
+		Expect(html).NotTo(ContainSubstring("http"))
+	})
+
+	It("should keep at most MaxEvents events", func() {
+		world := pasture(20, 4)
+		timeline := NewTimeline(world, 5, ImageOptions{TileSize: 1})
+		timeline.MaxEvents = 1
+		for i := 0; i < 20; i++ {
+			world.Tick()
+			timeline.Observe(world)
+		}
+		timeline.Close()
+
+		var buf bytes.Buffer
+		Expect(timeline.WriteHTML(&buf, "pasture", nil)).To(Succeed())
+		Expect(strings.Count(buf.String(), "<tr><td>")).To(Equal(1))
+		Expect(buf.String()).To(MatchRegexp(`\d+ later events are not shown`))
+	})
+})
@@ -0,0 +1,85 @@
+package tui
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/dustinrohde/ecoscript"
+)
+
+// DescribeEntity describes an Entity and what it is doing, one line each.
+func DescribeEntity(world *ecoscript.World, ent *ecoscript.Entity, vec ecoscript.Vector) []string {
+	lines := make([]string, 0)
+
+	header := fmt.Sprintf("entity #%d %s", ent.ID(), ent.Name)
+	if ent.Species() != "" && ent.Species() != ent.Name {
+		header += fmt.Sprintf(" (%s)", ent.Species())
+	}
+	header += fmt.Sprintf(" '%s' at (%d,%d) in %s", ent.Symbol, vec.X, vec.Y, world.Layer(vec.Z).Name())
+	lines = append(lines, header)
+
+	attrs := ent.Attrs
+	lines = append(lines, fmt.Sprintf("  energy %d, size %d, mass %d, walkable %t",
+		attrs.Energy, attrs.Size, attrs.Mass, attrs.Walkable))
+
+	traits := make([]string, len(ent.Traits))
+	for i := range ent.Traits {
+		traits[i] = string(ent.Traits[i])
+	}
+	lines = append(lines, fmt.Sprintf("  traits: %s", strings.Join(traits, ", ")))
+
+	if ent.Activity().InProgress() {
+		ticks, needed := ent.Activity().Progress()
+		lines = append(lines, fmt.Sprintf("  doing: %s (%d of %d ticks)", ent.Behavior(), ticks, needed))
+	} else if ent.Behavior() != "" {
+		lines = append(lines, fmt.Sprintf("  last did: %s", ent.Behavior()))
+	}
+
+	keys := make([]string, 0, len(ent.Behaviors))
+	for key := range ent.Behaviors {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	lines = append(lines, "  behaviors:")
+	for _, key := range keys {
+		state := strings.TrimPrefix(fmt.Sprintf("%+v", ent.Behaviors[key]), "&")
+		lines = append(lines, fmt.Sprintf("    %s %s", key, state))
+	}
+	return lines
+}
+
+// DescribeCell describes a Cell: its terrain, the Fields there and the
+// Entities in it, one line each.
+func DescribeCell(world *ecoscript.World, vec ecoscript.Vector) []string {
+	cell := world.Cell(vec)
+	lines := []string{
+		fmt.Sprintf("cell (%d,%d) in %s at tick %d", vec.X, vec.Y, world.Layer(vec.Z).Name(), world.Clock().Ticks()),
+	}
+
+	t := cell.Terrain()
+	terrain := fmt.Sprintf("  terrain: %s, moisture %g, fertility %g, elevation %g, move cost %d",
+		t.Kind, t.Moisture, t.Fertility, t.Elevation, t.MoveCost)
+	if t.Impassable {
+		terrain += ", impassable"
+	}
+	lines = append(lines, terrain)
+
+	if fields := world.Fields(); len(fields) > 0 {
+		values := make([]string, len(fields))
+		for i, field := range fields {
+			values[i] = fmt.Sprintf("%s %.2f", field.Name, field.At(vec))
+		}
+		lines = append(lines, fmt.Sprintf("  fields: %s", strings.Join(values, ", ")))
+	}
+
+	ents := cell.Entities()
+	if len(ents) == 0 {
+		return append(lines, "  entities: none")
+	}
+	lines = append(lines, "  entities:")
+	for _, ent := range ents {
+		lines = append(lines, fmt.Sprintf("    #%d %s '%s', energy %d", ent.ID(), ent.Name, ent.Symbol, ent.Attrs.Energy))
+	}
+	return lines
+}
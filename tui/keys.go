@@ -0,0 +1,67 @@
+package tui
+
+import (
+	"bufio"
+	"io"
+)
+
+// Names of the keys that aren't printable characters. Printable keys are
+// named by themselves.
+const (
+	KeyUp      = "up"
+	KeyDown    = "down"
+	KeyLeft    = "left"
+	KeyRight   = "right"
+	KeyTab     = "tab"
+	KeyBackTab = "backtab"
+	KeyEnter   = "enter"
+	KeyEscape  = "esc"
+	KeyCtrlC   = "ctrl-c"
+)
+
+// escapes maps the final byte of the escape sequences a terminal sends for
+// special keys, like ESC [ A for the up arrow, to their names.
+var escapes = map[byte]string{
+	'A': KeyUp,
+	'B': KeyDown,
+	'C': KeyRight,
+	'D': KeyLeft,
+	'Z': KeyBackTab,
+}
+
+// ReadKeys reads keystrokes from a terminal in raw mode, or from a script of
+// them, and sends their names on keys. It closes keys when r is exhausted.
+func ReadKeys(r io.Reader, keys chan<- string) {
+	defer close(keys)
+	br := bufio.NewReader(r)
+	for {
+		b, err := br.ReadByte()
+		if err != nil {
+			return
+		}
+
+		switch b {
+		case 0x1b:
+			if next, err := br.Peek(1); err != nil || next[0] != '[' {
+				keys <- KeyEscape
+				continue
+			}
+			br.ReadByte()
+			final, err := br.ReadByte()
+			if err != nil {
+				return
+			}
+			if name, ok := escapes[final]; ok {
+				keys <- name
+			}
+		case '\t':
+			keys <- KeyTab
+		case '\r', '\n':
+			keys <- KeyEnter
+		case 0x03:
+			keys <- KeyCtrlC
+		default:
+			keys <- string(b)
+		}
+	}
+}
@@ -0,0 +1,332 @@
+// Package tui plays a World full-screen in a terminal, with controls to
+// pause, step and speed up the simulation, switch between Layers, and move a
+// cursor around to inspect Cells and the Entities in them.
+package tui
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/dustinrohde/ecoscript"
+)
+
+const (
+	// DefaultDelay is the time to wait between ticks while running.
+	DefaultDelay = 500 * time.Millisecond
+
+	minDelay = 10 * time.Millisecond
+	maxDelay = 5 * time.Second
+
+	// logSize is the number of recent Events to show, if logging.
+	logSize = 5
+)
+
+// ANSI escape sequences.
+const (
+	enterScreen = "\x1b[?1049h\x1b[?25l"
+	leaveScreen = "\x1b[?25h\x1b[?1049l"
+	clearScreen = "\x1b[H\x1b[2J"
+	reverse     = "\x1b[7m"
+	reset       = "\x1b[0m"
+)
+
+const help = "space pause  s step  +/- speed  tab layer  c composite  arrows/hjkl move  n next entity  q quit"
+
+// UI plays a World, reading keys from one stream and drawing frames to
+// another. It is driven either by a terminal in raw mode or by a script of
+// keys, which makes it testable.
+type UI struct {
+	world *ecoscript.World
+	in    io.Reader
+	out   io.Writer
+
+	paused    bool
+	delay     time.Duration
+	z         int
+	cursor    ecoscript.Vector
+	selected  int
+	log       []string
+	display   ecoscript.DisplayOptions
+	composite bool
+}
+
+// New creates a UI for world that reads keys from in and draws to out.
+func New(world *ecoscript.World, in io.Reader, out io.Writer) *UI {
+	return &UI{
+		world: world,
+		in:    in,
+		out:   out,
+		delay: DefaultDelay,
+	}
+}
+
+// SetPaused sets whether the UI starts paused.
+func (ui *UI) SetPaused(paused bool) *UI {
+	ui.paused = paused
+	return ui
+}
+
+// SetDelay sets the time to wait between ticks while running.
+func (ui *UI) SetDelay(delay time.Duration) *UI {
+	ui.delay = clampDelay(delay)
+	return ui
+}
+
+// SetLayer sets the Layer to show first.
+func (ui *UI) SetLayer(z int) *UI {
+	ui.z = z
+	ui.cursor.Z = z
+	return ui
+}
+
+// SetDisplay sets how the Layer is drawn. The default is Monochrome.
+func (ui *UI) SetDisplay(opts ecoscript.DisplayOptions) *UI {
+	ui.display = opts
+	return ui
+}
+
+// LogEvents shows the most recent Events that pass filter below the map.
+func (ui *UI) LogEvents(filter ecoscript.Filter) *UI {
+	ui.world.Events().Subscribe(filter, func(ev ecoscript.Event) {
+		ui.log = append(ui.log, ev.String())
+		if len(ui.log) > logSize {
+			ui.log = ui.log[len(ui.log)-logSize:]
+		}
+	})
+	return ui
+}
+
+// Paused returns true if the UI is paused.
+func (ui *UI) Paused() bool {
+	return ui.paused
+}
+
+// Delay returns the time to wait between ticks while running.
+func (ui *UI) Delay() time.Duration {
+	return ui.delay
+}
+
+// Cursor returns the Vector of the Cell under the cursor.
+func (ui *UI) Cursor() ecoscript.Vector {
+	return ui.cursor
+}
+
+// Run takes over the output stream and plays the World until the quit key
+// is pressed or the input stream ends.
+func (ui *UI) Run() error {
+	keys := make(chan string)
+	go ReadKeys(ui.in, keys)
+
+	fmt.Fprint(ui.out, enterScreen)
+	defer fmt.Fprint(ui.out, leaveScreen)
+
+	ticker := time.NewTicker(ui.delay)
+	defer ticker.Stop()
+	delay := ui.delay
+
+	for {
+		if _, err := io.WriteString(ui.out, clearScreen+strings.Replace(ui.Frame(), "\n", "\r\n", -1)); err != nil {
+			return err
+		}
+		if ui.delay != delay {
+			delay = ui.delay
+			ticker.Reset(delay)
+		}
+		var tick <-chan time.Time
+		if !ui.paused {
+			tick = ticker.C
+		}
+
+		select {
+		case key, ok := <-keys:
+			if !ok || ui.Handle(key) {
+				return nil
+			}
+		case <-tick:
+			ui.world.Tick()
+		}
+	}
+}
+
+// Handle responds to a key, and returns true if it means to quit.
+func (ui *UI) Handle(key string) (quit bool) {
+	switch key {
+	case "q", KeyCtrlC:
+		return true
+	case " ", "p":
+		ui.paused = !ui.paused
+	case "s", ".":
+		ui.paused = true
+		ui.world.Tick()
+	case "+", "=":
+		ui.delay = clampDelay(ui.delay / 2)
+	case "-", "_":
+		ui.delay = clampDelay(ui.delay * 2)
+	case "c":
+		ui.composite = !ui.composite
+	case KeyTab, "]":
+		ui.setLayer((ui.z + 1) % ui.world.Depth())
+	case KeyBackTab, "[":
+		ui.setLayer((ui.z + ui.world.Depth() - 1) % ui.world.Depth())
+	case KeyUp, "k":
+		ui.move(0, -1)
+	case KeyDown, "j":
+		ui.move(0, 1)
+	case KeyLeft, "h":
+		ui.move(-1, 0)
+	case KeyRight, "l":
+		ui.move(1, 0)
+	case "n", KeyEnter:
+		ui.selected++
+	}
+	return false
+}
+
+func (ui *UI) setLayer(z int) {
+	ui.z = z
+	ui.cursor.Z = z
+	ui.selected = 0
+}
+
+func (ui *UI) move(dx, dy int) {
+	vec := ecoscript.Vec(ui.cursor.X+dx, ui.cursor.Y+dy, ui.z)
+	if ui.world.InBounds(vec) {
+		ui.cursor = vec
+		ui.selected = 0
+	}
+}
+
+func clampDelay(delay time.Duration) time.Duration {
+	if delay < minDelay {
+		return minDelay
+	}
+	if delay > maxDelay {
+		return maxDelay
+	}
+	return delay
+}
+
+// Frame renders the screen: the Layer with the cursor on it and a sidebar
+// beside it, then the inspector, the Event log and a line of help.
+func (ui *UI) Frame() string {
+	layer := ui.world.Layer(ui.z)
+	tiles := layer.Tiles(ui.display)
+	if ui.composite {
+		tiles = ui.world.CompositeTiles(ecoscript.CompositeOptions{DisplayOptions: ui.display})
+	}
+	sidebar := ui.sidebar()
+
+	var b strings.Builder
+	rows := layer.Height()
+	if len(sidebar) > rows {
+		rows = len(sidebar)
+	}
+	for y := 0; y < rows; y++ {
+		if y < layer.Height() {
+			for x := 0; x < layer.Width(); x++ {
+				symbol := tiles[y][x]
+				if x == ui.cursor.X && y == ui.cursor.Y {
+					symbol = reverse + symbol + reset
+				}
+				b.WriteString(symbol)
+			}
+		} else {
+			b.WriteString(strings.Repeat(" ", layer.Width()))
+		}
+		if y < len(sidebar) {
+			b.WriteString("  ")
+			b.WriteString(sidebar[y])
+		}
+		b.WriteString("\n")
+	}
+
+	b.WriteString("\n")
+	for _, line := range ui.inspector() {
+		b.WriteString(line)
+		b.WriteString("\n")
+	}
+	if len(ui.log) > 0 {
+		b.WriteString("\n")
+		for _, line := range ui.log {
+			b.WriteString(line)
+			b.WriteString("\n")
+		}
+	}
+	b.WriteString("\n")
+	b.WriteString(help)
+	b.WriteString("\n")
+	return b.String()
+}
+
+func (ui *UI) sidebar() []string {
+	clock := ui.world.Clock()
+	daylight := "night"
+	if clock.IsDay() {
+		daylight = "day"
+	}
+	state := fmt.Sprintf("running, %s per tick", ui.delay)
+	if ui.paused {
+		state = "paused"
+	}
+
+	lines := []string{
+		fmt.Sprintf("tick %d", clock.Ticks()),
+		fmt.Sprintf("day %d, hour %d (%s)", clock.Day(), clock.Hour(), daylight),
+		fmt.Sprintf("season: %s", clock.Season().Name),
+		state,
+		fmt.Sprintf("layer: %s (%d of %d)", ui.world.Layer(ui.z).Name(), ui.z+1, ui.world.Depth()),
+	}
+	if ui.composite {
+		lines = append(lines, "view: all layers")
+	}
+	for _, wx := range ui.world.Weather() {
+		if wx.Active() {
+			lines = append(lines, fmt.Sprintf("weather: %s", wx.Kind))
+		}
+	}
+
+	population := ecoscript.Census(ui.world).Population
+	species := make([]string, 0, len(population))
+	for name := range population {
+		species = append(species, name)
+	}
+	sort.Strings(species)
+	lines = append(lines, "", "population:")
+	for _, name := range species {
+		lines = append(lines, fmt.Sprintf("  %-12s %5d", name, population[name]))
+	}
+
+	if ui.display.Legend {
+		legend := ui.world.Layer(ui.z).Legend()
+		if ui.composite {
+			legend = ui.world.Legend(nil)
+		}
+		lines = append(lines, "", "legend:")
+		for _, line := range ecoscript.LegendLines(legend, ui.display.Mode) {
+			lines = append(lines, "  "+line)
+		}
+	}
+	return lines
+}
+
+// inspector describes the Cell under the cursor and the selected Entity in
+// it, marking the selected Entity in the Cell's stack.
+func (ui *UI) inspector() []string {
+	lines := DescribeCell(ui.world, ui.cursor)
+	ents := ui.world.Cell(ui.cursor).Entities()
+	if len(ents) == 0 {
+		return lines
+	}
+
+	selected := ui.selected % len(ents)
+	marker := fmt.Sprintf("    #%d ", ents[selected].ID())
+	for i, line := range lines {
+		if strings.HasPrefix(line, marker) {
+			lines[i] = "  > " + strings.TrimPrefix(line, "    ")
+		}
+	}
+	return append(append(lines, ""), DescribeEntity(ui.world, ents[selected], ui.cursor)...)
+}
@@ -0,0 +1,13 @@
+package tui_test
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"testing"
+)
+
+func TestTUI(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "tui suite")
+}
@@ -0,0 +1,122 @@
+package tui_test
+
+import (
+	"bytes"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/dustinrohde/ecoscript"
+	. "github.com/dustinrohde/ecoscript/tui"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("UI", func() {
+	var (
+		world *ecoscript.World
+		sheep *ecoscript.Entity
+		out   *bytes.Buffer
+	)
+
+	BeforeEach(func() {
+		world = ecoscript.NewWorld(5, 4, []string{"ground", "sky"})
+		world.Seed(1)
+		sheep = ecoscript.NewEntity("sheep", "&").
+			AddAttributes(&ecoscript.Attributes{Energy: 40, Size: 2, Mass: 10}).
+			AddTraits("herbivore")
+		exec, ok := world.Add(sheep, ecoscript.Vec(2, 1, 0))
+		Expect(ok).To(BeTrue())
+		exec()
+		out = new(bytes.Buffer)
+	})
+
+	play := func(script string) *UI {
+		ui := New(world, strings.NewReader(script), out).SetPaused(true)
+		Expect(ui.Run()).To(Succeed())
+		return ui
+	}
+
+	It("should step one tick at a time while paused", func() {
+		ui := play("ss")
+		Expect(world.Clock().Ticks()).To(Equal(2))
+		Expect(ui.Paused()).To(BeTrue())
+		Expect(out.String()).To(ContainSubstring("tick 2"))
+		Expect(out.String()).To(ContainSubstring("paused"))
+	})
+
+	It("should run until paused or quit", func() {
+		r, w := io.Pipe()
+		ui := New(world, r, out).SetPaused(true).SetDelay(10 * time.Millisecond)
+		done := make(chan error)
+		go func() { done <- ui.Run() }()
+		w.Write([]byte(" "))
+		time.Sleep(100 * time.Millisecond)
+		w.Write([]byte("q"))
+		Eventually(done).Should(Receive(BeNil()))
+		Expect(world.Clock().Ticks()).To(BeNumerically(">=", 3))
+		Expect(ui.Paused()).To(BeFalse())
+	})
+
+	It("should show the population", func() {
+		play("")
+		Expect(out.String()).To(MatchRegexp(`population:\r\n\s+sheep\s+1`))
+	})
+
+	It("should inspect the Cell under the cursor", func() {
+		ui := play("ll\x1b[B")
+		Expect(ui.Cursor()).To(Equal(ecoscript.Vec(2, 1, 0)))
+		frame := ui.Frame()
+		Expect(frame).To(ContainSubstring("cell (2,1) in ground"))
+		Expect(frame).To(ContainSubstring("  > #"))
+		Expect(frame).To(ContainSubstring("traits: herbivore"))
+		Expect(frame).To(ContainSubstring("energy 40"))
+	})
+
+	It("should keep the cursor in bounds", func() {
+		ui := play("hhkk" + strings.Repeat("l", 10))
+		Expect(ui.Cursor()).To(Equal(ecoscript.Vec(4, 0, 0)))
+	})
+
+	It("should cycle through Layers", func() {
+		ui := play("\t")
+		Expect(ui.Frame()).To(ContainSubstring("layer: sky (2 of 2)"))
+		Expect(ui.Cursor().Z).To(Equal(1))
+		ui.Handle(KeyTab)
+		Expect(ui.Frame()).To(ContainSubstring("layer: ground (1 of 2)"))
+		ui.Handle("[")
+		Expect(ui.Frame()).To(ContainSubstring("layer: sky"))
+	})
+
+	It("should change speed within bounds", func() {
+		ui := play("+")
+		Expect(ui.Delay()).To(Equal(DefaultDelay / 2))
+		for i := 0; i < 20; i++ {
+			ui.Handle("+")
+		}
+		Expect(ui.Delay()).To(BeNumerically(">", 0))
+		for i := 0; i < 20; i++ {
+			ui.Handle("-")
+		}
+		Expect(ui.Delay()).To(BeNumerically("<=", 5*time.Second))
+	})
+
+	It("should restore the terminal when it quits", func() {
+		play("q")
+		Expect(out.String()).To(HavePrefix("\x1b[?1049h"))
+		Expect(out.String()).To(HaveSuffix("\x1b[?1049l"))
+	})
+})
+
+var _ = Describe("ReadKeys", func() {
+	It("should name special keys", func() {
+		keys := make(chan string)
+		go ReadKeys(strings.NewReader("a\x1b[A\x1b[D\t\r\x03\x1b"), keys)
+		names := make([]string, 0)
+		for key := range keys {
+			names = append(names, key)
+		}
+		Expect(names).To(Equal([]string{"a", KeyUp, KeyLeft, KeyTab, KeyEnter, KeyCtrlC, KeyEscape}))
+	})
+})
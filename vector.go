@@ -85,20 +85,21 @@ func (v Vector) Dir() Vector {
 }
 
 // Flatten returns the index of the Vector as if its XY grid were flattened
-// into a single row, given the total number of rows in the grid.
-func (v Vector) Flatten(nRows int) int {
-	return v.X + (v.Y * nRows)
+// into a single row, given the length of each row in the grid.
+func (v Vector) Flatten(rowLen int) int {
+	return v.X + (v.Y * rowLen)
 }
 
 // Radius returns the surrounding Vectors by the given radius, ignoring the
 // Z axis.
 func (v Vector) Radius(radius int) []Vector {
-	n := (2*radius + 1) ^ 2 - 1
+	side := 2*radius + 1
+	n := side*side - 1
 	vectors := make([]Vector, n)
 
 	i := 0
-	for y := -radius; y < radius; y++ {
-		for x := -radius; x < radius; x++ {
+	for y := -radius; y <= radius; y++ {
+		for x := -radius; x <= radius; x++ {
 			vec := v.Plus(Vec2D(x, y))
 			if !vec.Equals(v) {
 				vectors[i] = vec
@@ -109,12 +110,12 @@ func (v Vector) Radius(radius int) []Vector {
 	return vectors
 }
 
-// RadiusR is like Radius but randomizes the returned Vectors.
-func (v Vector) RadiusR(radius int) []Vector {
+// RadiusR is like Radius but randomizes the returned Vectors using rng.
+func (v Vector) RadiusR(rng *rand.Rand, radius int) []Vector {
 	vectors := v.Radius(radius)
 
 	shuffled := make([]Vector, len(vectors))
-	for i, j := range rand.Perm(len(vectors)) {
+	for i, j := range rng.Perm(len(vectors)) {
 		shuffled[i] = vectors[j]
 	}
 	return shuffled
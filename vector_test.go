@@ -0,0 +1,47 @@
+package ecoscript_test
+
+import (
+	. "github.com/dustinrohde/ecoscript"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Vector", func() {
+	Describe("Vector#Radius()", func() {
+		It("should return every surrounding Vector but the origin", func() {
+			origin := Vec(5, 5, 1)
+			Expect(origin.Radius(1)).To(ConsistOf(
+				Vec(4, 4, 1), Vec(5, 4, 1), Vec(6, 4, 1),
+				Vec(4, 5, 1), Vec(6, 5, 1),
+				Vec(4, 6, 1), Vec(5, 6, 1), Vec(6, 6, 1),
+			))
+
+			vectors := origin.Radius(2)
+			Expect(vectors).To(HaveLen(24))
+			Expect(vectors).To(ContainElement(Vec(7, 7, 1)))
+			Expect(vectors).To(ContainElement(Vec(3, 3, 1)))
+			Expect(vectors).NotTo(ContainElement(origin))
+		})
+	})
+
+	Describe("Vector#Flatten()", func() {
+		It("should index by the length of each row", func() {
+			Expect(Vec(2, 1, 0).Flatten(5)).To(Equal(7))
+		})
+
+		It("should give each Cell of a World that isn't square its own index", func() {
+			world := NewWorld(4, 2, []string{"ground"})
+			ent := NewEntity("rock", "o")
+			exec, ok := world.Add(ent, Vec(3, 1, 0))
+			Expect(ok).To(BeTrue())
+			exec()
+
+			for y := 0; y < 2; y++ {
+				for x := 0; x < 4; x++ {
+					Expect(world.Cell(Vec(x, y, 0)).Exists(ent)).To(Equal(x == 3 && y == 1))
+				}
+			}
+		})
+	})
+})
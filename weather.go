@@ -0,0 +1,201 @@
+package ecoscript
+
+import (
+	"math/rand"
+)
+
+const (
+	Drought = "drought"
+	Flood   = "flood"
+	Fire    = "fire"
+)
+
+const (
+	// TraitPlant marks Entities that droughts damage, unless a Weather says
+	// otherwise.
+	TraitPlant Trait = "plant"
+
+	// TraitAquatic marks Entities that floods spare, unless a Weather says
+	// otherwise.
+	TraitAquatic Trait = "aquatic"
+)
+
+// Region is a rectangle of Cells. A Region with no width or height covers
+// the whole World.
+type Region struct {
+	X      int `mapstructure:"x"`
+	Y      int `mapstructure:"y"`
+	Width  int `mapstructure:"width"`
+	Height int `mapstructure:"height"`
+}
+
+// Contains returns true if the Vector lies inside the Region.
+func (r Region) Contains(vec Vector) bool {
+	if r.Width == 0 || r.Height == 0 {
+		return true
+	}
+	return vec.X >= r.X && vec.X < r.X+r.Width &&
+		vec.Y >= r.Y && vec.Y < r.Y+r.Height
+}
+
+// Weather is an event that changes terrain and damages Entities over a
+// Region for a number of ticks.
+//
+// Scheduled Weather starts on tick At. Random Weather starts on any tick
+// with probability Chance, but only during the listed Seasons, if any.
+//
+//   - A drought dries out and impoverishes the ground and damages Entities
+//     with the trait Harms, TraitPlant by default.
+//   - A flood soaks the ground, makes Cells below Level impassable until it
+//     ends, and damages Entities caught in them, except those with the trait
+//     Spares, TraitAquatic by default.
+//   - A fire damages every Entity and leaves dry but fertile ground.
+type Weather struct {
+	Kind     string   `mapstructure:"kind" validate:"oneof=drought flood fire"`
+	At       int      `mapstructure:"at" validate:"min=0"`
+	Chance   float64  `mapstructure:"chance" validate:"min=0,max=1"`
+	Seasons  []string `mapstructure:"seasons"`
+	Duration int      `mapstructure:"duration" validate:"min=1"`
+	Region   Region   `mapstructure:"region"`
+	Layer    string   `mapstructure:"layer"`
+	Damage   int      `mapstructure:"damage" validate:"min=0"`
+	Level    float64  `mapstructure:"level"`
+	Harms    Trait    `mapstructure:"harms"`
+	Spares   Trait    `mapstructure:"spares"`
+
+	remaining int
+	flooded   map[Vector]bool
+}
+
+// Active returns true while the Weather is in progress.
+func (wx *Weather) Active() bool {
+	return wx.remaining > 0
+}
+
+func (wx *Weather) starts(clock *Clock, rng *rand.Rand) bool {
+	if wx.Active() {
+		return false
+	}
+	if wx.Chance == 0 {
+		return clock.Ticks() == wx.At
+	}
+	if len(wx.Seasons) > 0 {
+		inSeason := false
+		for _, name := range wx.Seasons {
+			inSeason = inSeason || name == clock.Season().Name
+		}
+		if !inSeason {
+			return false
+		}
+	}
+	return rng.Float64() < wx.Chance
+}
+
+func (wx *Weather) update(w *World) {
+	if wx.starts(w.Clock(), w.Rand()) {
+		wx.remaining = wx.Duration
+		wx.flooded = make(map[Vector]bool)
+	}
+	if !wx.Active() {
+		return
+	}
+
+	wx.each(w, wx.apply)
+
+	wx.remaining--
+	if !wx.Active() && wx.Kind == Flood {
+		for vec := range wx.flooded {
+			w.Cell(vec).Terrain().Impassable = false
+		}
+		wx.flooded = nil
+	}
+}
+
+func (wx *Weather) each(w *World, fn func(*World, Vector)) {
+	for z := 0; z < w.Depth(); z++ {
+		if wx.Layer != "" && w.Layer(z).Name() != wx.Layer {
+			continue
+		}
+		for y := 0; y < w.Height(); y++ {
+			for x := 0; x < w.Width(); x++ {
+				vec := Vec(x, y, z)
+				if wx.Region.Contains(vec) {
+					fn(w, vec)
+				}
+			}
+		}
+	}
+}
+
+func (wx *Weather) apply(w *World, vec Vector) {
+	cell := w.Cell(vec)
+	terrain := cell.Terrain()
+
+	switch wx.Kind {
+	case Drought:
+		terrain.Moisture = clamp(terrain.Moisture-0.05, 0, 1)
+		terrain.Fertility *= 0.98
+		harms := wx.Harms
+		if harms == "" {
+			harms = TraitPlant
+		}
+		wx.damage(w, vec, func(ent *Entity) bool {
+			return ent.HasTrait(harms)
+		})
+
+	case Flood:
+		terrain.Moisture = 1
+		if terrain.Elevation < wx.Level {
+			if !terrain.Impassable {
+				terrain.Impassable = true
+				wx.flooded[vec] = true
+			}
+			spares := wx.Spares
+			if spares == "" {
+				spares = TraitAquatic
+			}
+			wx.damage(w, vec, func(ent *Entity) bool {
+				return !ent.HasTrait(spares)
+			})
+		}
+
+	case Fire:
+		terrain.Moisture = 0
+		terrain.Fertility += 0.01
+		wx.damage(w, vec, func(ent *Entity) bool {
+			return true
+		})
+	}
+}
+
+func (wx *Weather) damage(w *World, vec Vector, pred func(*Entity) bool) {
+	if wx.Damage == 0 {
+		return
+	}
+	ents := w.Cell(vec).Entities()
+	victims := make([]*Entity, 0, len(ents))
+	for _, ent := range ents {
+		if pred(ent) {
+			victims = append(victims, ent)
+		}
+	}
+
+	for _, ent := range victims {
+		if ent.Transfer(-wx.Damage) {
+			continue
+		}
+		if exec, ok := w.destroy(ent, vec, wx.Kind, true); ok {
+			exec()
+		}
+	}
+}
+
+func clamp(n, min, max float64) float64 {
+	if n < min {
+		return min
+	}
+	if n > max {
+		return max
+	}
+	return n
+}
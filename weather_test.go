@@ -0,0 +1,47 @@
+package ecoscript_test
+
+import (
+	. "github.com/dustinrohde/ecoscript"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Weather", func() {
+	var (
+		world       *World
+		fern, wheat *Entity
+	)
+
+	BeforeEach(func() {
+		world = NewWorld(2, 1, []string{"ground"})
+		fern = NewEntity("fern", "f").AddAttributes(&Attributes{Energy: 5}).AddTraits(TraitPlant)
+		wheat = NewEntity("wheat", "w").AddAttributes(&Attributes{Energy: 5}).AddTraits("crop")
+		for x, ent := range []*Entity{fern, wheat} {
+			exec, ok := world.Add(ent, Vec(x, 0, 0))
+			Expect(ok).To(BeTrue())
+			exec()
+		}
+	})
+
+	It("should damage plants in a drought", func() {
+		world.AddWeather(&Weather{Kind: Drought, Duration: 1, Damage: 1})
+		world.Tick()
+		Expect(fern.Attrs.Energy).To(Equal(4))
+		Expect(wheat.Attrs.Energy).To(Equal(5))
+	})
+
+	It("should damage the trait a drought harms, if given", func() {
+		world.AddWeather(&Weather{Kind: Drought, Duration: 1, Damage: 1, Harms: "crop"})
+		world.Tick()
+		Expect(fern.Attrs.Energy).To(Equal(5))
+		Expect(wheat.Attrs.Energy).To(Equal(4))
+	})
+
+	It("should spare the trait a flood spares, if given", func() {
+		world.AddWeather(&Weather{Kind: Flood, Duration: 1, Damage: 1, Level: 1, Spares: "crop"})
+		world.Tick()
+		Expect(fern.Attrs.Energy).To(Equal(4))
+		Expect(wheat.Attrs.Energy).To(Equal(5))
+	})
+})
@@ -2,6 +2,9 @@ package ecoscript
 
 import (
 	"math/rand"
+	"sort"
+
+	"github.com/pkg/errors"
 )
 
 // ---------------------------------------------------------------------
@@ -12,17 +15,46 @@ type World struct {
 	height int
 	depth  int
 	layers []*Layer
+
+	clock   *Clock
+	weather []*Weather
+	fields  []*Field
+	species map[string]*Species
+	events  *Bus
+
+	src       *source
+	rng       *rand.Rand
+	nextID    EntityID
+	scheduler Scheduler
+
+	// emptyTile and displayLegend are how the World asks to be drawn.
+	emptyTile     string
+	displayLegend bool
+
+	// The views of a World that a ParallelScheduler ticks regions with
+	// allocate every idStride'th ID, and buffer their Events.
+	idStride EntityID
+	buffer   *[]Event
 }
 
 func NewWorld(width, height int, layerNames []string) *World {
 	depth := len(layerNames)
 	layers := make([]*Layer, depth)
+	src := newSource(defaultSeed())
 
 	world := &World{
-		width:  width,
-		height: height,
-		depth:  depth,
-		layers: layers,
+		width:     width,
+		height:    height,
+		depth:     depth,
+		layers:    layers,
+		clock:     NewClock(),
+		weather:   make([]*Weather, 0),
+		fields:    make([]*Field, 0),
+		species:   make(map[string]*Species),
+		events:    NewBus(),
+		src:       src,
+		rng:       rand.New(src),
+		scheduler: new(SerialScheduler),
 	}
 	for z, name := range layerNames {
 		world.addLayer(z, name)
@@ -31,46 +63,20 @@ func NewWorld(width, height int, layerNames []string) *World {
 }
 
 func (w *World) Tick() {
-	// For each layer...
-	for z := 0; z < w.Depth(); z++ {
-		layer := w.Layer(z)
+	// Start, continue or end weather events.
+	for _, wx := range w.weather {
+		wx.update(w)
+	}
 
-		// For each cell...
-		for _, y := range rand.Perm(layer.Height()) {
-			for _, x := range rand.Perm(layer.Width()) {
-				vec := Vec(x, y, z)
-				cell := layer.Cell(vec)
-				entities := cell.Shuffled()
-
-				for i := range entities {
-					// Check index each iteration to account for entities that were removed.
-					if i <= cell.Population()-1 {
-						break
-					}
-					// Tick entity.
-					ent := entities[i]
-					// TODO: MAYBE (?) suspend actions until end to resolve conflicts (?)
-					ent.Tick(w, vec)
-				}
-			}
-		}
+	// Tick every entity.
+	w.scheduler.Tick(w)
 
-		//<<< sequential iteration through world >>>
-		//------------------------------------------
-		//for y := 0; y < layer.Height(); y++ {
-		//	for x := 0; x < layer.Width(); x++ {
-		//		vec := To2D(x, y)
-		//		cell := layer.Cell(vec)
-		//		entities := cell.Entities()
-		//
-		//		for i := range entities {
-		//			ent := entities[i]
-		//			ent.Tick(w, vec)
-		//		}
-		//	}
-		//}
-		//------------------------------------------
+	// Spread and fade fields.
+	for _, field := range w.fields {
+		field.step()
 	}
+
+	w.clock.advance()
 }
 
 func (w *World) addLayer(z int, name string) *Layer {
@@ -85,19 +91,234 @@ func (w *World) addLayer(z int, name string) *Layer {
 
 	layer := &Layer{
 		name:   name,
+		z:      z,
 		width:  width,
 		height: height,
 		depth:  w.depth,
 		cells:  cells,
+		rng:    w.rng,
+		above:  -1,
+		below:  -1,
 	}
 	w.layers[z] = layer
 	return layer
 }
 
+// Seed reseeds the random source that the World draws from. Two Worlds
+// with the same state and seed play out identically.
+func (w *World) Seed(seed int64) {
+	w.src.Seed(seed)
+}
+
+// Rand returns the random source of the World. Everything random that
+// happens in a World must draw from it, or runs can't be replayed.
+func (w *World) Rand() *rand.Rand {
+	return w.rng
+}
+
+// nextEntityID allocates an EntityID that is unique within the World.
+func (w *World) nextEntityID() EntityID {
+	id := w.nextID
+	if w.idStride > 0 {
+		w.nextID += w.idStride
+	} else {
+		w.nextID++
+	}
+	return id
+}
+
+func (w *World) Clock() *Clock {
+	return w.clock
+}
+
+func (w *World) SetClock(clock *Clock) {
+	w.clock = clock
+}
+
+// EmptyTile returns the symbol the World's empty Cells are drawn with.
+func (w *World) EmptyTile() string {
+	return w.emptyTile
+}
+
+func (w *World) SetEmptyTile(symbol string) {
+	w.emptyTile = symbol
+}
+
+// DisplayLegend reports whether the World is drawn with a legend.
+func (w *World) DisplayLegend() bool {
+	return w.displayLegend
+}
+
+func (w *World) SetDisplayLegend(show bool) {
+	w.displayLegend = show
+}
+
+func (w *World) Weather() []*Weather {
+	return w.weather
+}
+
+func (w *World) AddWeather(weather ...*Weather) {
+	w.weather = append(w.weather, weather...)
+}
+
+// AddField adds a Field with the given name to every Layer. Diffusion and
+// decay are the fractions of each Cell's value that spread to its neighbors
+// and that fade away each tick.
+func (w *World) AddField(name string, diffusion, decay float64) *Field {
+	field := newField(name, diffusion, decay, w.Width(), w.Height(), w.Depth())
+	w.fields = append(w.fields, field)
+	return field
+}
+
+// Field returns the Field with the given name, or nil if there is none.
+func (w *World) Field(name string) *Field {
+	for _, field := range w.fields {
+		if field.Name == name {
+			return field
+		}
+	}
+	return nil
+}
+
+func (w *World) Fields() []*Field {
+	return w.fields
+}
+
+// Events returns the Bus that the World publishes Events to.
+func (w *World) Events() *Bus {
+	return w.events
+}
+
+// emit publishes an Event, stamped with the current tick.
+func (w *World) emit(ev Event) {
+	if !w.events.Active() {
+		return
+	}
+	ev.Tick = w.clock.Ticks()
+	if w.buffer != nil {
+		*w.buffer = append(*w.buffer, ev)
+		return
+	}
+	w.events.Publish(ev)
+}
+
+func entityEvent(typ EventType, ent *Entity, vec Vector) Event {
+	return Event{
+		Type:    typ,
+		Entity:  ent.ID(),
+		Species: ent.Species(),
+		Vec:     vec,
+	}
+}
+
+// AddSpecies makes a Species available to spawn Entities from.
+func (w *World) AddSpecies(species ...*Species) {
+	for i := range species {
+		w.species[species[i].Key] = species[i]
+	}
+}
+
+// Species returns the Species with the given key, or nil if there is none.
+func (w *World) Species(key string) *Species {
+	return w.species[key]
+}
+
+// SpeciesKeys returns the keys of all Species in sorted order.
+func (w *World) SpeciesKeys() []string {
+	keys := make([]string, 0, len(w.species))
+	for key := range w.species {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// Find returns the Entity with the given ID and where it is.
+func (w *World) Find(id EntityID) (*Entity, Vector, bool) {
+	for z, layer := range w.layers {
+		for i, cell := range layer.cells {
+			for _, ent := range cell.Entities() {
+				if ent.ID() == id {
+					return ent, Vec(i%w.width, i/w.width, z), true
+				}
+			}
+		}
+	}
+	return nil, Vector{}, false
+}
+
 func (w *World) Layer(z int) *Layer {
 	return w.layers[z]
 }
 
+// LayerIndex returns the Z index of the Layer with the given name.
+func (w *World) LayerIndex(name string) (z int, ok bool) {
+	for z = range w.layers {
+		if w.layers[z].name == name {
+			return z, true
+		}
+	}
+	return -1, false
+}
+
+// Relate declares that the Layer named upper lies directly above the Layer
+// named lower.
+func (w *World) Relate(upper, lower string) error {
+	zUp, ok := w.LayerIndex(upper)
+	if !ok {
+		return errors.Errorf("no layer named '%s'", upper)
+	}
+	zLow, ok := w.LayerIndex(lower)
+	if !ok {
+		return errors.Errorf("no layer named '%s'", lower)
+	}
+	if zUp == zLow {
+		return errors.Errorf("layer '%s' cannot lie above itself", upper)
+	}
+
+	layerUp, layerLow := w.Layer(zUp), w.Layer(zLow)
+	if layerUp.below == zLow && layerLow.above == zUp {
+		return nil
+	}
+	if layerUp.below != -1 {
+		return errors.Errorf("layer '%s' already lies above '%s'", upper, w.Layer(layerUp.below).name)
+	}
+	if layerLow.above != -1 {
+		return errors.Errorf("layer '%s' already lies below '%s'", lower, w.Layer(layerLow.above).name)
+	}
+	for z := zUp; z != -1; z = w.Layer(z).above {
+		if z == zLow {
+			return errors.Errorf("layers '%s' and '%s' would form a cycle", upper, lower)
+		}
+	}
+
+	layerUp.below = zLow
+	layerLow.above = zUp
+	return nil
+}
+
+// Project returns the Vector with the same X and Y coordinates in the Layer
+// with the given name.
+func (w *World) Project(vec Vector, name string) (Vector, bool) {
+	z, ok := w.LayerIndex(name)
+	if !ok {
+		return vec, false
+	}
+	return Vec(vec.X, vec.Y, z), true
+}
+
+// Shaded returns true if any Layer above the given Vector blocks light and
+// is occupied at the same X and Y coordinates.
+func (w *World) Shaded(vec Vector) bool {
+	for z, ok := w.Layer(vec.Z).Above(); ok; z, ok = w.Layer(z).Above() {
+		layer := w.Layer(z)
+		if layer.BlocksLight() && layer.Cell(vec).Occupied() {
+			return true
+		}
+	}
+	return false
+}
+
 func (w *World) Width() int {
 	return w.width
 }
@@ -111,7 +332,7 @@ func (w *World) Depth() int {
 }
 
 func (w *World) Cell(vec Vector) *Cell {
-	index := vec.Flatten(w.Height())
+	index := vec.Flatten(w.Width())
 	return w.layers[vec.Z].cells[index]
 }
 
@@ -147,20 +368,88 @@ func (w *World) RandWalkable(origin Vector, radius int) Vector {
 	return SpaceRandWalkable(w, origin, radius)
 }
 
+// Add adds an Entity to the World. An Entity created by NewEntity is given
+// an ID by the World when it's added.
 func (w *World) Add(entity *Entity, vec Vector) (exec action, ok bool) {
-	return SpaceAdd(w, entity, vec)
+	exec, ok = SpaceAdd(w, entity, vec)
+	if ok {
+		exec = chain(func() {
+			if entity.id == noEntityID && w.Cell(vec).Accepts(entity) {
+				entity.id = w.nextEntityID()
+			}
+		}, exec, func() {
+			w.placed(entity, vec)
+		})
+	}
+	return
 }
 
 func (w *World) Remove(entity *Entity, vec Vector) (exec action, ok bool) {
-	return SpaceRemove(w, entity, vec)
+	exec, ok = SpaceRemove(w, entity, vec)
+	if ok {
+		exec = chain(exec, func() {
+			w.removed(entity, vec)
+		})
+	}
+	return
+}
+
+// Spawn adds a new Entity to the World like Add, and announces its birth.
+func (w *World) Spawn(entity *Entity, vec Vector) (exec action, ok bool) {
+	exec, ok = w.Add(entity, vec)
+	if ok {
+		exec = chain(exec, func() {
+			if w.Cell(vec).Exists(entity) {
+				w.emit(entityEvent(EventSpawned, entity, vec))
+			}
+		})
+	}
+	return
 }
 
 func (w *World) Move(entity *Entity, src Vector, dst Vector) (exec action, ok bool) {
-	return SpaceMove(w, entity, src, dst)
+	exec, ok = SpaceMove(w, entity, src, dst)
+	if ok {
+		exec = chain(exec, func() {
+			if w.Cell(dst).Exists(entity) {
+				w.placed(entity, dst)
+				ev := entityEvent(EventMoved, entity, src)
+				ev.Dest = dst
+				w.emit(ev)
+			}
+		})
+	}
+	return
 }
 
+// Destroy removes and destroys an Entity like SpaceDestroy. Its biomass then
+// returns to the nutrients Field, if the World has one.
 func (w *World) Destroy(entity *Entity, vec Vector) (exec action, ok bool) {
-	return SpaceDestroy(w, entity, vec)
+	return w.destroy(entity, vec, "destroyed", true)
+}
+
+// destroy is like Destroy, and announces the death with the given cause. An
+// Entity leaves remains unless it was eaten, in which case whatever ate it
+// takes its biomass instead.
+func (w *World) destroy(entity *Entity, vec Vector, cause string, remains bool) (exec action, ok bool) {
+	execDestroy, ok := SpaceDestroy(w, entity, vec)
+	if ok {
+		// The Entity may have died or moved away in the meantime.
+		cell := w.Cell(vec)
+		exec = func() {
+			if !cell.Exists(entity) {
+				return
+			}
+			execDestroy()
+			if field := w.Field(FieldNutrients); field != nil && remains {
+				field.Add(vec, float64(entity.Biomass()))
+			}
+			ev := entityEvent(EventDied, entity, vec)
+			ev.Detail = cause
+			w.emit(ev)
+		}
+	}
+	return
 }
 
 // ---------------------------------------------------------------------
@@ -172,6 +461,81 @@ type Layer struct {
 	depth  int
 	name   string
 	cells  []*Cell
+	rng    *rand.Rand
+
+	z           int
+	above       int
+	below       int
+	blocksLight bool
+	permeable   bool
+
+	// drawOrder and opaque control how the Layer is composited with the
+	// others when the World is displayed.
+	drawOrder int
+	opaque    bool
+}
+
+func (l *Layer) Name() string {
+	return l.name
+}
+
+// Z returns the index of the Layer in its World.
+func (l *Layer) Z() int {
+	return l.z
+}
+
+// Above returns the Z index of the Layer directly above, if any.
+func (l *Layer) Above() (z int, ok bool) {
+	return l.above, l.above != -1
+}
+
+// Below returns the Z index of the Layer directly below, if any.
+func (l *Layer) Below() (z int, ok bool) {
+	return l.below, l.below != -1
+}
+
+// BlocksLight returns true if occupied Cells in the Layer shade the Layers
+// beneath them.
+func (l *Layer) BlocksLight() bool {
+	return l.blocksLight
+}
+
+// Permeable returns true if Entities can pass into the Layer from adjacent
+// Layers.
+func (l *Layer) Permeable() bool {
+	return l.permeable
+}
+
+func (l *Layer) SetBlocksLight(blocksLight bool) *Layer {
+	l.blocksLight = blocksLight
+	return l
+}
+
+func (l *Layer) SetPermeable(permeable bool) *Layer {
+	l.permeable = permeable
+	return l
+}
+
+// DrawOrder returns the Layer's place in the order Layers are composited,
+// where Layers with higher DrawOrders are drawn over those with lower ones.
+func (l *Layer) DrawOrder() int {
+	return l.drawOrder
+}
+
+func (l *Layer) SetDrawOrder(order int) *Layer {
+	l.drawOrder = order
+	return l
+}
+
+// Opaque returns true if the Layer hides the Layers drawn beneath it when
+// the World is composited, even where its Cells are empty.
+func (l *Layer) Opaque() bool {
+	return l.opaque
+}
+
+func (l *Layer) SetOpaque(opaque bool) *Layer {
+	l.opaque = opaque
+	return l
 }
 
 func (l *Layer) Width() int {
@@ -183,7 +547,7 @@ func (l *Layer) Height() int {
 }
 
 func (l *Layer) Cell(vec Vector) *Cell {
-	index := vec.Flatten(l.Height())
+	index := vec.Flatten(l.Width())
 	return l.cells[index]
 }
 
@@ -191,6 +555,10 @@ func (l *Layer) Cells() []*Cell {
 	return l.cells
 }
 
+func (l *Layer) Rand() *rand.Rand {
+	return l.rng
+}
+
 func (l *Layer) InBounds(vec Vector) bool {
 	return SpaceInBounds(l, vec)
 }
@@ -56,6 +56,21 @@ var _ = Describe("World", func() {
 				Expect(cell.Occupier()).To(BeNil())
 			})
 		})
+
+		It("should give new Entities IDs that Species don't reuse", func() {
+			exec, ok := world.Add(ent3, Vec(0, 0, 0))
+			Expect(ok).To(BeTrue())
+			exec()
+			spawned := (&Species{Name: "spawned", Symbol: "s"}).Spawn(world)
+			exec, ok = world.Add(spawned, Vec(1, 0, 0))
+			Expect(ok).To(BeTrue())
+			exec()
+
+			Expect(spawned.ID()).NotTo(Equal(ent3.ID()))
+			found, _, ok := world.Find(ent3.ID())
+			Expect(ok).To(BeTrue())
+			Expect(found).To(BeIdenticalTo(ent3))
+		})
 	})
 
 	Describe("World#Remove()", func() {
@@ -160,4 +175,67 @@ var _ = Describe("World", func() {
 			})
 		})
 	})
+
+	Describe("World#Relate()", func() {
+		BeforeEach(func() {
+			world = NewWorld(3, 3, []string{"canopy", "ground", "soil"})
+		})
+
+		It("should link the two Layers", func() {
+			Expect(world.Relate("canopy", "ground")).To(Succeed())
+
+			z, ok := world.Layer(0).Below()
+			Expect(ok).To(BeTrue())
+			Expect(z).To(Equal(1))
+			z, ok = world.Layer(1).Above()
+			Expect(ok).To(BeTrue())
+			Expect(z).To(Equal(0))
+		})
+
+		It("should reject unknown Layers and cycles", func() {
+			Expect(world.Relate("canopy", "sky")).NotTo(Succeed())
+			Expect(world.Relate("canopy", "ground")).To(Succeed())
+			Expect(world.Relate("ground", "soil")).To(Succeed())
+			Expect(world.Relate("soil", "canopy")).NotTo(Succeed())
+		})
+	})
+
+	Describe("World#Shaded()", func() {
+		BeforeEach(func() {
+			world = NewWorld(3, 3, []string{"canopy", "ground"})
+			Expect(world.Relate("canopy", "ground")).To(Succeed())
+			world.Layer(0).SetBlocksLight(true)
+
+			exec, ok := world.Add(entUnwalkable, Vec(1, 1, 0))
+			Expect(ok).To(BeTrue())
+			exec()
+		})
+
+		It("should be true beneath an occupied Cell of a light-blocking Layer", func() {
+			Expect(world.Shaded(Vec(1, 1, 1))).To(BeTrue())
+			Expect(world.Shaded(Vec(0, 1, 1))).To(BeFalse())
+		})
+
+		It("should be false if the Layer above lets light through", func() {
+			world.Layer(0).SetBlocksLight(false)
+			Expect(world.Shaded(Vec(1, 1, 1))).To(BeFalse())
+		})
+	})
+
+	Describe("World#Walkable()", func() {
+		It("should be false on impassable Terrain", func() {
+			vec := Vec(1, 1, 0)
+			Expect(world.Walkable(vec)).To(BeTrue())
+
+			terrain := DefaultTerrain
+			terrain.Impassable = true
+			world.Cell(vec).SetTerrain(terrain)
+			Expect(world.Walkable(vec)).To(BeFalse())
+		})
+
+		It("should be false out of bounds", func() {
+			Expect(world.Walkable(Vec(-1, 0, 0))).To(BeFalse())
+			Expect(world.Walkable(Vec(0, 3, 0))).To(BeFalse())
+		})
+	})
 })